@@ -1,5 +1,13 @@
 @echo off
 chcp 65001 > nul
+rem Build info injected via ldflags; set WEBP_TOOLS_VERSION to the bundled libwebp version
+set COMMIT=
+set BUILD_DATE=
+for /f %%i in ('git rev-parse HEAD 2^>nul') do set COMMIT=%%i
+for /f %%i in ('powershell -NoProfile -Command "Get-Date -Format o"') do set BUILD_DATE=%%i
+set BUILDINFO=webpcompressor/internal/buildinfo
+set LDFLAGS=-X %BUILDINFO%.Commit=%COMMIT% -X %BUILDINFO%.Date=%BUILD_DATE% -X %BUILDINFO%.ToolsVersion=%WEBP_TOOLS_VERSION%
+
 echo 🎨 WebP Multi-Tool Builder
 echo =======================================
 
@@ -21,7 +29,7 @@ goto invalid
 echo.
 echo 🔧 Building Standard Version...
 if not exist bin mkdir bin
-go build -o bin/webpcompressor.exe cmd/webpcompressor/main.go
+go build -ldflags "%LDFLAGS%" -o bin/webpcompressor.exe cmd/webpcompressor/main.go
 if %ERRORLEVEL% EQU 0 (
     echo ✅ Standard version built: bin/webpcompressor.exe
 ) else (
@@ -33,7 +41,7 @@ goto end
 echo.
 echo 🔧 Building Embedded Version...
 if not exist bin mkdir bin
-go build -o bin/webptools.exe cmd/embedded/main.go
+go build -ldflags "%LDFLAGS%" -o bin/webptools.exe cmd/embedded/main.go
 if %ERRORLEVEL% EQU 0 (
     echo ✅ Embedded version built: bin/webptools.exe
     echo 📁 Embedded 12 WebP tools
@@ -46,7 +54,7 @@ goto end
 echo.
 echo 🔧 Building Standard Version...
 if not exist bin mkdir bin
-go build -o bin/webpcompressor.exe cmd/webpcompressor/main.go
+go build -ldflags "%LDFLAGS%" -o bin/webpcompressor.exe cmd/webpcompressor/main.go
 if %ERRORLEVEL% EQU 0 (
     echo ✅ Standard version built: bin/webpcompressor.exe
 ) else (
@@ -55,7 +63,7 @@ if %ERRORLEVEL% EQU 0 (
 
 echo.
 echo 🔧 Building Embedded Version...
-go build -o bin/webptools.exe cmd/embedded/main.go
+go build -ldflags "%LDFLAGS%" -o bin/webptools.exe cmd/embedded/main.go
 if %ERRORLEVEL% EQU 0 (
     echo ✅ Embedded version built: bin/webptools.exe
 ) else (
@@ -1,5 +1,13 @@
 @echo off
 chcp 936 > nul
+rem 构建信息通过ldflags注入，嵌入工具包版本由WEBP_TOOLS_VERSION指定
+set COMMIT=
+set BUILD_DATE=
+for /f %%i in ('git rev-parse HEAD 2^>nul') do set COMMIT=%%i
+for /f %%i in ('powershell -NoProfile -Command "Get-Date -Format o"') do set BUILD_DATE=%%i
+set BUILDINFO=webpcompressor/internal/buildinfo
+set LDFLAGS=-X %BUILDINFO%.Commit=%COMMIT% -X %BUILDINFO%.Date=%BUILD_DATE% -X %BUILDINFO%.ToolsVersion=%WEBP_TOOLS_VERSION%
+
 echo WebP工具构建脚本
 echo =======================================
 
@@ -21,7 +29,7 @@ goto invalid
 echo.
 echo 构建标准版...
 if not exist bin mkdir bin
-go build -o bin\webpcompressor.exe cmd\webpcompressor\main.go
+go build -ldflags "%LDFLAGS%" -o bin\webpcompressor.exe cmd\webpcompressor\main.go
 if %ERRORLEVEL% EQU 0 (
     echo 标准版构建完成: bin\webpcompressor.exe
 ) else (
@@ -33,7 +41,7 @@ goto end
 echo.
 echo 构建嵌入版...
 if not exist bin mkdir bin
-go build -o bin\webptools.exe cmd\embedded\main.go
+go build -ldflags "%LDFLAGS%" -o bin\webptools.exe cmd\embedded\main.go
 if %ERRORLEVEL% EQU 0 (
     echo 嵌入版构建完成: bin\webptools.exe
     echo 已嵌入12个WebP工具
@@ -46,7 +54,7 @@ goto end
 echo.
 echo 构建标准版...
 if not exist bin mkdir bin
-go build -o bin\webpcompressor.exe cmd\webpcompressor\main.go
+go build -ldflags "%LDFLAGS%" -o bin\webpcompressor.exe cmd\webpcompressor\main.go
 if %ERRORLEVEL% EQU 0 (
     echo 标准版构建完成: bin\webpcompressor.exe
 ) else (
@@ -55,7 +63,7 @@ if %ERRORLEVEL% EQU 0 (
 
 echo.
 echo 构建嵌入版...
-go build -o bin\webptools.exe cmd\embedded\main.go
+go build -ldflags "%LDFLAGS%" -o bin\webptools.exe cmd\embedded\main.go
 if %ERRORLEVEL% EQU 0 (
     echo 嵌入版构建完成: bin\webptools.exe
 ) else (
@@ -3,12 +3,20 @@ package main
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"webpcompressor/internal/buildinfo"
+	"webpcompressor/internal/cli"
 	"webpcompressor/internal/config"
 	"webpcompressor/internal/domain"
 	"webpcompressor/internal/infrastructure"
@@ -86,15 +94,13 @@ type EmbeddedApplication struct {
 	tempDir        string
 }
 
-// NewEmbeddedApplication 创建嵌入式应用程序
-func NewEmbeddedApplication() (*EmbeddedApplication, error) {
+// NewEmbeddedApplication 创建嵌入式应用程序，只加载配置和日志；嵌入工具在需要时由initServices提取，
+// 这样help和version命令不必提取工具，工具损坏时也能输出构建信息
+func NewEmbeddedApplication() *EmbeddedApplication {
 	// 加载配置
 	cfg := config.DefaultConfig()
 	cfg.LoadFromEnv()
 	cfg.Tools.UseEmbedded = true // 强制使用嵌入模式
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("配置验证失败: %w", err)
-	}
 
 	// 初始化日志
 	appLogger, err := logger.NewLogger(&cfg.Logging)
@@ -102,16 +108,30 @@ func NewEmbeddedApplication() (*EmbeddedApplication, error) {
 		appLogger = logger.NewDefaultLogger()
 		appLogger.Warn("使用默认日志配置", "error", err)
 	}
+	appLogger.Info("启动", buildinfo.Get(cfg.App.Version).LogFields()...)
+
+	return &EmbeddedApplication{
+		config: cfg,
+		logger: appLogger,
+	}
+}
+
+// initServices 验证配置，提取嵌入工具并创建压缩服务
+func (app *EmbeddedApplication) initServices() error {
+	if err := app.config.Validate(); err != nil {
+		return fmt.Errorf("配置验证失败: %w", err)
+	}
 
 	// 提取嵌入的工具到临时目录
-	tempDir, err := extractEmbeddedTools(appLogger)
+	tempDir, err := extractEmbeddedTools(app.logger)
+	app.tempDir = tempDir
 	if err != nil {
-		return nil, fmt.Errorf("提取嵌入工具失败: %w", err)
+		return fmt.Errorf("提取嵌入工具失败: %w", err)
 	}
 
 	// 创建工厂
-	toolFactory := infrastructure.NewToolExecutorFactory(cfg, appLogger)
-	fileFactory := infrastructure.NewFileManagerFactory(cfg, appLogger)
+	toolFactory := infrastructure.NewToolExecutorFactory(app.config, app.logger)
+	fileFactory := infrastructure.NewFileManagerFactory(app.config, app.logger)
 
 	// 创建基础组件（使用嵌入模式）
 	toolExecutor := toolFactory.CreateExecutor(true, tempDir)
@@ -119,22 +139,15 @@ func NewEmbeddedApplication() (*EmbeddedApplication, error) {
 
 	// 验证工具可用性
 	if err := toolFactory.ValidateTools(toolExecutor); err != nil {
-		return nil, fmt.Errorf("工具验证失败: %w", err)
+		return fmt.Errorf("工具验证失败: %w", err)
 	}
 
 	// 创建临时目录管理器
-	tempDirManager := infrastructure.NewTempDirManager(fileManager, appLogger)
+	app.tempDirManager = infrastructure.NewTempDirManager(fileManager, app.logger)
 
 	// 创建服务
-	webpService := service.NewWebPService(cfg, toolExecutor, fileManager, appLogger)
-
-	return &EmbeddedApplication{
-		config:         cfg,
-		logger:         appLogger,
-		webpService:    webpService,
-		tempDirManager: tempDirManager,
-		tempDir:        tempDir,
-	}, nil
+	app.webpService = service.NewWebPService(app.config, toolExecutor, fileManager, app.logger)
+	return nil
 }
 
 // extractEmbeddedTools 提取嵌入的工具到临时目录
@@ -165,7 +178,9 @@ func extractEmbeddedTools(logger logger.Logger) (string, error) {
 // Cleanup 清理资源
 func (app *EmbeddedApplication) Cleanup() {
 	// 清理临时目录管理器管理的目录
-	app.tempDirManager.CleanupAll()
+	if app.tempDirManager != nil {
+		app.tempDirManager.CleanupAll()
+	}
 
 	// 清理嵌入工具的临时目录
 	if app.tempDir != "" {
@@ -177,6 +192,17 @@ func (app *EmbeddedApplication) Cleanup() {
 	}
 }
 
+// commandContext 返回带超时的命令上下文，收到中断或终止信号时同样取消，
+// 正在运行的工具子进程随之终止，命令返回后临时目录得到清理
+func (app *EmbeddedApplication) commandContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(ctx, app.config.App.Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
 // Run 运行应用程序
 func (app *EmbeddedApplication) Run(args []string) error {
 	// 确保清理资源
@@ -190,16 +216,40 @@ func (app *EmbeddedApplication) Run(args []string) error {
 	command := args[1]
 
 	switch command {
-	case "compress", "压缩":
-		return app.handleCompress(args[2:])
-	case "info", "信息":
-		return app.handleInfo(args[2:])
 	case "help", "帮助":
 		app.showDetailedHelp()
 		return nil
 	case "version", "版本":
-		fmt.Printf("WebP工具集 v%s (嵌入版)\n", app.config.App.Version)
-		return nil
+		return app.handleVersion(args[2:])
+	case "compress", "压缩", "info", "信息", "compose", "合成", "convert", "转换", "frames", "拆帧",
+		"compare", "比较", "experiments", "实验", "verify", "校验", "replay", "重放", "stats", "统计":
+		// 其余命令需要工具和服务
+		if err := app.initServices(); err != nil {
+			return err
+		}
+	}
+
+	switch command {
+	case "compress", "压缩":
+		return app.handleCompress(args[2:])
+	case "info", "信息":
+		return app.handleInfo(args[2:])
+	case "compose", "合成":
+		return app.handleCompose(args[2:])
+	case "convert", "转换":
+		return app.handleConvert(args[2:])
+	case "frames", "拆帧":
+		return app.handleFrames(args[2:])
+	case "compare", "比较":
+		return app.handleCompare(args[2:])
+	case "experiments", "实验":
+		return app.handleExperiments(args[2:])
+	case "verify", "校验":
+		return app.handleVerify(args[2:])
+	case "replay", "重放":
+		return app.handleReplay(args[2:])
+	case "stats", "统计":
+		return app.handleStats(args[2:])
 	default:
 		fmt.Printf("❌ 未知命令: %s\n", command)
 		app.showUsage()
@@ -207,25 +257,56 @@ func (app *EmbeddedApplication) Run(args []string) error {
 	}
 }
 
+// handleVersion 处理版本命令，--verbose时输出完整构建信息便于对应问题报告
+func (app *EmbeddedApplication) handleVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	verbose := fs.Bool("verbose", false, "输出提交、构建时间、Go版本和工具包版本")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	info := buildinfo.Get(app.config.App.Version)
+	if !*verbose {
+		fmt.Printf("WebP工具集 v%s (嵌入版, %s)\n", info.Version, info.Short())
+		return nil
+	}
+	fmt.Printf("WebP工具集 v%s (嵌入版)\n", info.Version)
+	fmt.Printf("  提交:     %s\n", info.Commit)
+	fmt.Printf("  构建时间: %s\n", info.Date)
+	fmt.Printf("  Go版本:   %s\n", info.GoVersion)
+	fmt.Printf("  平台:     %s\n", info.Platform)
+	fmt.Printf("  工具包:   %s (%d个工具)\n", info.ToolsVersion, len(embeddedTools))
+	return nil
+}
+
 // handleCompress 处理压缩命令
 func (app *EmbeddedApplication) handleCompress(args []string) error {
-	if len(args) < 3 {
-		fmt.Println("用法: webptools compress <input.webp> <quality[0-100]> <output.webp>")
-		return fmt.Errorf("参数不足")
+	fs := flag.NewFlagSet("compress", flag.ContinueOnError)
+	compressFlags := cli.NewCompressFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
 
-	inputFile := args[0]
-	quality, err := strconv.Atoi(args[1])
+	if fs.NArg() != 3 {
+		fmt.Println("用法: webptools compress [--max-fps N] [--drop-every-n N] [--dedup] [--frames A-B] [--trim-start T] [--trim-end T] [--auto-quality [--min-ssim S] [--min-psnr P]] [--quality-report] [--gif-estimate] [--near-lossless N] [--mixed] [--pipeline webpmux|img2webp] [--best] [--verbose] [--progress] [--priority normal|low|idle] [--encoder cwebp|ffmpeg] [--deadline D] [--continue-on-error] [--effort 1-9] [--learn suggest|apply] [--recompress-above N] [--fallback] <input.webp> <quality[0-100]> <output.webp>")
+		return cli.ArgCountError(fs, 3)
+	}
+
+	inputFile := fs.Arg(0)
+	quality, err := strconv.Atoi(fs.Arg(1))
 	if err != nil {
-		return fmt.Errorf("无效的质量参数: %s", args[1])
+		return fmt.Errorf("无效的质量参数: %s", fs.Arg(1))
 	}
-	outputFile := args[2]
+	outputFile := fs.Arg(2)
 
 	// 创建压缩配置
-	compressionConfig := domain.DefaultCompressionConfig(quality)
+	compressionConfig, err := compressFlags.Config(quality)
+	if err != nil {
+		return err
+	}
 
 	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), app.config.App.Timeout)
+	ctx, cancel := app.commandContext()
 	defer cancel()
 
 	// 记录开始
@@ -256,13 +337,7 @@ func (app *EmbeddedApplication) handleCompress(args []string) error {
 	)
 
 	// 显示用户友好的结果
-	fmt.Printf("✅ 压缩完成！\n")
-	fmt.Printf("📊 压缩效果: %s -> %s (%.1f%%)\n",
-		formatFileSize(result.OriginalSize),
-		formatFileSize(result.CompressedSize),
-		result.CompressionRatio)
-	fmt.Printf("⏱️  处理时间: %v\n", result.ProcessingTime)
-	fmt.Printf("🎞️  处理帧数: %d\n", result.FramesProcessed)
+	cli.PrintCompressResult(result, compressionConfig, app.config.Processing.ExperimentStrategy, *compressFlags.Verbose)
 
 	return nil
 }
@@ -273,11 +348,15 @@ func (app *EmbeddedApplication) handleInfo(args []string) error {
 		fmt.Println("用法: webptools info <input.webp>")
 		return fmt.Errorf("参数不足")
 	}
+	if len(args) > 1 {
+		fmt.Println("用法: webptools info <input.webp>")
+		return fmt.Errorf("多余的参数: %s", strings.Join(args[1:], " "))
+	}
 
 	inputFile := args[0]
 
 	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), app.config.App.Timeout)
+	ctx, cancel := app.commandContext()
 	defer cancel()
 
 	// 解析动画信息
@@ -307,6 +386,467 @@ func (app *EmbeddedApplication) handleInfo(args []string) error {
 	return nil
 }
 
+// handleCompose 处理合成命令
+func (app *EmbeddedApplication) handleCompose(args []string) error {
+	fs := flag.NewFlagSet("compose", flag.ContinueOnError)
+	durationMs := fs.Int("d", 100, "每帧持续时间(毫秒)")
+	durationList := fs.String("durations", "", "逐帧持续时间列表(毫秒)，以逗号分隔")
+	loopCount := fs.Int("loop", 0, "循环次数，0表示无限循环")
+	nearLossless := fs.Int("near-lossless", 0, "近无损预处理级别(1-100，越小压缩越强)，0表示不启用")
+	mixed := fs.Bool("mixed", false, "每帧分别尝试有损和无损编码，保留较小的结果")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 3 {
+		fmt.Println("用法: webptools compose [-d 100] [-durations 100,80,...] [-loop 0] [--near-lossless N] [--mixed] <frames_dir|manifest.json> <quality[0-100]> <output.webp>")
+		return cli.ArgCountError(fs, 3)
+	}
+
+	source := fs.Arg(0)
+	quality, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("无效的质量参数: %s", fs.Arg(1))
+	}
+	outputFile := fs.Arg(2)
+
+	durations, err := parseDurationList(*durationList)
+	if err != nil {
+		return err
+	}
+
+	// 目录按文件名排序加载，否则视为JSON清单
+	var frames []*domain.FrameInfo
+	loop := *loopCount
+	if info, statErr := os.Stat(source); statErr == nil && info.IsDir() {
+		frames, err = app.webpService.LoadImageSequence(source, time.Duration(*durationMs)*time.Millisecond, durations)
+	} else {
+		var manifestLoop int
+		frames, manifestLoop, err = app.webpService.LoadComposeManifest(source)
+		if !cli.IsFlagSet(fs, "loop") {
+			// 命令行显式指定的-loop优先于清单中的循环次数
+			loop = manifestLoop
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	compressionConfig := domain.DefaultCompressionConfig(quality)
+	compressionConfig.NearLossless = *nearLossless
+	compressionConfig.Mixed = *mixed
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	result, err := app.webpService.ComposeAnimation(ctx, frames, outputFile, loop, compressionConfig)
+	if err != nil {
+		app.logger.Error("合成失败", "error", err)
+		return err
+	}
+
+	fmt.Printf("✅ 合成完成！\n")
+	fmt.Printf("📊 源图像: %s -> 动画: %s\n",
+		cli.FormatFileSize(result.OriginalSize),
+		cli.FormatFileSize(result.CompressedSize))
+	fmt.Printf("⏱️  处理时间: %v\n", result.ProcessingTime)
+	fmt.Printf("🎞️  合成帧数: %d\n", result.FramesProcessed)
+
+	return nil
+}
+
+// handleConvert 处理格式转换命令
+func (app *EmbeddedApplication) handleConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	target := fs.String("to", "gif", "导出格式 (gif|apng)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fmt.Println("用法: webptools convert --to gif|apng <input.webp> <output>")
+		return cli.ArgCountError(fs, 2)
+	}
+
+	inputFile := fs.Arg(0)
+	outputFile := fs.Arg(1)
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	result, err := app.webpService.ConvertAnimation(ctx, inputFile, outputFile, domain.OutputFormat(strings.ToLower(*target)))
+	if err != nil {
+		app.logger.Error("转换失败", "error", err)
+		return err
+	}
+
+	fmt.Printf("✅ 转换完成！\n")
+	fmt.Printf("📊 文件大小: %s -> %s (%.1f%%)\n",
+		cli.FormatFileSize(result.OriginalSize),
+		cli.FormatFileSize(result.CompressedSize),
+		result.CompressionRatio)
+	fmt.Printf("⏱️  处理时间: %v\n", result.ProcessingTime)
+	fmt.Printf("🎞️  导出帧数: %d\n", result.FramesProcessed)
+
+	return nil
+}
+
+// handleFrames 处理帧提取命令
+func (app *EmbeddedApplication) handleFrames(args []string) error {
+	fs := flag.NewFlagSet("frames", flag.ContinueOnError)
+	format := fs.String("format", "png", "帧文件格式 (png|webp)")
+	fullCanvas := fs.Bool("full", false, "导出合成后的完整画布帧")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fmt.Println("用法: webptools frames [--format png|webp] [--full] <input.webp> <outdir>")
+		return cli.ArgCountError(fs, 2)
+	}
+
+	inputFile := fs.Arg(0)
+	outputDir := fs.Arg(1)
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	manifest, err := app.webpService.DecomposeAnimation(ctx, inputFile, outputDir, strings.ToLower(*format), *fullCanvas)
+	if err != nil {
+		app.logger.Error("帧提取失败", "error", err)
+		return err
+	}
+
+	fmt.Printf("✅ 帧提取完成！\n")
+	fmt.Printf("📐 画布大小: %dx%d\n", manifest.Width, manifest.Height)
+	fmt.Printf("🎞️  导出帧数: %d\n", len(manifest.Frames))
+	fmt.Printf("📋 帧清单: %s\n", filepath.Join(outputDir, service.FrameManifestFile))
+
+	return nil
+}
+
+// handleCompare 处理动画比较命令
+func (app *EmbeddedApplication) handleCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出比较结果")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fmt.Println("用法: webptools compare [--json] <a.webp> <b.webp>")
+		return cli.ArgCountError(fs, 2)
+	}
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	report, err := app.webpService.CompareAnimations(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		app.logger.Error("比较失败", "error", err)
+		return err
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化比较结果失败: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if report.Identical {
+		fmt.Printf("✅ 两个动画完全一致\n")
+	} else {
+		fmt.Printf("⚠️  两个动画存在差异\n")
+		for _, diff := range report.Differences {
+			fmt.Printf("  • %s\n", diff)
+		}
+	}
+	fmt.Printf("🔍 估计质量因子: %s / %s\n", formatQualityEstimate(report.QualityA), formatQualityEstimate(report.QualityB))
+
+	if len(report.Frames) > 0 {
+		fmt.Printf("\n%-6s %-14s %-10s %s\n", "帧", "时长(ms)", "PSNR", "SSIM")
+		for _, frame := range report.Frames {
+			fmt.Printf("%-6d %-14s %-10s %.4f\n",
+				frame.Index,
+				fmt.Sprintf("%d/%d", frame.DurationA, frame.DurationB),
+				fmt.Sprintf("%.2fdB", frame.PSNR),
+				frame.SSIM)
+		}
+		fmt.Printf("\n📈 整体相似度: %.2f%%\n", report.Similarity*100)
+	}
+
+	return nil
+}
+
+// handleVerify 处理动画完整性检查命令，发现错误级别的问题时返回错误
+func (app *EmbeddedApplication) handleVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出检查结果")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Println("用法: webptools verify [--json] <file.webp>")
+		return cli.ArgCountError(fs, 1)
+	}
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	report, err := app.webpService.CheckIntegrity(ctx, fs.Arg(0))
+	if err != nil {
+		app.logger.Error("完整性检查失败", "error", err)
+		return err
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化检查结果失败: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("📐 画布: %dx%d, 帧数: %d, 循环次数: %d\n", report.Width, report.Height, report.Frames, report.LoopCount)
+		for _, issue := range report.Issues {
+			mark := "⚠️ "
+			if issue.Severity == domain.SeverityError {
+				mark = "❌"
+			}
+			if issue.Frame > 0 {
+				fmt.Printf("%s 第%d帧 [%s] %s\n", mark, issue.Frame, issue.Code, issue.Message)
+			} else {
+				fmt.Printf("%s [%s] %s\n", mark, issue.Code, issue.Message)
+			}
+		}
+		if report.Healthy {
+			fmt.Printf("✅ 动画完整\n")
+		}
+	}
+
+	if !report.Healthy {
+		return fmt.Errorf("动画存在%d个问题", len(report.Issues))
+	}
+	return nil
+}
+
+// handleReplay 处理诊断包重放命令，原错误重现时以非零状态退出
+func (app *EmbeddedApplication) handleReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出重放结果")
+	output := fs.String("o", "", "保存重放输出的路径，默认写入临时目录并在结束后删除")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Println("用法: webptools replay [--json] [-o output.webp] <bundle.zip>")
+		return cli.ArgCountError(fs, 1)
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		dir, err := os.MkdirTemp("", "webp_replay_out")
+		if err != nil {
+			return fmt.Errorf("创建临时目录失败: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		outputPath = filepath.Join(dir, "replay.webp")
+	}
+
+	ctx, cancel := app.commandContext()
+	defer cancel()
+
+	report, err := app.webpService.ReplayDiagnosticsBundle(ctx, fs.Arg(0), outputPath)
+	if err != nil {
+		app.logger.Error("重放失败", "error", err)
+		return err
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化重放结果失败: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("📦 诊断包: %s\n", report.Bundle)
+		fmt.Printf("📋 原始错误: %s\n", report.OriginalError)
+		for _, diff := range report.VersionDiffs {
+			fmt.Printf("⚠️  %s 版本不同: 记录 %s, 本地 %s\n", diff.Tool, diff.Recorded, diff.Current)
+		}
+		switch {
+		case report.Reproduced:
+			fmt.Printf("❌ 已重现: %s\n", report.Error)
+		case report.Error != "":
+			fmt.Printf("⚠️  重放以不同的错误失败: %s\n", report.Error)
+		default:
+			fmt.Printf("✅ 重放成功，未能重现 (压缩率 %.1f%%)\n", report.Result.CompressionRatio)
+		}
+	}
+
+	if report.Reproduced {
+		return fmt.Errorf("已重现原任务的失败")
+	}
+	return nil
+}
+
+// handleStats 处理统计命令，目前支持export导出
+func (app *EmbeddedApplication) handleStats(args []string) error {
+	if len(args) < 1 || args[0] != "export" {
+		fmt.Println("用法: webptools stats export [--from 时间] [--to 时间] [--format csv|jsonl|parquet] [-o 输出文件] [stats.jsonl]")
+		return fmt.Errorf("未知的统计子命令")
+	}
+
+	fs := flag.NewFlagSet("stats export", flag.ContinueOnError)
+	from := fs.String("from", "", "只导出此时间及之后的记录，如 2024-01-01 或 RFC3339 时间")
+	to := fs.String("to", "", "只导出此时间之前的记录")
+	format := fs.String("format", service.StatsFormatCSV, "导出格式: csv、jsonl 或 parquet")
+	output := fs.String("o", "", "输出文件，默认写到标准输出")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		fmt.Println("用法: webptools stats export [--from 时间] [--to 时间] [--format csv|jsonl|parquet] [-o 输出文件] [stats.jsonl]")
+		return cli.ArgCountError(fs, 1)
+	}
+
+	statsPath := app.config.Processing.StatsFile
+	if fs.NArg() > 0 {
+		statsPath = fs.Arg(0)
+	}
+	if statsPath == "" {
+		fmt.Println("用法: webptools stats export [--from 时间] [--to 时间] [--format csv|jsonl|parquet] [-o 输出文件] [stats.jsonl]")
+		return fmt.Errorf("未指定统计文件")
+	}
+
+	fromTime, err := parseStatsTime(*from)
+	if err != nil {
+		return fmt.Errorf("无效的起始时间: %s", *from)
+	}
+	toTime, err := parseStatsTime(*to)
+	if err != nil {
+		return fmt.Errorf("无效的结束时间: %s", *to)
+	}
+	// 在创建输出文件前检查格式，避免留下空文件
+	if !service.IsValidStatsFormat(*format) {
+		fmt.Println("用法: webptools stats export [--from 时间] [--to 时间] [--format csv|jsonl|parquet] [-o 输出文件] [stats.jsonl]")
+		return fmt.Errorf("不支持的导出格式: %s，支持: csv、jsonl、parquet", *format)
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := app.webpService.ExportStats(statsPath, fromTime, toTime, *format, w)
+	if err != nil {
+		app.logger.Error("导出统计失败", "error", err)
+		return err
+	}
+	if *output != "" {
+		fmt.Printf("✅ 已导出 %d 条记录到 %s\n", count, *output)
+	}
+	return nil
+}
+
+// parseStatsTime 解析日期(按本地时区)或RFC3339时间，空字符串表示不限制
+func parseStatsTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// handleExperiments 处理A/B实验汇总命令
+func (app *EmbeddedApplication) handleExperiments(args []string) error {
+	fs := flag.NewFlagSet("experiments", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出汇总结果")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		fmt.Println("用法: webptools experiments [--json] [experiments.jsonl]")
+		return cli.ArgCountError(fs, 1)
+	}
+
+	logPath := app.config.Processing.ExperimentLog
+	if fs.NArg() > 0 {
+		logPath = fs.Arg(0)
+	}
+	if logPath == "" {
+		fmt.Println("用法: webptools experiments [--json] [experiments.jsonl]")
+		return fmt.Errorf("未指定实验记录文件")
+	}
+
+	report, err := app.webpService.ExperimentReport(logPath)
+	if err != nil {
+		app.logger.Error("汇总实验记录失败", "error", err)
+		return err
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化汇总结果失败: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("🧪 实验策略: %s\n", report.Strategy)
+	fmt.Printf("\n%-10s %-8s %-8s %-12s %s\n", "分组", "任务数", "失败数", "平均压缩率", "平均耗时")
+	for _, group := range report.Groups {
+		fmt.Printf("%-10s %-8d %-8d %-12s %v\n",
+			group.Group,
+			group.Jobs,
+			group.Failures,
+			fmt.Sprintf("%.1f%%", group.AvgRatio),
+			group.AvgDuration.Round(time.Millisecond))
+	}
+
+	return nil
+}
+
+// formatQualityEstimate 格式化估计的质量因子，无法估计时显示为无损或未知
+func formatQualityEstimate(quality int) string {
+	if quality < 0 {
+		return "无损/未知"
+	}
+	return strconv.Itoa(quality)
+}
+
+// parseDurationList 解析以逗号分隔的毫秒时长列表
+func parseDurationList(value string) ([]time.Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	durations := make([]time.Duration, 0, len(parts))
+	for _, part := range parts {
+		ms, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("无效的持续时间: %s", part)
+		}
+		durations = append(durations, time.Duration(ms)*time.Millisecond)
+	}
+	return durations, nil
+}
+
 // showUsage 显示使用说明
 func (app *EmbeddedApplication) showUsage() {
 	fmt.Printf(`WebP工具集 v%s (嵌入版) - 内置所有WebP工具
@@ -314,8 +854,16 @@ func (app *EmbeddedApplication) showUsage() {
 🎯 主要命令:
   compress    压缩WebP动画
   info        显示WebP文件信息
+  compose     将图像序列合成为WebP动画
+  convert     将WebP动画导出为GIF/APNG
+  frames      逐帧导出动画并生成帧清单
+  compare     比较两个WebP动画的逐帧差异
+  experiments 汇总A/B实验各分组的压缩指标
+  verify      检查动画完整性并输出健康报告
+  replay      按诊断包在本地重放失败的压缩任务
+  stats       导出压缩统计(CSV/JSON Lines/Parquet)供离线分析
   help        显示详细帮助
-  version     显示版本信息
+  version     显示版本信息(--verbose显示完整构建信息)
 
 💡 快速开始:
   webptools compress input.webp 40 output.webp
@@ -340,15 +888,59 @@ func (app *EmbeddedApplication) showDetailedHelp() {
 🎯 主要功能:
 
 1. compress/压缩 - 压缩WebP动画
-   用法: webptools compress <input.webp> <quality[0-100]> <output.webp>
+   用法: webptools compress [选项] <input.webp> <quality[0-100]> <output.webp>
    示例: webptools compress animation.webp 40 compressed.webp
-
+   选项:
+%s
 2. info/信息 - 显示WebP文件详细信息
    用法: webptools info <input.webp>
    示例: webptools info animation.webp
 
+3. compose/合成 - 将PNG/JPEG图像序列合成为WebP动画
+   用法: webptools compose [-d 100] [-durations 100,80,...] [-loop 0] [--near-lossless N] [--mixed] <frames_dir|manifest.json> <quality[0-100]> <output.webp>
+   示例: webptools compose -d 80 frames/ 75 animation.webp
+   清单: {"loop": 0, "frames": [{"file": "a.png", "duration": 100}]}，显式指定的 -loop 优先于清单中的 loop
+
+4. convert/转换 - 将WebP动画导出为GIF或APNG
+   用法: webptools convert --to gif|apng <input.webp> <output>
+   示例: webptools convert --to gif animation.webp animation.gif
+
+5. frames/拆帧 - 逐帧导出动画，并生成包含偏移、时长、处理与混合方式的manifest.json
+   用法: webptools frames [--format png|webp] [--full] <input.webp> <outdir>
+   示例: webptools frames --full animation.webp frames/
+   说明: --full 导出完整画布帧，其清单可直接用于 compose 重新合成
+
+6. compare/比较 - 比较两个WebP动画，输出逐帧差异和整体相似度
+   用法: webptools compare [--json] <a.webp> <b.webp>
+   示例: webptools compare original.webp compressed.webp
+   说明: 基于anim_diff和webp_quality，相似度为逐帧SSIM的平均值
+
+7. experiments/实验 - 汇总A/B实验记录，对比对照组与实验组的压缩率和耗时
+   用法: webptools experiments [--json] [experiments.jsonl]
+   说明: 由WEBP_EXPERIMENT_STRATEGY和WEBP_EXPERIMENT_PERCENT开启实验，默认读取WEBP_EXPERIMENT_LOG
+
+8. verify/校验 - 检查动画完整性，逐帧解码并核对时长、偏移和循环次数
+   用法: webptools verify [--json] <file.webp>
+   示例: webptools verify --json asset.webp
+   说明: 发现错误级别的问题时以非零状态退出，便于在发布前批量检查第三方素材
+
+9. replay/重放 - 按诊断包记录的输入、设置和处理配置在本地重新执行失败的压缩任务
+   用法: webptools replay [--json] [-o output.webp] <bundle.zip>
+   示例: webptools replay webp_diagnostics_20240101_120000_123456789.zip
+   说明: 诊断包由WEBP_DIAGNOSTICS_DIR开启，重放时列出与记录不同的工具版本，原错误重现时以非零状态退出
+
+10. stats/统计 - 导出WEBP_STATS_FILE记录的压缩统计
+   用法: webptools stats export [--from 时间] [--to 时间] [--format csv|jsonl|parquet] [-o 输出文件] [stats.jsonl]
+   示例: webptools stats export --from 2024-01-01 --to 2024-02-01 -o january.csv
+   说明: 时间可写日期或RFC3339格式，CSV每行包含输入特征、主要设置和压缩结果；
+         Parquet的列与CSV相同，数值列保留类型，失败任务的结果列为空，二进制输出建议用 -o 写入文件
+
+11. version/版本 - 显示版本信息
+   用法: webptools version [--verbose]
+   说明: --verbose 输出git提交、构建时间、Go版本和嵌入工具包版本，提交问题报告时请附上
+
 🛠️ 内置工具 (%d个):
-`, app.config.App.Version, len(embeddedTools))
+`, app.config.App.Version, cli.CompressOptionsHelp("     "), len(embeddedTools))
 
 	for _, tool := range embeddedTools {
 		fmt.Printf("  • %-15s - %s\n", tool.name, tool.desc)
@@ -356,12 +948,7 @@ func (app *EmbeddedApplication) showDetailedHelp() {
 
 	fmt.Printf(`
 🔧 环境变量配置:
-  WEBP_LOG_LEVEL       日志级别 (debug|info|warn|error)
-  WEBP_TEMP_DIR        临时目录路径
-  WEBP_MAX_CONCURRENCY 最大并发数
-  WEBP_TIMEOUT         操作超时时间
-  WEBP_MAX_FILE_SIZE   最大文件大小限制
-
+%s
 💡 使用提示:
   • 压缩质量: 0-100 (0=最小文件,100=最高质量)
   • 建议质量: 30-50 获得最佳压缩效果
@@ -369,34 +956,17 @@ func (app *EmbeddedApplication) showDetailedHelp() {
   • 工具会自动提取到临时目录并在程序结束时清理
 
 更多信息请访问: https://github.com/webmproject/libwebp
-`)
-}
-
-// formatFileSize 格式化文件大小
-func formatFileSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+`, cli.EnvHelp)
 }
 
 // main 主函数
 func main() {
 	// 创建嵌入式应用程序
-	app, err := NewEmbeddedApplication()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ 初始化失败: %v\n", err)
-		os.Exit(1)
-	}
+	app := NewEmbeddedApplication()
 
 	// 运行应用程序
-	if err := app.Run(os.Args); err != nil {
+	// -h/--help已由flag包输出用法，视为正常退出
+	if err := app.Run(os.Args); err != nil && !errors.Is(err, flag.ErrHelp) {
 		fmt.Fprintf(os.Stderr, "❌ 运行失败: %v\n", err)
 		os.Exit(1)
 	}
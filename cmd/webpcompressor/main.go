@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
+	"webpcompressor/internal/buildinfo"
+	"webpcompressor/internal/cli"
 	"webpcompressor/internal/config"
-	"webpcompressor/internal/domain"
 	"webpcompressor/internal/infrastructure"
 	"webpcompressor/internal/service"
 	"webpcompressor/pkg/logger"
@@ -22,14 +27,12 @@ type Application struct {
 	tempDirManager *infrastructure.TempDirManager
 }
 
-// NewApplication 创建应用程序实例
-func NewApplication() (*Application, error) {
+// NewApplication 创建应用程序实例，只加载配置和日志；工具和服务在需要时由initServices创建，
+// 这样工具缺失或配置有误时--version仍能输出构建信息
+func NewApplication() *Application {
 	// 加载配置
 	cfg := config.DefaultConfig()
 	cfg.LoadFromEnv()
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("配置验证失败: %w", err)
-	}
 
 	// 初始化日志
 	appLogger, err := logger.NewLogger(&cfg.Logging)
@@ -38,57 +41,95 @@ func NewApplication() (*Application, error) {
 		appLogger = logger.NewDefaultLogger()
 		appLogger.Warn("使用默认日志配置", "error", err)
 	}
+	appLogger.Info("启动", buildinfo.Get(cfg.App.Version).LogFields()...)
+
+	return &Application{
+		config: cfg,
+		logger: appLogger,
+	}
+}
+
+// initServices 验证配置和工具可用性，创建压缩服务
+func (app *Application) initServices() error {
+	if err := app.config.Validate(); err != nil {
+		return fmt.Errorf("配置验证失败: %w", err)
+	}
 
 	// 创建工厂
-	toolFactory := infrastructure.NewToolExecutorFactory(cfg, appLogger)
-	fileFactory := infrastructure.NewFileManagerFactory(cfg, appLogger)
+	toolFactory := infrastructure.NewToolExecutorFactory(app.config, app.logger)
+	fileFactory := infrastructure.NewFileManagerFactory(app.config, app.logger)
 
 	// 创建基础组件
-	toolExecutor := toolFactory.CreateExecutor(cfg.Tools.UseEmbedded, "")
+	toolExecutor := toolFactory.CreateExecutor(app.config.Tools.UseEmbedded, "")
 	fileManager := fileFactory.CreateFileManager(true) // 使用安全模式
 
 	// 验证工具可用性
 	if err := toolFactory.ValidateTools(toolExecutor); err != nil {
-		return nil, fmt.Errorf("工具验证失败: %w", err)
+		return fmt.Errorf("工具验证失败: %w", err)
 	}
 
 	// 创建临时目录管理器
-	tempDirManager := infrastructure.NewTempDirManager(fileManager, appLogger)
+	app.tempDirManager = infrastructure.NewTempDirManager(fileManager, app.logger)
 
 	// 创建服务
-	webpService := service.NewWebPService(cfg, toolExecutor, fileManager, appLogger)
+	app.webpService = service.NewWebPService(app.config, toolExecutor, fileManager, app.logger)
+	return nil
+}
 
-	return &Application{
-		config:         cfg,
-		logger:         appLogger,
-		webpService:    webpService,
-		tempDirManager: tempDirManager,
-	}, nil
+// commandContext 返回带超时的命令上下文，收到中断或终止信号时同样取消，
+// 正在运行的工具子进程随之终止，命令返回后临时目录得到清理
+func (app *Application) commandContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(ctx, app.config.App.Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
 }
 
 // Run 运行应用程序
 func (app *Application) Run(args []string) error {
-	// 确保清理临时文件
-	defer app.tempDirManager.CleanupAll()
-
 	// 解析命令行参数
-	if len(args) < 4 {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	compressFlags := cli.NewCompressFlags(fs)
+	showVersion := fs.Bool("version", false, "显示版本信息，配合--verbose显示完整构建信息")
+	fs.Usage = app.showUsage
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *showVersion {
+		app.printVersion(*compressFlags.Verbose)
+		return nil
+	}
+
+	if fs.NArg() != 3 {
+		// 放在位置参数之后的选项不会被解析，报错而不是静默忽略
 		app.showUsage()
-		return fmt.Errorf("参数不足")
+		return cli.ArgCountError(fs, 3)
 	}
 
-	inputFile := args[1]
-	quality, err := strconv.Atoi(args[2])
+	inputFile := fs.Arg(0)
+	quality, err := strconv.Atoi(fs.Arg(1))
 	if err != nil {
-		return fmt.Errorf("无效的质量参数: %s", args[2])
+		return fmt.Errorf("无效的质量参数: %s", fs.Arg(1))
+	}
+	outputFile := fs.Arg(2)
+
+	if err := app.initServices(); err != nil {
+		return err
 	}
-	outputFile := args[3]
+	// 确保清理临时文件
+	defer app.tempDirManager.CleanupAll()
 
 	// 创建压缩配置
-	compressionConfig := domain.DefaultCompressionConfig(quality)
+	compressionConfig, err := compressFlags.Config(quality)
+	if err != nil {
+		return err
+	}
 
 	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), app.config.App.Timeout)
+	ctx, cancel := app.commandContext()
 	defer cancel()
 
 	// 记录开始
@@ -118,70 +159,62 @@ func (app *Application) Run(args []string) error {
 	)
 
 	// 显示用户友好的结果
-	fmt.Printf("✅ 压缩完成！\n")
-	fmt.Printf("📊 压缩效果: %s -> %s (%.1f%%)\n",
-		formatFileSize(result.OriginalSize),
-		formatFileSize(result.CompressedSize),
-		result.CompressionRatio)
-	fmt.Printf("⏱️  处理时间: %v\n", result.ProcessingTime)
-	fmt.Printf("🎞️  处理帧数: %d\n", result.FramesProcessed)
+	cli.PrintCompressResult(result, compressionConfig, app.config.Processing.ExperimentStrategy, *compressFlags.Verbose)
 
 	return nil
 }
 
+// printVersion 输出版本信息，verbose时附带完整构建信息便于对应问题报告
+func (app *Application) printVersion(verbose bool) {
+	info := buildinfo.Get(app.config.App.Version)
+	if !verbose {
+		fmt.Printf("WebP Compressor v%s (%s)\n", info.Version, info.Short())
+		return
+	}
+	fmt.Printf("WebP Compressor v%s\n", info.Version)
+	fmt.Printf("  提交:     %s\n", info.Commit)
+	fmt.Printf("  构建时间: %s\n", info.Date)
+	fmt.Printf("  Go版本:   %s\n", info.GoVersion)
+	fmt.Printf("  平台:     %s\n", info.Platform)
+	fmt.Printf("  工具包:   %s\n", info.ToolsVersion)
+}
+
 // showUsage 显示使用说明
 func (app *Application) showUsage() {
 	fmt.Printf(`WebP Compressor v%s - 高性能WebP动画压缩工具
 
-用法: %s <input.webp> <quality[0-100]> <output.webp>
+用法: %s [选项] <input.webp> <quality[0-100]> <output.webp>
 
 参数:
   input.webp    输入的WebP动画文件
   quality       压缩质量(0-100)，建议30-50获得更好的压缩效果
   output.webp   输出的压缩文件
 
+选项:
+%s  --version          显示版本信息，配合--verbose显示提交、构建时间、Go版本和工具包版本
+
 示例:
   %s animation.webp 40 compressed.webp
 
 环境变量配置:
-  WEBP_LOG_LEVEL       日志级别 (debug|info|warn|error)
-  WEBP_TEMP_DIR        临时目录路径
-  WEBP_MAX_CONCURRENCY 最大并发数
-  WEBP_TIMEOUT         操作超时时间
-  WEBP_MAX_FILE_SIZE   最大文件大小限制
-
+%s
 更多信息请访问: https://github.com/webmproject/libwebp
 `,
 		app.config.App.Version,
 		os.Args[0],
-		os.Args[0])
-}
-
-// formatFileSize 格式化文件大小
-func formatFileSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+		cli.CompressOptionsHelp("  "),
+		os.Args[0],
+		cli.EnvHelp)
 }
 
 // main 主函数
 func main() {
 	// 创建应用程序
-	app, err := NewApplication()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ 初始化失败: %v\n", err)
-		os.Exit(1)
-	}
+	app := NewApplication()
 
 	// 运行应用程序
-	if err := app.Run(os.Args); err != nil {
+	// -h/--help已由flag包输出用法，视为正常退出
+	if err := app.Run(os.Args); err != nil && !errors.Is(err, flag.ErrHelp) {
 		fmt.Fprintf(os.Stderr, "❌ 运行失败: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/faketools"
+)
+
+// 验收测试构建命令行程序并作为子进程运行。默认使用faketools替身工具，
+// 设置WEBP_E2E_TOOLS为真实libwebp工具目录时改用真实工具。
+//
+// cmd/embedded不在验收范围内：它通过go:embed打包embedded/*.exe，仓库中没有这些工具二进制，无法构建
+var (
+	binaryPath string
+	toolsPath  string
+)
+
+func TestMain(m *testing.M) {
+	if code, ok := faketools.Main(); ok {
+		os.Exit(code)
+	}
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "webpcompressor_e2e")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建临时目录失败: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	binaryPath = filepath.Join(dir, "webpcompressor")
+	if runtime.GOOS == "windows" {
+		binaryPath += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", binaryPath, ".")
+	if output, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "构建失败: %v\n%s", err, output)
+		return 1
+	}
+
+	toolsPath = os.Getenv("WEBP_E2E_TOOLS")
+	if toolsPath == "" {
+		toolsPath = filepath.Join(dir, "tools")
+		if err := os.Mkdir(toolsPath, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "创建工具目录失败: %v\n", err)
+			return 1
+		}
+		if err := faketools.Install(toolsPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	return m.Run()
+}
+
+// runCLI 运行命令行程序，返回退出码和合并后的输出
+func runCLI(t *testing.T, env []string, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(binaryPath, args...)
+	cmd.Env = append(os.Environ(),
+		"WEBP_TOOLS_PATH="+toolsPath,
+		"WEBP_TEMP_DIR="+t.TempDir(),
+		"WEBP_LOG_FILE="+filepath.Join(t.TempDir(), "webpcompressor.log"),
+	)
+	cmd.Env = append(cmd.Env, env...)
+	output, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), string(output)
+	}
+	if err != nil {
+		t.Fatalf("运行%s失败: %v", binaryPath, err)
+	}
+	return 0, string(output)
+}
+
+// fixture 返回testdata/input中样例文件的绝对路径，安全模式拒绝含..的相对路径
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("..", "..", "testdata", "input", name))
+	if err != nil {
+		t.Fatalf("解析样例路径失败: %v", err)
+	}
+	return path
+}
+
+// countFrames 校验RIFF/WEBP容器并返回ANMF帧数
+func countFrames(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取输出失败: %v", err)
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		t.Fatalf("%s不是WebP文件", path)
+	}
+	if size := int(binary.LittleEndian.Uint32(data[4:8])); size+8 != len(data) {
+		t.Fatalf("RIFF大小%d与文件大小%d不符", size+8, len(data))
+	}
+
+	frames := 0
+	for pos := 12; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if string(data[pos:pos+4]) == "ANMF" {
+			frames++
+		}
+		pos += 8 + size + size%2
+	}
+	return frames
+}
+
+func TestCLI_Compress(t *testing.T) {
+	input := fixture(t, "lianzhixin_1.webp")
+	output := filepath.Join(t.TempDir(), "out.webp")
+	statsFile := filepath.Join(t.TempDir(), "stats.jsonl")
+
+	// strict模式下输出校验(含webpinfo码流检查)失败会使命令失败
+	env := []string{"WEBP_STATS_FILE=" + statsFile, "WEBP_VERIFY_OUTPUT=strict"}
+	code, out := runCLI(t, env, input, "40", output)
+	if code != 0 {
+		t.Fatalf("退出码为%d，期望0\n%s", code, out)
+	}
+
+	if got, want := countFrames(t, output), countFrames(t, input); got != want {
+		t.Errorf("输出有%d帧，期望%d帧", got, want)
+	}
+
+	file, err := os.Open(statsFile)
+	if err != nil {
+		t.Fatalf("打开统计文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("统计记录不是JSON: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 {
+		t.Fatalf("期望1条统计记录，实际%d条", len(records))
+	}
+	record := records[0]
+	if record["input"] != input || record["error"] != nil {
+		t.Errorf("统计记录的输入或错误不符: %v", record)
+	}
+	result, ok := record["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("统计记录缺少result: %v", record)
+	}
+	if result["frames_processed"] != float64(120) {
+		t.Errorf("统计记录的处理帧数为%v，期望120", result["frames_processed"])
+	}
+}
+
+func TestCLI_InvalidArguments(t *testing.T) {
+	input := fixture(t, "lianzhixin_1.webp")
+	output := filepath.Join(t.TempDir(), "out.webp")
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"参数不足", []string{input, "40"}},
+		{"无效质量", []string{input, "abc", output}},
+		{"未知选项", []string{"--no-such-flag", input, "40", output}},
+		{"选项在位置参数之后", []string{input, "40", output, "--dedup"}},
+		{"输入不存在", []string{filepath.Join(t.TempDir(), "missing.webp"), "40", output}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := runCLI(t, nil, tt.args...)
+			if code != 1 {
+				t.Errorf("退出码为%d，期望1\n%s", code, out)
+			}
+			if _, err := os.Stat(output); err == nil {
+				t.Errorf("失败时不应生成输出文件")
+			}
+		})
+	}
+}
+
+func TestCLI_Version(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want string
+	}{
+		{"工具可用", nil, []string{"--version"}, "WebP Compressor v"},
+		// 版本信息用于问题报告，安装不完整时同样要能输出
+		{"工具缺失", []string{"WEBP_TOOLS_PATH=" + t.TempDir()}, []string{"--version"}, "WebP Compressor v"},
+		{"工具缺失详细信息", []string{"WEBP_TOOLS_PATH=" + t.TempDir()}, []string{"--version", "--verbose"}, "构建时间"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := runCLI(t, tt.env, tt.args...)
+			if code != 0 || !strings.Contains(out, tt.want) {
+				t.Errorf("退出码%d，输出:\n%s", code, out)
+			}
+		})
+	}
+}
+
+func TestCLI_Help(t *testing.T) {
+	for _, arg := range []string{"-h", "--help"} {
+		code, out := runCLI(t, nil, arg)
+		if code != 0 || !strings.Contains(out, "用法") {
+			t.Errorf("%s: 退出码%d，输出:\n%s", arg, code, out)
+		}
+	}
+}
+
+func TestCLI_ToolsMissing(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.webp")
+	// PATH同样指向空目录，避免找到系统安装的工具
+	env := []string{"WEBP_TOOLS_PATH=" + t.TempDir(), "PATH=" + t.TempDir()}
+	code, out := runCLI(t, env, fixture(t, "lianzhixin_1.webp"), "40", output)
+	if code != 1 || !strings.Contains(out, "缺少必需的工具") {
+		t.Errorf("退出码%d，输出:\n%s", code, out)
+	}
+}
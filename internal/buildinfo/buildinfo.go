@@ -0,0 +1,102 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// 以下变量在构建时通过 -ldflags "-X webpcompressor/internal/buildinfo.Commit=..." 注入
+var (
+	// Commit 构建所用的git提交
+	Commit string
+	// Date 构建时间
+	Date string
+	// ToolsVersion 嵌入工具包(libwebp)的版本
+	ToolsVersion string
+)
+
+const unknown = "unknown"
+
+// Info 构建信息，用于把问题报告对应到具体的构建
+type Info struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit"`
+	Date         string `json:"date"`
+	GoVersion    string `json:"go_version"`
+	Platform     string `json:"platform"`
+	ToolsVersion string `json:"tools_version"`
+}
+
+// Get 返回当前程序的构建信息
+// 未通过ldflags注入提交和时间时，使用Go工具链记录的版本控制信息
+func Get(version string) Info {
+	info := Info{
+		Version:      version,
+		Commit:       Commit,
+		Date:         Date,
+		GoVersion:    runtime.Version(),
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		ToolsVersion: ToolsVersion,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok && (info.Commit == "" || info.Date == "") {
+		modified := false
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.Date == "" {
+		info.Date = unknown
+	}
+	if info.ToolsVersion == "" {
+		info.ToolsVersion = unknown
+	}
+	return info
+}
+
+// Short 返回短格式提交号
+func (i Info) Short() string {
+	commit := strings.TrimSuffix(i.Commit, "-dirty")
+	if len(commit) > 12 {
+		return commit[:12] + i.Commit[len(commit):]
+	}
+	return i.Commit
+}
+
+// String 返回单行构建信息
+func (i Info) String() string {
+	return fmt.Sprintf("v%s (commit %s, built %s, %s %s, tools %s)",
+		i.Version, i.Short(), i.Date, i.GoVersion, i.Platform, i.ToolsVersion)
+}
+
+// LogFields 返回用于结构化日志的键值对
+func (i Info) LogFields() []interface{} {
+	return []interface{}{
+		"version", i.Version,
+		"commit", i.Commit,
+		"build_date", i.Date,
+		"go_version", i.GoVersion,
+		"platform", i.Platform,
+		"tools_version", i.ToolsVersion,
+	}
+}
@@ -0,0 +1,72 @@
+// Package cli 标准版和嵌入版命令行程序共用的选项注册、参数检查和结果输出
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// ArgCountError 位置参数个数不符时的错误；多出的参数通常是放在位置参数之后的选项，flag包不会解析它们
+func ArgCountError(fs *flag.FlagSet, want int) error {
+	if fs.NArg() < want {
+		return fmt.Errorf("参数不足")
+	}
+	return fmt.Errorf("多余的参数: %s（选项必须放在位置参数之前）", strings.Join(fs.Args()[want:], " "))
+}
+
+// IsFlagSet 判断命令行是否显式设置了某个选项
+func IsFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// FormatFileSize 格式化文件大小
+func FormatFileSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// EnvHelp 两个命令行程序共同支持的环境变量说明，每行缩进两个空格
+const EnvHelp = `  WEBP_LOG_LEVEL       日志级别 (debug|info|warn|error)
+  WEBP_TEMP_DIR        临时目录路径
+  WEBP_MAX_CONCURRENCY 最大并发数
+  WEBP_EXTRACT_WORKERS 提取阶段并发数
+  WEBP_COMPRESS_WORKERS 压缩阶段并发数
+  WEBP_FRAME_RETRIES    单帧压缩失败重试次数
+  WEBP_DIAGNOSTICS_DIR  组装失败时写入诊断包的目录
+  WEBP_VERIFY_OUTPUT   输出校验模式 (off|warn|strict)
+  WEBP_FFMPEG_CODEC    ffmpeg编码后端使用的编码器，默认libwebp
+  WEBP_TOOL_RETRIES    工具暂时性失败(文件被锁定、内存不足)的重试次数，默认2
+  WEBP_TOOL_RETRY_BACKOFF 首次重试前等待的毫秒数，之后每次翻倍，默认200
+  WEBP_CPU_CLASS       机器类别 (laptop|ci|server)，默认自动检测
+  WEBP_HISTORY_FILE    压缩历史记录文件，用于按相似输入学习最佳设置
+  WEBP_EXPERIMENT_STRATEGY A/B实验组策略 (img2webp|mixed|dedup|best)
+  WEBP_EXPERIMENT_PERCENT  进入实验组的任务比例(0-100)
+  WEBP_EXPERIMENT_LOG      实验指标记录文件(JSON Lines)
+  WEBP_STATS_FILE      压缩统计文件(JSON Lines)，记录每次压缩的输入特征、设置和结果，可导入SQLite/ClickHouse分析
+  WEBP_CACHE_DIR       压缩结果缓存目录，相同输入和设置直接复用之前的输出
+  WEBP_UNSUPPORTED_POLICY 输入含有无法重现的特性(如分片)时: reject(默认，报错) 或 passthrough(原样输出)
+  WEBP_FRAME_BOUNDS    帧区域超出画布时: fix(默认，移回画布内) 或 fail(报错)
+  WEBP_MAX_OUTPUT_RATIO 预计输出超过输入大小的此倍数时提前中止(如3)，默认不限制
+  WEBP_MAX_FRAMES      输入帧数上限，默认5000，0表示不限制
+  WEBP_MAX_CANVAS_PIXELS 输入画布像素数上限，默认8192x8192，0表示不限制
+  WEBP_MAX_DECODED_MB  全部帧解码后的总大小上限(MB)，默认16384，0表示不限制
+  WEBP_MAX_MEMORY      内存阈值(MB)，本进程超过后暂停派发新的帧任务；不统计工具子进程，不是硬上限
+  WEBP_CPU_LIMIT       CPU使用率阈值(1-100)，超过后暂停派发新的帧任务；Windows下不统计工具子进程
+  WEBP_TIMEOUT         操作超时时间
+  WEBP_MAX_FILE_SIZE   最大文件大小限制
+`
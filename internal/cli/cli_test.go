@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func newTestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("compress", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestCompressFlags_Config(t *testing.T) {
+	fs := newTestFlagSet()
+	flags := NewCompressFlags(fs)
+	args := []string{"--dedup", "--frames", "10-60", "--trim-end", "4s", "--priority", "LOW",
+		"--auto-quality", "--min-ssim", "0.9", "--effort", "5", "--progress", "--verbose"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	config, err := flags.Config(40)
+	if err != nil {
+		t.Fatalf("Config failed: %v", err)
+	}
+	if config.Quality != 40 || !config.Deduplicate || config.Effort != 5 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if config.FrameStart != 10 || config.FrameEnd != 60 || config.TrimEnd != 4*time.Second {
+		t.Errorf("Expected frames 10-60 and trim end 4s, got %d-%d/%v", config.FrameStart, config.FrameEnd, config.TrimEnd)
+	}
+	if config.Priority != "low" {
+		t.Errorf("Expected lower-cased priority, got %q", config.Priority)
+	}
+	if !config.AutoQuality || config.MinSSIM != 0.9 {
+		t.Errorf("Expected auto quality with SSIM 0.9, got %v/%v", config.AutoQuality, config.MinSSIM)
+	}
+	if config.Progress == nil || !*flags.Verbose {
+		t.Error("Expected progress callback and verbose output")
+	}
+	if config.Pipeline != domain.PipelineWebpmux || config.Encoder != domain.EncoderCwebp {
+		t.Errorf("Expected default pipeline and encoder, got %q/%q", config.Pipeline, config.Encoder)
+	}
+}
+
+func TestCompressFlags_ConfigInvalidFrameRange(t *testing.T) {
+	fs := newTestFlagSet()
+	flags := NewCompressFlags(fs)
+	if err := fs.Parse([]string{"--frames", "60-10"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := flags.Config(40); err == nil {
+		t.Error("Expected error for reversed frame range")
+	}
+}
+
+func TestCompressOptionsHelp_CoversFlags(t *testing.T) {
+	fs := newTestFlagSet()
+	NewCompressFlags(fs)
+	help := CompressOptionsHelp("  ")
+	fs.VisitAll(func(f *flag.Flag) {
+		if !strings.Contains(help, "  --"+f.Name+" ") {
+			t.Errorf("Option --%s missing from help", f.Name)
+		}
+	})
+}
+
+func TestArgCountError(t *testing.T) {
+	fs := newTestFlagSet()
+	if err := fs.Parse([]string{"in.webp", "40", "out.webp", "--verbose"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := ArgCountError(fs, 3); err == nil || !strings.Contains(err.Error(), "--verbose") {
+		t.Errorf("Expected extra argument error naming --verbose, got %v", err)
+	}
+	if err := ArgCountError(fs, 5); err == nil || err.Error() != "参数不足" {
+		t.Errorf("Expected missing argument error, got %v", err)
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+	}
+	for _, tt := range tests {
+		if got := FormatFileSize(tt.bytes); got != tt.expected {
+			t.Errorf("FormatFileSize(%d) = %q, want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
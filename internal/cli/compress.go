@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// CompressFlags 压缩命令的选项
+type CompressFlags struct {
+	Verbose *bool // 输出逐帧压缩统计，标准版同时用于--version的详细信息
+
+	maxFPS          *float64
+	dropEveryN      *int
+	dedup           *bool
+	frameRange      *string
+	trimStart       *time.Duration
+	trimEnd         *time.Duration
+	autoQuality     *bool
+	minSSIM         *float64
+	minPSNR         *float64
+	qualityReport   *bool
+	gifEstimate     *bool
+	nearLossless    *int
+	mixed           *bool
+	pipeline        *string
+	best            *bool
+	showProgress    *bool
+	priority        *string
+	encoder         *string
+	deadline        *time.Duration
+	continueOnError *bool
+	effort          *int
+	learn           *string
+	recompressAbove *int64
+	fallback        *bool
+}
+
+// NewCompressFlags 在fs上注册压缩选项
+func NewCompressFlags(fs *flag.FlagSet) *CompressFlags {
+	return &CompressFlags{
+		maxFPS:          fs.Float64("max-fps", 0, "最大帧率，超出部分的帧被丢弃并把时长并入前一帧"),
+		dropEveryN:      fs.Int("drop-every-n", 0, "每N帧丢弃一帧"),
+		dedup:           fs.Bool("dedup", false, "合并画面相同的连续帧"),
+		frameRange:      fs.String("frames", "", "只压缩指定帧范围，如 10-60"),
+		trimStart:       fs.Duration("trim-start", 0, "丢弃在此时间点之前开始的帧，如 1.5s"),
+		trimEnd:         fs.Duration("trim-end", 0, "丢弃在此时间点及之后开始的帧，如 4s"),
+		autoQuality:     fs.Bool("auto-quality", false, "逐帧搜索满足失真下限的最低质量，quality作为上限"),
+		minSSIM:         fs.Float64("min-ssim", 0.95, "自动质量的SSIM下限(0-1)，0表示不限制"),
+		minPSNR:         fs.Float64("min-psnr", 0, "自动质量的PSNR下限(dB)，0表示不限制"),
+		qualityReport:   fs.Bool("quality-report", false, "逐帧测量PSNR/SSIM并输出画质报告"),
+		gifEstimate:     fs.Bool("gif-estimate", false, "抽样编码部分帧，估算等价GIF动画的大小"),
+		nearLossless:    fs.Int("near-lossless", 0, "近无损预处理级别(1-100，越小压缩越强)，0表示不启用"),
+		mixed:           fs.Bool("mixed", false, "每帧分别尝试有损和无损编码，保留较小的结果"),
+		pipeline:        fs.String("pipeline", domain.PipelineWebpmux, "处理管线: webpmux 或 img2webp"),
+		best:            fs.Bool("best", false, "两种处理管线都尝试，保留较小的结果"),
+		Verbose:         fs.Bool("verbose", false, "输出逐帧压缩统计"),
+		showProgress:    fs.Bool("progress", false, "在标准错误输出各阶段的实时进度"),
+		priority:        fs.String("priority", "", "工具子进程优先级: normal、low 或 idle"),
+		encoder:         fs.String("encoder", domain.EncoderCwebp, "逐帧编码后端: cwebp 或 ffmpeg"),
+		deadline:        fs.Duration("deadline", 0, "目标处理时长(如30s)，按试编码耗时选择来得及的最高压缩方法"),
+		continueOnError: fs.Bool("continue-on-error", false, "丢弃处理失败的帧并继续，失败帧的时长并入前一帧"),
+		effort:          fs.Int("effort", 0, "努力程度(1-9)，按机器类别统一设置压缩方法、遍数和并发数"),
+		learn:           fs.String("learn", "", "使用相似输入的历史最佳设置: suggest 或 apply"),
+		recompressAbove: fs.Int64("recompress-above", 0, "只重新压缩大于此字节数的帧，较小的帧保留原始数据"),
+		fallback:        fs.Bool("fallback", false, "处理失败时依次尝试另一管线、GIF往返和原样复制"),
+	}
+}
+
+// Config 按解析后的选项创建压缩配置
+func (f *CompressFlags) Config(quality int) (*domain.CompressionConfig, error) {
+	config := domain.DefaultCompressionConfig(quality)
+	config.MaxFPS = *f.maxFPS
+	config.DropEveryN = *f.dropEveryN
+	config.Deduplicate = *f.dedup
+	config.TrimStart = *f.trimStart
+	config.TrimEnd = *f.trimEnd
+	config.QualityReport = *f.qualityReport
+	config.GIFEstimate = *f.gifEstimate
+	config.NearLossless = *f.nearLossless
+	config.Mixed = *f.mixed
+	config.Pipeline = *f.pipeline
+	config.Best = *f.best
+	config.Priority = strings.ToLower(*f.priority)
+	config.Encoder = strings.ToLower(*f.encoder)
+	config.Deadline = *f.deadline
+	config.ContinueOnError = *f.continueOnError
+	config.Effort = *f.effort
+	config.Learn = strings.ToLower(*f.learn)
+	config.RecompressAbove = *f.recompressAbove
+	config.Fallback = *f.fallback
+	if *f.showProgress {
+		config.Progress = PrintStageProgress
+	}
+	if *f.autoQuality {
+		config.AutoQuality = true
+		config.MinSSIM = *f.minSSIM
+		config.MinPSNR = *f.minPSNR
+	}
+
+	var err error
+	config.FrameStart, config.FrameEnd, err = domain.ParseFrameRange(*f.frameRange)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// CompressOptionsHelp 返回压缩选项的说明，每行以indent开头
+func CompressOptionsHelp(indent string) string {
+	var b strings.Builder
+	for _, line := range compressOptionsHelp {
+		fmt.Fprintf(&b, "%s%s\n", indent, line)
+	}
+	return b.String()
+}
+
+// compressOptionsHelp 压缩选项说明，与NewCompressFlags注册的选项一一对应
+var compressOptionsHelp = []string{
+	"--max-fps N        限制最大帧率，丢弃的帧时长并入前一帧",
+	"--drop-every-n N   每N帧丢弃一帧",
+	"--dedup            合并画面相同的连续帧",
+	"--frames A-B       只压缩第A到第B帧（含），如 10-60",
+	"--trim-start T     按时间裁剪起点，如 1.5s",
+	"--trim-end T       按时间裁剪终点，如 4s",
+	"--auto-quality     逐帧搜索满足失真下限的最低质量，quality作为上限",
+	"--min-ssim S       自动质量的SSIM下限，默认0.95",
+	"--min-psnr P       自动质量的PSNR下限(dB)",
+	"--quality-report   逐帧测量PSNR/SSIM并输出画质报告",
+	"--gif-estimate     抽样编码部分帧，估算等价GIF动画的大小",
+	"--near-lossless N  近无损预处理级别(1-100)",
+	"--mixed            每帧在有损和无损编码中取较小者",
+	"--pipeline P       处理管线: webpmux(默认，逐帧压缩后组装) 或 img2webp(解码完整帧后重新编码)",
+	"--best             两种处理管线都尝试，保留较小的结果",
+	"--verbose          输出逐帧压缩统计",
+	"--progress         在标准错误输出各阶段的实时进度",
+	"--priority P       工具子进程优先级: normal、low(后台) 或 idle(空闲时运行)",
+	"--encoder E        逐帧编码后端: cwebp(默认) 或 ffmpeg(编码器由WEBP_FFMPEG_CODEC指定)",
+	"--deadline D       目标处理时长(如30s)，试编码前几帧后选择来得及完成的最高压缩方法",
+	"--continue-on-error 丢弃重试后仍失败的帧并继续(重试次数由WEBP_FRAME_RETRIES指定)",
+	"--effort N         努力程度1-9(越高越慢、越小)，按机器类别(笔记本/CI/服务器)设置方法、遍数和并发数",
+	"--learn M          suggest(给出建议) 或 apply(直接使用) 相似输入的历史最佳设置，需配置WEBP_HISTORY_FILE",
+	"--recompress-above N 只重新压缩大于N字节的帧，较小的帧原样保留(仅webpmux管线)",
+	"--fallback         处理失败时依次尝试另一管线、GIF往返(gif2webp)和原样复制，结果中注明所用策略",
+}
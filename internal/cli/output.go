@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// PrintCompressResult 输出用户友好的压缩结果，experimentStrategy为配置的实验组策略，verbose时附带逐帧统计
+func PrintCompressResult(result *domain.CompressResult, config *domain.CompressionConfig, experimentStrategy string, verbose bool) {
+	fmt.Printf("✅ 压缩完成！\n")
+	fmt.Printf("📊 压缩效果: %s -> %s (%.1f%%)\n",
+		FormatFileSize(result.OriginalSize),
+		FormatFileSize(result.CompressedSize),
+		result.CompressionRatio)
+	fmt.Printf("⏱️  处理时间: %v\n", result.ProcessingTime)
+	fmt.Printf("🎞️  处理帧数: %d\n", result.FramesProcessed)
+	if result.FramesDropped > 0 {
+		fmt.Printf("✂️  丢弃帧数: %d\n", result.FramesDropped)
+	}
+	if result.FramesMerged > 0 {
+		fmt.Printf("🔁 合并重复帧: %d\n", result.FramesMerged)
+	}
+	if result.DuplicateFrames > 0 {
+		fmt.Printf("♻️  压缩结果相同的帧: %d\n", result.DuplicateFrames)
+	}
+	if result.FramesTrimmed > 0 {
+		fmt.Printf("✂️  裁剪帧数: %d\n", result.FramesTrimmed)
+	}
+	if result.FramesFailed > 0 {
+		fmt.Printf("⚠️  失败丢弃帧数: %d\n", result.FramesFailed)
+	}
+	if result.FramesRepositioned > 0 {
+		fmt.Printf("📐 移回画布内的越界帧: %d\n", result.FramesRepositioned)
+	}
+	if result.FramesKept > 0 {
+		fmt.Printf("📎 保留原始数据的帧数: %d\n", result.FramesKept)
+	}
+	if result.Pipeline != "" {
+		fmt.Printf("🛠️  处理管线: %s\n", result.Pipeline)
+	}
+	if config.Deadline > 0 || config.Effort > 0 {
+		fmt.Printf("⚙️  压缩方法: -m %d\n", result.Method)
+	}
+	if result.Quality != nil {
+		fmt.Printf("🔍 画质评估: PSNR 平均 %.2fdB / 最低 %.2fdB, SSIM 平均 %.4f / 最低 %.4f\n",
+			result.Quality.AvgPSNR, result.Quality.MinPSNR, result.Quality.AvgSSIM, result.Quality.MinSSIM)
+	}
+	if result.EstimatedGIFSize > 0 {
+		fmt.Printf("🎞️  等价GIF估计大小: %s（压缩结果为其 %.1f%%）\n",
+			FormatFileSize(result.EstimatedGIFSize), float64(result.CompressedSize)/float64(result.EstimatedGIFSize)*100)
+	}
+	if len(result.UnsupportedFeatures) > 0 {
+		fmt.Printf("🪂 输入含有无法重现的特性 %v，已原样输出\n", result.UnsupportedFeatures)
+	} else if result.Fallback != "" {
+		fmt.Printf("🪂 主处理失败，已改用回退策略: %s\n", result.Fallback)
+	}
+	if result.Cached {
+		fmt.Printf("📦 输出取自缓存，未重新压缩\n")
+	}
+	if result.ExperimentGroup != "" {
+		fmt.Printf("🧪 实验分组: %s (%s)\n", result.ExperimentGroup, experimentStrategy)
+	}
+	if result.Suggested != nil {
+		PrintLearnedSettings(result.Suggested, config.Learn == domain.LearnApply)
+	}
+	if verbose && len(result.FrameStats) > 0 {
+		PrintFrameStats(result.FrameStats)
+	}
+}
+
+// stageNames 处理阶段的显示名称
+var stageNames = map[string]string{
+	domain.StageExtract:  "提取帧",
+	domain.StageCompress: "压缩帧",
+	domain.StageAssemble: "组装动画",
+}
+
+// PrintStageProgress 在标准错误输出阶段进度，帧总数未知时只显示已完成数，心跳时显示已运行时长和输出大小
+func PrintStageProgress(progress domain.StageProgress) {
+	name := stageNames[progress.Stage]
+	if progress.Heartbeat {
+		fmt.Fprintf(os.Stderr, "⏳ %s: 仍在运行，已用时 %v", name, progress.Elapsed.Round(time.Second))
+		if progress.OutputBytes > 0 {
+			fmt.Fprintf(os.Stderr, "，输出 %s", FormatFileSize(progress.OutputBytes))
+		}
+		fmt.Fprintln(os.Stderr)
+		return
+	}
+	if progress.Total > 0 {
+		fmt.Fprintf(os.Stderr, "⏳ %s: %d/%d\n", name, progress.Completed, progress.Total)
+	} else {
+		fmt.Fprintf(os.Stderr, "⏳ %s: %d\n", name, progress.Completed)
+	}
+}
+
+// PrintLearnedSettings 打印相似输入的历史最佳设置
+func PrintLearnedSettings(settings *domain.LearnedSettings, applied bool) {
+	label := "💡 相似输入的历史最佳设置"
+	if applied {
+		label = "💡 已使用相似输入的历史最佳设置"
+	}
+	fmt.Printf("%s: 管线 %s, -m %d", label, settings.Pipeline, settings.Method)
+	if settings.Pass > 0 {
+		fmt.Printf(", -pass %d", settings.Pass)
+	}
+	if settings.Preset != "" {
+		fmt.Printf(", 预设 %s", settings.Preset)
+	}
+	if settings.Mixed {
+		fmt.Printf(", 混合编码")
+	}
+	if settings.NearLossless > 0 {
+		fmt.Printf(", 近无损 %d", settings.NearLossless)
+	}
+	fmt.Println()
+}
+
+// PrintFrameStats 打印逐帧压缩统计，保留原始数据和压缩后反而变大的帧加以标记
+func PrintFrameStats(stats []domain.FrameStat) {
+	fmt.Printf("\n%-6s %-8s %-10s %-10s %-8s %s\n", "帧", "时长", "原始", "压缩后", "比例", "耗时")
+	for _, stat := range stats {
+		ratio := 0.0
+		if stat.OriginalSize > 0 {
+			ratio = float64(stat.CompressedSize) / float64(stat.OriginalSize) * 100
+		}
+		mark := ""
+		if stat.Kept {
+			mark = " (保留)"
+		} else if stat.CompressedSize > stat.OriginalSize {
+			mark = " ⚠️"
+		}
+		fmt.Printf("%-6d %-8v %-10s %-10s %-8s %v%s\n",
+			stat.Index,
+			stat.Duration,
+			FormatFileSize(stat.OriginalSize),
+			FormatFileSize(stat.CompressedSize),
+			fmt.Sprintf("%.1f%%", ratio),
+			stat.CompressTime.Round(time.Millisecond),
+			mark)
+	}
+}
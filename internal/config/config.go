@@ -3,9 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config 应用程序配置
@@ -19,31 +21,57 @@ type Config struct {
 
 // AppConfig 应用程序基础配置
 type AppConfig struct {
-	Name           string `json:"name"`
-	Version        string `json:"version"`
-	MaxConcurrency int    `json:"max_concurrency"`
-	TempDirPrefix  string `json:"temp_dir_prefix"`
-	DefaultQuality int    `json:"default_quality"`
+	Name           string        `json:"name"`
+	Version        string        `json:"version"`
+	MaxConcurrency int           `json:"max_concurrency"`
+	TempDirPrefix  string        `json:"temp_dir_prefix"`
+	TempDir        string        `json:"temp_dir,omitempty"` // 临时目录的根目录，为空时使用系统临时目录
+	DefaultQuality int           `json:"default_quality"`
+	Timeout        time.Duration `json:"timeout"` // 单次操作的总超时，单个工具调用另受CommandTimeout限制
 }
 
 // ToolsConfig 工具配置
 type ToolsConfig struct {
-	ToolsPath      string `json:"tools_path"`
-	WebpmuxPath    string `json:"webpmux_path"`
-	CwebpPath      string `json:"cwebp_path"`
-	DwebpPath      string `json:"dwebp_path"`
-	CommandTimeout int    `json:"command_timeout"` // 秒
+	ToolsPath      string            `json:"tools_path"`
+	ToolPaths      map[string]string `json:"tool_paths,omitempty"` // 按工具名单独指定的可执行文件路径，优先于ToolsPath
+	UseEmbedded    bool              `json:"use_embedded"`         // 使用释放到临时目录的内置工具
+	WebpmuxPath    string            `json:"webpmux_path"`
+	CwebpPath      string            `json:"cwebp_path"`
+	DwebpPath      string            `json:"dwebp_path"`
+	CommandTimeout int               `json:"command_timeout"` // 秒
+	FFmpegCodec    string            `json:"ffmpeg_codec"`    // ffmpeg编码后端使用的WebP编码器，可替换为厂商提供的硬件编码器
+	Retries        int               `json:"retries"`         // 工具因暂时性原因（文件被锁定、内存不足等）失败后的重试次数
+	RetryBackoff   int               `json:"retry_backoff"`   // 首次重试前的等待时间(毫秒)，之后每次翻倍
 }
 
 // ProcessingConfig 处理配置
 type ProcessingConfig struct {
-	EnableParallel     bool   `json:"enable_parallel"`
-	MaxWorkers         int    `json:"max_workers"`
-	ChunkSize          int    `json:"chunk_size"`
-	PreserveMetadata   bool   `json:"preserve_metadata"`
-	DefaultPreset      string `json:"default_preset"`
-	EnableProgressBar  bool   `json:"enable_progress_bar"`
-	EnableOptimization bool   `json:"enable_optimization"`
+	EnableParallel     bool    `json:"enable_parallel"`
+	MaxWorkers         int     `json:"max_workers"`
+	ChunkSize          int     `json:"chunk_size"`
+	PreserveMetadata   bool    `json:"preserve_metadata"`
+	DefaultPreset      string  `json:"default_preset"`
+	EnableProgressBar  bool    `json:"enable_progress_bar"`
+	EnableOptimization bool    `json:"enable_optimization"`
+	ExtractWorkers     int     `json:"extract_workers"`               // 提取阶段并发数（磁盘密集）
+	CompressWorkers    int     `json:"compress_workers"`              // 压缩阶段并发数（CPU密集），0表示使用MaxConcurrency
+	StageQueueSize     int     `json:"stage_queue_size"`              // 阶段间通道容量
+	FrameRetries       int     `json:"frame_retries"`                 // 单帧压缩失败后的重试次数
+	DiagnosticsDir     string  `json:"diagnostics_dir,omitempty"`     // 组装失败时写入诊断包的目录，为空则不生成
+	VerifyOutput       string  `json:"verify_output"`                 // 输出校验模式: off、warn、strict
+	CPUClass           string  `json:"cpu_class,omitempty"`           // 机器类别: laptop、ci、server，为空时按CPU核数和速度检测
+	HistoryFile        string  `json:"history_file,omitempty"`        // 按输入指纹记录压缩设置和效果的文件，为空则不记录
+	ExperimentStrategy string  `json:"experiment_strategy,omitempty"` // A/B实验组使用的策略: img2webp、mixed、dedup、best，为空则不做实验
+	ExperimentPercent  int     `json:"experiment_percent"`            // 进入实验组的任务比例(0-100)
+	ExperimentLog      string  `json:"experiment_log,omitempty"`      // 实验指标记录文件(JSON Lines)，为空则只写日志
+	StatsFile          string  `json:"stats_file,omitempty"`          // 每次压缩的输入特征、设置和结果(JSON Lines)，用于离线分析，为空则不记录
+	CacheDir           string  `json:"cache_dir,omitempty"`           // 按输入内容和设置缓存压缩结果的目录，为空则不缓存
+	UnsupportedPolicy  string  `json:"unsupported_policy"`            // 输入含有无法重现的特性(如分片)时: reject拒绝、passthrough原样输出
+	FrameBoundsPolicy  string  `json:"frame_bounds_policy"`           // 帧区域超出画布时: fix移回画布内、fail报错
+	MaxOutputRatio     float64 `json:"max_output_ratio,omitempty"`    // 预计输出超过输入大小的此倍数时提前中止，0表示不限制
+	MaxFrames          int     `json:"max_frames"`                    // 输入帧数上限，0表示不限制
+	MaxCanvasPixels    int64   `json:"max_canvas_pixels"`             // 输入画布像素数上限，0表示不限制
+	MaxDecodedBytes    int64   `json:"max_decoded_bytes"`             // 全部帧按画布解码为RGBA后的总字节数上限，0表示不限制
 }
 
 // LoggingConfig 日志配置
@@ -103,9 +131,9 @@ type OptimizationRules struct {
 type PerformanceConfig struct {
 	IOBufferSize        int  `json:"io_buffer_size"` // bytes
 	EnableMemoryLimit   bool `json:"enable_memory_limit"`
-	MaxMemoryUsage      int  `json:"max_memory_usage"` // MB
+	MaxMemoryUsage      int  `json:"max_memory_usage"` // MB，只统计本进程Go运行时的内存，不含cwebp等工具子进程
 	EnableCPUThrottling bool `json:"enable_cpu_throttling"`
-	CPUUsageLimit       int  `json:"cpu_usage_limit"` // 0-100%
+	CPUUsageLimit       int  `json:"cpu_usage_limit"` // 0-100%，含已结束的工具子进程；Windows下只统计本进程
 }
 
 // DefaultConfig 返回默认配置
@@ -117,6 +145,7 @@ func DefaultConfig() *Config {
 			MaxConcurrency: runtime.NumCPU(),
 			TempDirPrefix:  "webpcompressor",
 			DefaultQuality: 75,
+			Timeout:        30 * time.Minute,
 		},
 		Tools: ToolsConfig{
 			ToolsPath:      ".",
@@ -124,6 +153,9 @@ func DefaultConfig() *Config {
 			CwebpPath:      "cwebp",
 			DwebpPath:      "dwebp",
 			CommandTimeout: 300, // 5分钟
+			FFmpegCodec:    "libwebp",
+			Retries:        2,
+			RetryBackoff:   200,
 		},
 		Processing: ProcessingConfig{
 			EnableParallel:     true,
@@ -133,6 +165,15 @@ func DefaultConfig() *Config {
 			DefaultPreset:      "photo",
 			EnableProgressBar:  true,
 			EnableOptimization: true,
+			ExtractWorkers:     2,
+			CompressWorkers:    0,
+			StageQueueSize:     16,
+			VerifyOutput:       "warn",
+			UnsupportedPolicy:  "reject",
+			FrameBoundsPolicy:  "fix",
+			MaxFrames:          5000,
+			MaxCanvasPixels:    8192 * 8192,
+			MaxDecodedBytes:    16 << 30, // 16GB
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -272,6 +313,16 @@ func (c *Config) LoadFromEnv() {
 		}
 	}
 
+	if val := os.Getenv("WEBP_TEMP_DIR"); val != "" {
+		c.App.TempDir = val
+	}
+
+	if val := os.Getenv("WEBP_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			c.App.Timeout = d
+		}
+	}
+
 	// 工具配置
 	if val := os.Getenv("WEBP_TOOLS_PATH"); val != "" {
 		c.Tools.ToolsPath = val
@@ -283,6 +334,22 @@ func (c *Config) LoadFromEnv() {
 		}
 	}
 
+	if val := os.Getenv("WEBP_FFMPEG_CODEC"); val != "" {
+		c.Tools.FFmpegCodec = val
+	}
+
+	if val := os.Getenv("WEBP_TOOL_RETRIES"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num >= 0 {
+			c.Tools.Retries = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_TOOL_RETRY_BACKOFF"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num >= 0 {
+			c.Tools.RetryBackoff = num
+		}
+	}
+
 	// 处理配置
 	if val := os.Getenv("WEBP_ENABLE_PARALLEL"); val != "" {
 		c.Processing.EnableParallel = strings.ToLower(val) == "true"
@@ -296,6 +363,100 @@ func (c *Config) LoadFromEnv() {
 		c.Processing.DefaultPreset = val
 	}
 
+	if val := os.Getenv("WEBP_EXTRACT_WORKERS"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num > 0 {
+			c.Processing.ExtractWorkers = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_COMPRESS_WORKERS"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num > 0 {
+			c.Processing.CompressWorkers = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_FRAME_RETRIES"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num >= 0 {
+			c.Processing.FrameRetries = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_DIAGNOSTICS_DIR"); val != "" {
+		c.Processing.DiagnosticsDir = val
+	}
+
+	if val := os.Getenv("WEBP_VERIFY_OUTPUT"); val != "" {
+		c.Processing.VerifyOutput = strings.ToLower(val)
+	}
+
+	if val := os.Getenv("WEBP_CPU_CLASS"); val != "" {
+		c.Processing.CPUClass = strings.ToLower(val)
+	}
+
+	if val := os.Getenv("WEBP_HISTORY_FILE"); val != "" {
+		c.Processing.HistoryFile = val
+	}
+
+	if val := os.Getenv("WEBP_UNSUPPORTED_POLICY"); val != "" {
+		c.Processing.UnsupportedPolicy = strings.ToLower(val)
+	}
+
+	if val := os.Getenv("WEBP_FRAME_BOUNDS"); val != "" {
+		c.Processing.FrameBoundsPolicy = strings.ToLower(val)
+	}
+
+	if val := os.Getenv("WEBP_MAX_OUTPUT_RATIO"); val != "" {
+		if num, err := strconv.ParseFloat(val, 64); err == nil {
+			c.Processing.MaxOutputRatio = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_MAX_FRAMES"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil {
+			c.Processing.MaxFrames = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_MAX_CANVAS_PIXELS"); val != "" {
+		if num, err := strconv.ParseInt(val, 10, 64); err == nil {
+			c.Processing.MaxCanvasPixels = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_MAX_DECODED_MB"); val != "" {
+		if num, err := strconv.ParseInt(val, 10, 64); err == nil {
+			c.Processing.MaxDecodedBytes = num << 20
+		}
+	}
+
+	if val := os.Getenv("WEBP_CACHE_DIR"); val != "" {
+		c.Processing.CacheDir = val
+	}
+
+	if val := os.Getenv("WEBP_EXPERIMENT_STRATEGY"); val != "" {
+		c.Processing.ExperimentStrategy = strings.ToLower(val)
+	}
+
+	if val := os.Getenv("WEBP_EXPERIMENT_PERCENT"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil {
+			c.Processing.ExperimentPercent = num
+		}
+	}
+
+	if val := os.Getenv("WEBP_EXPERIMENT_LOG"); val != "" {
+		c.Processing.ExperimentLog = val
+	}
+
+	if val := os.Getenv("WEBP_STATS_FILE"); val != "" {
+		c.Processing.StatsFile = val
+	}
+
+	if val := os.Getenv("WEBP_MAX_FILE_SIZE"); val != "" {
+		if num, err := strconv.ParseInt(val, 10, 64); err == nil && num > 0 {
+			c.Advanced.OptimizationRules.MaxFileSize = num
+		}
+	}
+
 	// 日志配置
 	if val := os.Getenv("WEBP_LOG_LEVEL"); val != "" {
 		c.Logging.Level = val
@@ -311,6 +472,13 @@ func (c *Config) LoadFromEnv() {
 			c.Advanced.PerformanceConfig.MaxMemoryUsage = num
 		}
 	}
+
+	if val := os.Getenv("WEBP_CPU_LIMIT"); val != "" {
+		if num, err := strconv.Atoi(val); err == nil && num > 0 {
+			c.Advanced.PerformanceConfig.EnableCPUThrottling = true
+			c.Advanced.PerformanceConfig.CPUUsageLimit = num
+		}
+	}
 }
 
 // Validate 验证配置
@@ -331,10 +499,80 @@ func (c *Config) Validate() error {
 	}
 
 	// 验证超时时间
+	if c.App.Timeout <= 0 {
+		return fmt.Errorf("操作超时时间必须大于0，当前值: %v", c.App.Timeout)
+	}
 	if c.Tools.CommandTimeout <= 0 {
 		return fmt.Errorf("命令超时时间必须大于0，当前值: %d", c.Tools.CommandTimeout)
 	}
 
+	// 验证工具重试配置
+	if c.Tools.Retries < 0 {
+		return fmt.Errorf("工具重试次数不能为负数，当前值: %d", c.Tools.Retries)
+	}
+	if c.Tools.RetryBackoff < 0 {
+		return fmt.Errorf("工具重试等待时间不能为负数，当前值: %d", c.Tools.RetryBackoff)
+	}
+
+	// 验证流水线阶段配置
+	if c.Processing.ExtractWorkers <= 0 {
+		return fmt.Errorf("提取阶段并发数必须大于0，当前值: %d", c.Processing.ExtractWorkers)
+	}
+	if c.Processing.CompressWorkers < 0 {
+		return fmt.Errorf("压缩阶段并发数不能为负数，当前值: %d", c.Processing.CompressWorkers)
+	}
+	if c.Processing.StageQueueSize <= 0 {
+		return fmt.Errorf("阶段队列容量必须大于0，当前值: %d", c.Processing.StageQueueSize)
+	}
+	switch c.Processing.CPUClass {
+	case "", "laptop", "ci", "server":
+	default:
+		return fmt.Errorf("无效的机器类别: %s，支持: laptop、ci、server", c.Processing.CPUClass)
+	}
+	switch c.Processing.ExperimentStrategy {
+	case "", "img2webp", "mixed", "dedup", "best":
+	default:
+		return fmt.Errorf("无效的实验策略: %s，支持: img2webp、mixed、dedup、best", c.Processing.ExperimentStrategy)
+	}
+	if c.Processing.ExperimentPercent < 0 || c.Processing.ExperimentPercent > 100 {
+		return fmt.Errorf("实验比例必须在0-100之间，当前值: %d", c.Processing.ExperimentPercent)
+	}
+	if c.Processing.FrameRetries < 0 {
+		return fmt.Errorf("帧压缩重试次数不能为负数，当前值: %d", c.Processing.FrameRetries)
+	}
+	switch c.Processing.VerifyOutput {
+	case "off", "warn", "strict":
+	default:
+		return fmt.Errorf("无效的输出校验模式: %s，支持: off、warn、strict", c.Processing.VerifyOutput)
+	}
+
+	switch c.Processing.UnsupportedPolicy {
+	case "reject", "passthrough":
+	default:
+		return fmt.Errorf("无效的不支持特性处理策略: %s，支持: reject、passthrough", c.Processing.UnsupportedPolicy)
+	}
+
+	switch c.Processing.FrameBoundsPolicy {
+	case "fix", "fail":
+	default:
+		return fmt.Errorf("无效的帧越界处理策略: %s，支持: fix、fail", c.Processing.FrameBoundsPolicy)
+	}
+	if c.Processing.MaxOutputRatio < 0 {
+		return fmt.Errorf("输出大小倍数上限不能为负数，当前值: %v", c.Processing.MaxOutputRatio)
+	}
+	if c.Processing.MaxFrames < 0 || c.Processing.MaxCanvasPixels < 0 || c.Processing.MaxDecodedBytes < 0 {
+		return fmt.Errorf("输入帧数、画布像素和解码大小上限不能为负数")
+	}
+
+	// 验证资源限制
+	perf := c.Advanced.PerformanceConfig
+	if perf.EnableMemoryLimit && perf.MaxMemoryUsage <= 0 {
+		return fmt.Errorf("内存阈值必须大于0，当前值: %d", perf.MaxMemoryUsage)
+	}
+	if perf.EnableCPUThrottling && (perf.CPUUsageLimit <= 0 || perf.CPUUsageLimit > 100) {
+		return fmt.Errorf("CPU使用率阈值必须在1-100之间，当前值: %d", perf.CPUUsageLimit)
+	}
+
 	// 验证日志级别
 	validLogLevels := []string{"debug", "info", "warn", "error"}
 	levelValid := false
@@ -395,3 +633,39 @@ func (c *Config) GetEffectiveWorkers(taskCount int) int {
 
 	return maxWorkers
 }
+
+// GetToolPath 返回工具的可执行文件路径
+//
+// 依次使用ToolPaths中单独指定的路径、ToolsPath目录下存在的文件，否则返回文件名交给PATH查找。
+// Windows下没有扩展名时补全.exe
+func (c *Config) GetToolPath(toolName string) string {
+	if path := c.Tools.ToolPaths[toolName]; path != "" {
+		return path
+	}
+
+	name := toolName
+	switch toolName {
+	case "webpmux":
+		name = c.Tools.WebpmuxPath
+	case "cwebp":
+		name = c.Tools.CwebpPath
+	case "dwebp":
+		name = c.Tools.DwebpPath
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		name += ".exe"
+	}
+	if filepath.IsAbs(name) || c.Tools.ToolsPath == "" {
+		return name
+	}
+
+	// 相对路径保留目录前缀，避免exec把它当作PATH中的命令名
+	path := filepath.Join(c.Tools.ToolsPath, name)
+	if _, err := os.Stat(path); err == nil {
+		if !filepath.IsAbs(path) && !strings.ContainsRune(path, filepath.Separator) {
+			path = "." + string(filepath.Separator) + path
+		}
+		return path
+	}
+	return name
+}
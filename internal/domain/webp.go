@@ -2,19 +2,35 @@ package domain
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"webpcompressor/pkg/errors"
 )
 
 // FrameInfo 表示WebP动画帧信息
 type FrameInfo struct {
 	Index    int           `json:"index"`
+	Width    int           `json:"width"`
+	Height   int           `json:"height"`
 	X        int           `json:"x"`
 	Y        int           `json:"y"`
 	Duration time.Duration `json:"duration"`
 	Dispose  DisposeMethod `json:"dispose"`
 	Blend    BlendMethod   `json:"blend"`
 	Path     string        `json:"path"`
+
+	PSNR float64 `json:"psnr,omitempty"` // 压缩后与源帧的PSNR(dB)，开启画质报告时填写
+	SSIM float64 `json:"ssim,omitempty"` // 压缩后与源帧的SSIM(0-1)，开启画质报告时填写
+
+	OriginalSize   int64         `json:"original_size,omitempty"`   // 压缩前帧文件大小
+	CompressedSize int64         `json:"compressed_size,omitempty"` // 压缩后帧文件大小
+	CompressTime   time.Duration `json:"compress_time,omitempty"`   // 压缩耗时
+	Kept           bool          `json:"kept,omitempty"`            // 小于重新压缩阈值，保留了原始帧数据
 }
 
 // DisposeMethod 表示帧处理方式
@@ -25,6 +41,14 @@ const (
 	DisposeBackground DisposeMethod = 1 // 背景色处理
 )
 
+// String 返回与webpmux一致的处理方式名称
+func (d DisposeMethod) String() string {
+	if d == DisposeBackground {
+		return "background"
+	}
+	return "none"
+}
+
 // BlendMethod 表示帧混合方式
 type BlendMethod int
 
@@ -33,6 +57,14 @@ const (
 	BlendYes BlendMethod = 1 // 混合
 )
 
+// String 返回与webpmux一致的混合方式名称
+func (b BlendMethod) String() string {
+	if b == BlendYes {
+		return "yes"
+	}
+	return "no"
+}
+
 // AnimationInfo 表示动画信息
 type AnimationInfo struct {
 	Width      int          `json:"width"`
@@ -42,16 +74,253 @@ type AnimationInfo struct {
 	Frames     []*FrameInfo `json:"frames"`
 }
 
+// IsSelfContained 判断每一帧是否都覆盖整个画布且不依赖前一帧
+func (a *AnimationInfo) IsSelfContained() bool {
+	for _, frame := range a.Frames {
+		if frame.X != 0 || frame.Y != 0 ||
+			frame.Width != a.Width || frame.Height != a.Height ||
+			frame.Blend != BlendNo {
+			return false
+		}
+	}
+	return true
+}
+
+// OutputFormat 表示动画导出格式
+type OutputFormat string
+
+const (
+	OutputFormatGIF  OutputFormat = "gif"  // GIF动画
+	OutputFormatAPNG OutputFormat = "apng" // APNG动画
+)
+
+// ComposeManifest 表示图像序列合成清单
+type ComposeManifest struct {
+	Loop   int                    `json:"loop"`   // 循环次数，0表示无限循环
+	Frames []ComposeManifestFrame `json:"frames"` // 按播放顺序排列的帧
+}
+
+// ComposeManifestFrame 表示清单中的单帧
+type ComposeManifestFrame struct {
+	File     string `json:"file"`     // 图像文件路径，相对路径以清单所在目录为基准
+	Duration int    `json:"duration"` // 持续时间(毫秒)
+}
+
+// FrameManifest 表示帧提取清单
+//
+// 字段名与ComposeManifest兼容，完整画布模式下的清单可直接用于重新合成
+type FrameManifest struct {
+	Source     string               `json:"source"`
+	Width      int                  `json:"width"`
+	Height     int                  `json:"height"`
+	Loop       int                  `json:"loop"`
+	FullCanvas bool                 `json:"full_canvas"` // 帧是否为合成后的完整画布
+	Frames     []FrameManifestEntry `json:"frames"`
+}
+
+// FrameManifestEntry 表示提取清单中的单帧
+type FrameManifestEntry struct {
+	Index    int    `json:"index"`
+	File     string `json:"file"`     // 相对于清单所在目录的文件名
+	Duration int    `json:"duration"` // 持续时间(毫秒)
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Dispose  string `json:"dispose"`
+	Blend    string `json:"blend"`
+}
+
+// AssemblyManifest 表示组装阶段的输入清单
+//
+// 由压缩阶段逐帧记录，webpmux的组装参数完全由清单生成，便于审计和重放
+type AssemblyManifest struct {
+	Output string          `json:"output"`
+	Loop   int             `json:"loop"`
+	Frames []AssemblyEntry `json:"frames"`
+}
+
+// AssemblyEntry 表示组装清单中的单帧
+type AssemblyEntry struct {
+	Index    int           `json:"index"`
+	File     string        `json:"file"`
+	Size     int64         `json:"size"`               // 记录时的文件大小，组装前校验
+	Checksum string        `json:"checksum,omitempty"` // 压缩后文件内容的SHA-256，用于发现字节相同的帧
+	Duration int           `json:"duration"`           // 持续时间(毫秒)
+	X        int           `json:"x"`
+	Y        int           `json:"y"`
+	Width    int           `json:"width,omitempty"`  // 帧宽度，用于检查帧区域是否超出画布
+	Height   int           `json:"height,omitempty"` // 帧高度
+	Dispose  DisposeMethod `json:"dispose"`
+	Blend    BlendMethod   `json:"blend"`
+}
+
 // CompressionConfig 表示压缩配置
 type CompressionConfig struct {
 	Quality        int    `json:"quality"`         // 质量 0-100
 	Method         int    `json:"method"`          // 压缩方法 0-6
+	Pass           int    `json:"pass,omitempty"`  // 分析遍数 1-10，0表示使用默认的10
 	FilterStrength int    `json:"filter_strength"` // 滤波强度 0-100
 	Preset         string `json:"preset"`          // 预设
 	Lossless       bool   `json:"lossless"`        // 无损压缩
 	AlphaQuality   int    `json:"alpha_quality"`   // Alpha质量
 	EnableParallel bool   `json:"enable_parallel"` // 启用并行处理
 	MaxConcurrency int    `json:"max_concurrency"` // 最大并发数
+
+	MaxFPS      float64 `json:"max_fps,omitempty"`      // 最大帧率，0表示不限制
+	DropEveryN  int     `json:"drop_every_n,omitempty"` // 每N帧丢弃一帧，0表示不丢帧
+	Deduplicate bool    `json:"deduplicate,omitempty"`  // 合并画面相同的连续帧
+
+	FrameStart int           `json:"frame_start,omitempty"` // 起始帧序号(从1开始，含)，0表示从头开始
+	FrameEnd   int           `json:"frame_end,omitempty"`   // 结束帧序号(含)，0表示到最后一帧
+	TrimStart  time.Duration `json:"trim_start,omitempty"`  // 丢弃在此时间点之前开始的帧
+	TrimEnd    time.Duration `json:"trim_end,omitempty"`    // 丢弃在此时间点及之后开始的帧，0表示不限制
+
+	AutoQuality bool    `json:"auto_quality,omitempty"` // 逐帧搜索满足失真下限的最低质量，Quality作为上限
+	MinSSIM     float64 `json:"min_ssim,omitempty"`     // SSIM下限(0-1)，0表示不限制
+	MinPSNR     float64 `json:"min_psnr,omitempty"`     // PSNR下限(dB)，0表示不限制
+
+	QualityReport bool `json:"quality_report,omitempty"` // 逐帧测量PSNR/SSIM并在结果中汇总
+	GIFEstimate   bool `json:"gif_estimate,omitempty"`   // 抽样编码部分帧，估算等价GIF动画的大小
+
+	NearLossless int  `json:"near_lossless,omitempty"` // 近无损预处理级别(1-100，越小预处理越强)，0表示不启用
+	Mixed        bool `json:"mixed,omitempty"`         // 每帧分别尝试有损和无损编码，保留较小的结果
+
+	Pipeline string `json:"pipeline,omitempty"` // 处理管线，见Pipeline*常量，为空时使用webpmux
+	Best     bool   `json:"best,omitempty"`     // 两种管线都尝试，保留较小的结果
+
+	Priority string `json:"priority,omitempty"` // 工具子进程优先级，见Priority*常量，为空时不调整
+	Encoder  string `json:"encoder,omitempty"`  // 逐帧编码后端，见Encoder*常量，为空时使用cwebp
+
+	Deadline time.Duration `json:"deadline,omitempty"` // 目标处理时长，按试编码耗时降低Method以满足，0表示不限制
+
+	ContinueOnError bool `json:"continue_on_error,omitempty"` // 重试后仍压缩失败的帧丢弃并记录警告，时长并入前一帧
+
+	Effort int `json:"effort,omitempty"` // 努力程度1-9，按机器类别统一设置Method、Pass和并发数，0表示不使用
+
+	Learn string `json:"learn,omitempty"` // 历史最佳设置的使用方式，见Learn*常量，为空时只记录不查找
+
+	RecompressAbove int64 `json:"recompress_above,omitempty"` // 只重新压缩大于此字节数的帧，较小的帧保留原始数据，0表示全部重新压缩
+
+	Fallback bool `json:"fallback,omitempty"` // 处理管线失败时依次尝试其他策略，见Fallback*常量
+
+	Progress StageProgressCallback `json:"-"` // 阶段进度回调，为空时不报告进度
+}
+
+// 处理管线
+const (
+	PipelineWebpmux  = "webpmux"  // webpmux逐帧提取、压缩后重新组装
+	PipelineImg2webp = "img2webp" // anim_dump解码完整画布帧后由img2webp重新编码
+)
+
+// 处理管线失败后的回退策略
+const (
+	FallbackWebpmux     = PipelineWebpmux  // 主管线为img2webp时改用webpmux管线
+	FallbackImg2webp    = PipelineImg2webp // 改用img2webp管线
+	FallbackGIF         = "gif2webp"       // 导出为GIF后由gif2webp重新编码
+	FallbackPassthrough = "passthrough"    // 原样复制输入
+)
+
+// 逐帧编码后端
+const (
+	EncoderCwebp  = "cwebp"  // libwebp自带的cwebp
+	EncoderFFmpeg = "ffmpeg" // ffmpeg，编码器由配置指定，可使用GPU或厂商编码器
+)
+
+// 历史最佳设置的使用方式
+const (
+	LearnSuggest = "suggest" // 在结果中给出相似输入的历史最佳设置
+	LearnApply   = "apply"   // 直接使用相似输入的历史最佳设置
+)
+
+// LearnedSettings 从历史记录中学到的压缩策略，不含质量（按相同质量比较）
+type LearnedSettings struct {
+	Pipeline     string `json:"pipeline"`
+	Preset       string `json:"preset,omitempty"`
+	Method       int    `json:"method"`
+	Pass         int    `json:"pass,omitempty"`
+	Mixed        bool   `json:"mixed,omitempty"`
+	NearLossless int    `json:"near_lossless,omitempty"`
+}
+
+// 机器类别，决定努力程度预设下的并发数和遍数上限
+const (
+	CPUClassLaptop = "laptop" // 笔记本：保留一半核心给前台使用
+	CPUClassCI     = "ci"     // CI运行器：核心少或单核慢，限制遍数以控制构建时间
+	CPUClassServer = "server" // 专用服务器：使用全部核心和完整遍数
+)
+
+// 工具子进程优先级
+const (
+	PriorityNormal = "normal" // 与当前进程相同
+	PriorityLow    = "low"    // nice 10 / BELOW_NORMAL_PRIORITY_CLASS
+	PriorityIdle   = "idle"   // nice 19 / IDLE_PRIORITY_CLASS，适合后台批量重压缩
+)
+
+// IsValidPriority 判断是否为支持的工具子进程优先级，空值视为不调整
+func IsValidPriority(priority string) bool {
+	switch priority {
+	case "", PriorityNormal, PriorityLow, PriorityIdle:
+		return true
+	}
+	return false
+}
+
+// toolPriorityKey 上下文中工具子进程优先级的键
+type toolPriorityKey struct{}
+
+// WithToolPriority 返回携带工具子进程优先级的上下文，工具执行器据此调整所启动子进程的优先级
+func WithToolPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, toolPriorityKey{}, priority)
+}
+
+// ToolPriorityFromContext 取出上下文中的工具子进程优先级，未设置时返回PriorityNormal
+func ToolPriorityFromContext(ctx context.Context) string {
+	if priority, ok := ctx.Value(toolPriorityKey{}).(string); ok && priority != "" {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// HasFrameRange 是否指定了帧范围或时间裁剪
+func (c *CompressionConfig) HasFrameRange() bool {
+	return c.FrameStart > 0 || c.FrameEnd > 0 || c.TrimStart > 0 || c.TrimEnd > 0
+}
+
+// ParseFrameRange 解析形如 "10-60"、"10-"、"-60" 或 "10" 的帧范围，0表示该端不限制
+func ParseFrameRange(value string) (int, int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	startStr, endStr, isRange := strings.Cut(value, "-")
+	if !isRange {
+		endStr = startStr
+	}
+
+	parse := func(part string) (int, error) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("无效的帧序号: %s", part)
+		}
+		return n, nil
+	}
+
+	start, err := parse(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parse(endStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end > 0 && start > end {
+		return 0, 0, fmt.Errorf("起始帧大于结束帧: %s", value)
+	}
+	return start, end, nil
 }
 
 // DefaultCompressionConfig 返回默认压缩配置
@@ -68,14 +337,137 @@ func DefaultCompressionConfig(quality int) *CompressionConfig {
 	}
 }
 
+// FrameStat 表示单帧压缩统计
+type FrameStat struct {
+	Index          int           `json:"index"`
+	Duration       time.Duration `json:"duration"` // 帧显示时长
+	OriginalSize   int64         `json:"original_size"`
+	CompressedSize int64         `json:"compressed_size"`
+	CompressTime   time.Duration `json:"compress_time"`
+	PSNR           float64       `json:"psnr,omitempty"`
+	SSIM           float64       `json:"ssim,omitempty"`
+	Kept           bool          `json:"kept,omitempty"` // 保留了原始帧数据，未重新压缩
+}
+
+// QualityReport 表示压缩前后逐帧画质的汇总
+type QualityReport struct {
+	Frames  int     `json:"frames"`
+	AvgPSNR float64 `json:"avg_psnr"` // dB
+	MinPSNR float64 `json:"min_psnr"` // dB
+	AvgSSIM float64 `json:"avg_ssim"` // 0-1
+	MinSSIM float64 `json:"min_ssim"` // 0-1
+}
+
+// CompareReport 表示两个动画的比较结果
+type CompareReport struct {
+	FileA       string      `json:"file_a"`
+	FileB       string      `json:"file_b"`
+	Identical   bool        `json:"identical"`             // anim_diff判定两者完全一致
+	Differences []string    `json:"differences,omitempty"` // 画布、帧数、循环次数及anim_diff报告的差异
+	QualityA    int         `json:"quality_a"`             // webp_quality估计的质量因子，-1表示无法估计（如无损）
+	QualityB    int         `json:"quality_b"`
+	Similarity  float64     `json:"similarity"` // 整体相似度(0-1)，即各帧SSIM的平均值
+	Frames      []FrameDiff `json:"frames"`
+}
+
+// FrameDiff 表示两个动画中同序号帧的差异
+type FrameDiff struct {
+	Index     int     `json:"index"`
+	DurationA int     `json:"duration_a"` // 持续时间(毫秒)
+	DurationB int     `json:"duration_b"`
+	PSNR      float64 `json:"psnr"` // dB
+	SSIM      float64 `json:"ssim"` // 0-1
+}
+
+// IntegrityReport 表示动画完整性检查的结果
+type IntegrityReport struct {
+	File      string           `json:"file"`
+	Healthy   bool             `json:"healthy"` // 没有错误级别的问题
+	Width     int              `json:"width"`
+	Height    int              `json:"height"`
+	Frames    int              `json:"frames"`
+	LoopCount int              `json:"loop_count"`
+	Issues    []IntegrityIssue `json:"issues,omitempty"`
+}
+
+// IntegrityIssue 表示完整性检查发现的一个问题
+type IntegrityIssue struct {
+	Severity string `json:"severity"`        // 见Severity*常量
+	Code     string `json:"code"`            // 问题类型，便于程序处理
+	Frame    int    `json:"frame,omitempty"` // 相关的帧序号，整体问题为0
+	Message  string `json:"message"`
+}
+
+// 完整性问题的严重程度
+const (
+	SeverityError   = "error"   // 动画损坏或会被错误渲染
+	SeverityWarning = "warning" // 能正常播放，但不同播放器的表现可能不一致
+)
+
+// ReplayReport 表示重放诊断包中失败任务的结果
+type ReplayReport struct {
+	Bundle        string            `json:"bundle"`
+	Settings      CompressionConfig `json:"settings"`
+	OriginalError string            `json:"original_error"`
+	VersionDiffs  []ToolVersionDiff `json:"version_diffs,omitempty"` // 本地工具版本与诊断包记录不一致
+	Reproduced    bool              `json:"reproduced"`              // 重放失败且错误码与原任务相同
+	Error         string            `json:"error,omitempty"`         // 重放的错误，成功时为空
+	Result        *CompressResult   `json:"result,omitempty"`
+}
+
+// ToolVersionDiff 表示诊断包记录的工具版本与本地版本的差异
+type ToolVersionDiff struct {
+	Tool     string `json:"tool"`
+	Recorded string `json:"recorded"`
+	Current  string `json:"current"`
+}
+
+// A/B实验分组
+const (
+	ExperimentControl   = "control"   // 按任务自身配置处理
+	ExperimentTreatment = "treatment" // 改用实验策略处理
+)
+
+// ExperimentReport 表示A/B实验记录的汇总
+type ExperimentReport struct {
+	Strategy string                 `json:"strategy"`
+	Groups   []ExperimentGroupStats `json:"groups"` // 对照组在前
+}
+
+// ExperimentGroupStats 表示实验某一分组的汇总指标
+type ExperimentGroupStats struct {
+	Group       string        `json:"group"`
+	Jobs        int           `json:"jobs"`
+	Failures    int           `json:"failures"`
+	AvgRatio    float64       `json:"avg_ratio"`    // 成功任务的平均压缩率(%)
+	AvgDuration time.Duration `json:"avg_duration"` // 成功任务的平均处理时间
+}
+
 // CompressResult 表示压缩结果
 type CompressResult struct {
-	OriginalSize     int64         `json:"original_size"`
-	CompressedSize   int64         `json:"compressed_size"`
-	CompressionRatio float64       `json:"compression_ratio"`
-	ProcessingTime   time.Duration `json:"processing_time"`
-	FramesProcessed  int           `json:"frames_processed"`
-	ParallelWorkers  int           `json:"parallel_workers"` // 使用的并行工作者数量
+	OriginalSize        int64            `json:"original_size"`
+	CompressedSize      int64            `json:"compressed_size"`
+	CompressionRatio    float64          `json:"compression_ratio"`
+	ProcessingTime      time.Duration    `json:"processing_time"`
+	FramesProcessed     int              `json:"frames_processed"`
+	FramesDropped       int              `json:"frames_dropped,omitempty"`       // 降帧丢弃的帧数
+	FramesMerged        int              `json:"frames_merged,omitempty"`        // 去重合并的重复帧数
+	FramesTrimmed       int              `json:"frames_trimmed,omitempty"`       // 范围裁剪去掉的帧数
+	FramesFailed        int              `json:"frames_failed,omitempty"`        // 压缩失败而丢弃的帧数（ContinueOnError）
+	FramesKept          int              `json:"frames_kept,omitempty"`          // 未超过重新压缩阈值而保留原始数据的帧数
+	Quality             *QualityReport   `json:"quality,omitempty"`              // 画质评估，开启画质报告时填写
+	Pipeline            string           `json:"pipeline,omitempty"`             // 实际使用的处理管线
+	FrameStats          []FrameStat      `json:"frame_stats,omitempty"`          // 逐帧压缩统计（webpmux管线）
+	Method              int              `json:"method,omitempty"`               // 实际使用的压缩方法(-m)，指定截止时间时可能低于配置值
+	Suggested           *LearnedSettings `json:"suggested,omitempty"`            // 相似输入的历史最佳设置（Learn非空且有匹配时）
+	ExperimentGroup     string           `json:"experiment_group,omitempty"`     // 配置了A/B实验时任务所在的分组
+	DuplicateFrames     int              `json:"duplicate_frames,omitempty"`     // 压缩结果与之前某帧字节相同的帧数
+	Cached              bool             `json:"cached,omitempty"`               // 输出取自相同输入和设置的缓存结果
+	Fallback            string           `json:"fallback,omitempty"`             // 处理管线失败后成功的回退策略，见Fallback*常量
+	UnsupportedFeatures []string         `json:"unsupported_features,omitempty"` // 输入中处理管线无法重现的RIFF块，按策略原样输出时填写
+	FramesRepositioned  int              `json:"frames_repositioned,omitempty"`  // 区域超出画布而被移回画布内的帧数
+	EstimatedGIFSize    int64            `json:"estimated_gif_size,omitempty"`   // 等价GIF动画的估计大小，开启GIFEstimate时填写
+	ParallelWorkers     int              `json:"parallel_workers"`               // 使用的并行工作者数量
 }
 
 // CalculateCompressionRatio 计算压缩率
@@ -94,12 +486,67 @@ type ParallelProcessor interface {
 // FrameProcessor 帧处理器函数类型
 type FrameProcessor func(ctx context.Context, frame *FrameInfo) error
 
+// FrameEncoder 逐帧编码后端接口，按CompressionConfig.Encoder的名称选择
+type FrameEncoder interface {
+	// Name 返回编码后端名称，见Encoder*常量
+	Name() string
+
+	// Available 检查编码后端在本机可用
+	Available(ctx context.Context) error
+
+	// EncodeFrame 按配置把单帧编码为WebP
+	EncodeFrame(ctx context.Context, frame *FrameInfo, outputPath string, config *CompressionConfig) error
+}
+
+// FrameError 单帧处理失败
+type FrameError struct {
+	Frame *FrameInfo
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("第%d帧: %v", e.Frame.Index, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// FrameErrors 聚合多个帧的处理错误，按帧序号排列
+type FrameErrors []*FrameError
+
+func (e FrameErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d帧处理失败: %s", len(e), strings.Join(messages, "; "))
+}
+
+func (e FrameErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Err 没有失败的帧时返回nil，避免把空的FrameErrors当作非nil错误返回
+func (e FrameErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
 // WorkerPool 工作池
 type WorkerPool struct {
 	maxWorkers int
 	jobs       chan *FrameInfo
-	results    chan error
 	wg         sync.WaitGroup
+
+	mu     sync.Mutex
+	errors FrameErrors
 }
 
 // NewWorkerPool 创建工作池
@@ -107,7 +554,6 @@ func NewWorkerPool(maxWorkers int) *WorkerPool {
 	return &WorkerPool{
 		maxWorkers: maxWorkers,
 		jobs:       make(chan *FrameInfo, maxWorkers*2),
-		results:    make(chan error, maxWorkers*2),
 	}
 }
 
@@ -119,9 +565,14 @@ func (wp *WorkerPool) Start(ctx context.Context, processor FrameProcessor) {
 	}
 }
 
-// Submit 提交任务
-func (wp *WorkerPool) Submit(frame *FrameInfo) {
-	wp.jobs <- frame
+// Submit 提交任务，队列已满时等待，上下文取消后不再等待并返回其错误
+func (wp *WorkerPool) Submit(ctx context.Context, frame *FrameInfo) error {
+	select {
+	case wp.jobs <- frame:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Close 关闭工作池
@@ -129,36 +580,50 @@ func (wp *WorkerPool) Close() {
 	close(wp.jobs)
 }
 
-// Wait 等待所有任务完成
-func (wp *WorkerPool) Wait() []error {
+// Wait 等待所有任务完成，返回所有失败帧的错误，处理成功的帧不受影响
+func (wp *WorkerPool) Wait() FrameErrors {
 	wp.wg.Wait()
-	close(wp.results)
 
-	var errors []error
-	for err := range wp.results {
-		if err != nil {
-			errors = append(errors, err)
-		}
-	}
-	return errors
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	failed := wp.errors
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Frame.Index < failed[j].Frame.Index })
+	return failed
 }
 
-// worker 工作者
+// fail 记录一帧的处理错误
+func (wp *WorkerPool) fail(frame *FrameInfo, err error) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.errors = append(wp.errors, &FrameError{Frame: frame, Err: err})
+}
+
+// worker 工作者，上下文取消后继续取出已排队的任务但不再处理，记为取消错误
 func (wp *WorkerPool) worker(ctx context.Context, processor FrameProcessor) {
 	defer wp.wg.Done()
 
 	for frame := range wp.jobs {
-		select {
-		case <-ctx.Done():
-			wp.results <- ctx.Err()
-			return
-		default:
-			err := processor(ctx, frame)
-			wp.results <- err
+		if err := ctx.Err(); err != nil {
+			wp.fail(frame, err)
+			continue
+		}
+		if err := wp.process(ctx, processor, frame); err != nil {
+			wp.fail(frame, err)
 		}
 	}
 }
 
+// process 处理一帧，处理器panic时转换为该帧的错误并保留panic时的调用栈，其余帧继续处理
+func (wp *WorkerPool) process(ctx context.Context, processor FrameProcessor, frame *FrameInfo) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(errors.ErrorTypeInternal, "FRAME_PANIC", fmt.Sprintf("处理帧时发生panic: %v", r)).
+				WithContext("frame", frame.Index)
+		}
+	}()
+	return processor(ctx, frame)
+}
+
 // BatchProcessor 批量处理器接口
 type BatchProcessor interface {
 	// ProcessBatch 批量处理多个文件
@@ -171,6 +636,30 @@ type BatchProcessor interface {
 // ProgressCallback 进度回调函数类型
 type ProgressCallback func(completed, total int, currentFile string)
 
+// 压缩流程的处理阶段
+const (
+	StageExtract  = "extract"  // 提取帧
+	StageCompress = "compress" // 压缩帧
+	StageAssemble = "assemble" // 组装动画
+)
+
+// StageProgress 表示某一处理阶段的进度
+type StageProgress struct {
+	Stage      string `json:"stage"`
+	FrameIndex int    `json:"frame_index,omitempty"` // 刚完成的帧序号，整体完成的阶段为0
+	Completed  int    `json:"completed"`
+	Total      int    `json:"total"` // 边解析边处理时帧总数未知，为0
+
+	Heartbeat   bool          `json:"heartbeat,omitempty"`    // 单个工具长时间运行期间的心跳，Completed不变
+	Elapsed     time.Duration `json:"elapsed,omitempty"`      // 心跳时工具已运行的时长
+	OutputBytes int64         `json:"output_bytes,omitempty"` // 心跳时输出文件的当前大小，未知时为0
+}
+
+// StageProgressCallback 阶段进度回调函数类型
+//
+// 同一阶段内的调用是串行的，但流水线中不同阶段可能并发回调
+type StageProgressCallback func(progress StageProgress)
+
 // WebPProcessor 定义WebP处理接口
 type WebPProcessor interface {
 	// ParseAnimation 解析WebP动画信息
@@ -190,6 +679,15 @@ type WebPProcessor interface {
 
 	// CompressAnimation 完整的动画压缩流程
 	CompressAnimation(ctx context.Context, inputPath, outputPath string, config *CompressionConfig) (*CompressResult, error)
+
+	// ConvertAnimation 将WebP动画导出为其他动画格式
+	ConvertAnimation(ctx context.Context, inputPath, outputPath string, format OutputFormat) (*CompressResult, error)
+
+	// DecomposeAnimation 将动画逐帧导出并生成帧清单
+	DecomposeAnimation(ctx context.Context, inputPath, outputDir string, format string, fullCanvas bool) (*FrameManifest, error)
+
+	// ComposeAnimation 将图像序列合成为动画
+	ComposeAnimation(ctx context.Context, frames []*FrameInfo, outputPath string, loopCount int, config *CompressionConfig) (*CompressResult, error)
 }
 
 // ToolExecutor 定义工具执行接口
@@ -200,6 +698,9 @@ type ToolExecutor interface {
 	// ExecuteCommandWithOutput 执行命令并返回输出
 	ExecuteCommandWithOutput(ctx context.Context, toolName string, args ...string) (string, error)
 
+	// ExecuteCommandWithLineHandler 执行命令并在标准输出每产生一行时调用handler
+	ExecuteCommandWithLineHandler(ctx context.Context, toolName string, handler func(line string), args ...string) error
+
 	// GetToolPath 获取工具路径
 	GetToolPath(toolName string) string
 
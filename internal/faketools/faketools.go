@@ -0,0 +1,529 @@
+// Package faketools 提供测试用的libwebp工具替身
+//
+// 测试通过Install把测试二进制以工具名链接到一个目录，被测程序以工具名启动它时，
+// TestMain中的Main按工具名处理命令。替身遵循真实工具的命令行格式读写RIFF容器，
+// 但不编解码像素：cwebp原样复制帧数据，anim_dump和dwebp输出按帧着色的纯色PNG，webpinfo只检查容器结构
+package faketools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Tools 替身支持的工具
+var Tools = []string{"webpmux", "cwebp", "dwebp", "anim_dump", "webpinfo"}
+
+// Version 替身报告的工具版本
+const Version = "0.0.0-fake"
+
+// Install 在dir中为每个工具创建指向当前测试二进制的链接，不支持符号链接时复制文件
+func Install(dir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	for _, tool := range Tools {
+		path := filepath.Join(dir, toolFileName(tool))
+		if err := os.Symlink(exe, path); err == nil {
+			continue
+		}
+		if err := copyExecutable(exe, path); err != nil {
+			return fmt.Errorf("安装%s替身失败: %w", tool, err)
+		}
+	}
+	return nil
+}
+
+// Main 当前进程以替身工具名启动时执行命令，返回退出码和true；否则返回false，由测试正常运行
+func Main() (int, bool) {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	args := os.Args[1:]
+
+	var err error
+	switch name {
+	case "webpmux":
+		err = webpmux(args)
+	case "cwebp":
+		err = cwebp(args)
+	case "dwebp":
+		err = dwebp(args)
+	case "anim_dump":
+		err = animDump(args)
+	case "webpinfo":
+		err = webpinfo(args)
+	default:
+		return 0, false
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1, true
+	}
+	return 0, true
+}
+
+func toolFileName(tool string) string {
+	if runtime.GOOS == "windows" {
+		return tool + ".exe"
+	}
+	return tool
+}
+
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// chunk RIFF块
+type chunk struct {
+	id   string
+	data []byte
+}
+
+// frame ANMF帧
+type frame struct {
+	x, y, width, height int
+	duration            int
+	dispose, noBlend    bool
+	chunks              []chunk // ALPH、VP8、VP8L等图像块
+}
+
+// webp 解析后的WebP文件
+type webp struct {
+	width, height int
+	loop          int
+	frames        []frame
+	image         []chunk // 静态图像的图像块
+}
+
+func readWebP(path string) (*webp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%s不是WebP文件", path)
+	}
+	chunks, err := splitChunks(data[12:])
+	if err != nil {
+		return nil, err
+	}
+
+	w := &webp{}
+	for _, c := range chunks {
+		switch c.id {
+		case "VP8X":
+			if len(c.data) < 10 {
+				return nil, fmt.Errorf("VP8X块过短")
+			}
+			w.width = int(uint24(c.data[4:])) + 1
+			w.height = int(uint24(c.data[7:])) + 1
+		case "ANIM":
+			if len(c.data) >= 6 {
+				w.loop = int(binary.LittleEndian.Uint16(c.data[4:6]))
+			}
+		case "ANMF":
+			if len(c.data) < 16 {
+				return nil, fmt.Errorf("ANMF块过短")
+			}
+			sub, err := splitChunks(c.data[16:])
+			if err != nil {
+				return nil, err
+			}
+			w.frames = append(w.frames, frame{
+				x:        int(uint24(c.data[0:])) * 2,
+				y:        int(uint24(c.data[3:])) * 2,
+				width:    int(uint24(c.data[6:])) + 1,
+				height:   int(uint24(c.data[9:])) + 1,
+				duration: int(uint24(c.data[12:])),
+				dispose:  c.data[15]&0x01 != 0,
+				noBlend:  c.data[15]&0x02 != 0,
+				chunks:   sub,
+			})
+		case "ALPH", "VP8 ", "VP8L":
+			w.image = append(w.image, c)
+		}
+	}
+
+	if w.width == 0 && len(w.image) > 0 {
+		w.width, w.height = imageSize(w.image)
+	}
+	return w, nil
+}
+
+func splitChunks(data []byte) ([]chunk, error) {
+	var chunks []chunk
+	for pos := 0; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if pos+8+size > len(data) {
+			return nil, fmt.Errorf("块%q超出文件范围", data[pos:pos+4])
+		}
+		chunks = append(chunks, chunk{id: string(data[pos : pos+4]), data: data[pos+8 : pos+8+size]})
+		pos += 8 + size + size%2
+	}
+	return chunks, nil
+}
+
+func writeWebP(path string, chunks []chunk) error {
+	var body []byte
+	for _, c := range chunks {
+		body = appendChunk(body, c)
+	}
+	out := append([]byte("RIFF"), le32(len(body)+4)...)
+	out = append(out, "WEBP"...)
+	return os.WriteFile(path, append(out, body...), 0644)
+}
+
+func appendChunk(dst []byte, c chunk) []byte {
+	dst = append(dst, c.id...)
+	dst = append(dst, le32(len(c.data))...)
+	dst = append(dst, c.data...)
+	if len(c.data)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// imageSize 从VP8或VP8L位流头读取图像尺寸
+func imageSize(chunks []chunk) (int, int) {
+	for _, c := range chunks {
+		switch {
+		case c.id == "VP8L" && len(c.data) >= 5:
+			bits := binary.LittleEndian.Uint32(c.data[1:5])
+			return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1
+		case c.id == "VP8 " && len(c.data) >= 10:
+			return int(binary.LittleEndian.Uint16(c.data[6:8]) & 0x3fff),
+				int(binary.LittleEndian.Uint16(c.data[8:10]) & 0x3fff)
+		}
+	}
+	return 0, 0
+}
+
+func hasAlpha(chunks []chunk) bool {
+	for _, c := range chunks {
+		if c.id == "ALPH" || c.id == "VP8L" {
+			return true
+		}
+	}
+	return false
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func put24(dst []byte, v int) {
+	dst[0], dst[1], dst[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+func le32(v int) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
+
+// vp8x 构造VP8X块
+func vp8x(width, height int, animation, alpha bool) chunk {
+	data := make([]byte, 10)
+	if animation {
+		data[0] |= 0x02
+	}
+	if alpha {
+		data[0] |= 0x10
+	}
+	put24(data[4:], width-1)
+	put24(data[7:], height-1)
+	return chunk{id: "VP8X", data: data}
+}
+
+// webpmux 支持 -info、-get frame 和 -frame 组装
+func webpmux(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "-version":
+		fmt.Println(Version)
+		return nil
+	case len(args) == 2 && args[0] == "-info":
+		return webpmuxInfo(args[1])
+	case len(args) == 6 && args[0] == "-get" && args[1] == "frame" && args[3] == "-o":
+		return webpmuxGetFrame(args[5], args[2], args[4])
+	case len(args) > 0 && args[0] == "-frame":
+		return webpmuxAssemble(args)
+	}
+	return fmt.Errorf("不支持的参数: %v", args)
+}
+
+func webpmuxInfo(path string) error {
+	w, err := readWebP(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Canvas size: %d x %d\n", w.width, w.height)
+	if len(w.frames) == 0 {
+		fmt.Println("No features present.")
+		return nil
+	}
+	fmt.Println("Features present: animation transparency")
+	fmt.Println("Background color : 0xFFFFFFFF")
+	fmt.Printf("Loop Count : %d\n", w.loop)
+	fmt.Printf("Number of frames: %d\n", len(w.frames))
+	fmt.Println("No.: width height alpha x_offset y_offset duration dispose blend image_size compression")
+	for i, f := range w.frames {
+		alpha, dispose, blend, compression := "no", "none", "yes", "lossy"
+		if hasAlpha(f.chunks) {
+			alpha = "yes"
+		}
+		if f.dispose {
+			dispose = "background"
+		}
+		if f.noBlend {
+			blend = "no"
+		}
+		size := 0
+		for _, c := range f.chunks {
+			size += len(c.data)
+			if c.id == "VP8L" {
+				compression = "lossless"
+			}
+		}
+		fmt.Printf("%3d: %6d %6d %5s %8d %8d %8d %10s %5s %10d %11s\n",
+			i+1, f.width, f.height, alpha, f.x, f.y, f.duration, dispose, blend, size, compression)
+	}
+	fmt.Println()
+	return nil
+}
+
+func webpmuxGetFrame(input, index, output string) error {
+	w, err := readWebP(input)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 1 || n > len(w.frames) {
+		return fmt.Errorf("帧序号无效: %s", index)
+	}
+	f := w.frames[n-1]
+	return writeWebP(output, append([]chunk{vp8x(f.width, f.height, false, hasAlpha(f.chunks))}, f.chunks...))
+}
+
+func webpmuxAssemble(args []string) error {
+	var frames []frame
+	var output string
+	loop := 0
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-frame":
+			if i+2 >= len(args) {
+				return fmt.Errorf("-frame缺少参数")
+			}
+			f, err := readFrame(args[i+1], args[i+2])
+			if err != nil {
+				return err
+			}
+			frames = append(frames, f)
+			i += 2
+		case "-loop":
+			if i+1 >= len(args) {
+				return fmt.Errorf("-loop缺少参数")
+			}
+			loop, _ = strconv.Atoi(args[i+1])
+			i++
+		case "-o":
+			if i+1 >= len(args) {
+				return fmt.Errorf("-o缺少参数")
+			}
+			output = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("不支持的参数: %s", args[i])
+		}
+	}
+	if output == "" || len(frames) == 0 {
+		return fmt.Errorf("缺少帧或输出路径")
+	}
+
+	width, height, alpha := 0, 0, false
+	for _, f := range frames {
+		width = max(width, f.x+f.width)
+		height = max(height, f.y+f.height)
+		alpha = alpha || hasAlpha(f.chunks)
+	}
+
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint32(anim, 0xFFFFFFFF)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loop))
+	chunks := []chunk{vp8x(width, height, true, alpha), {id: "ANIM", data: anim}}
+	for _, f := range frames {
+		header := make([]byte, 16)
+		put24(header[0:], f.x/2)
+		put24(header[3:], f.y/2)
+		put24(header[6:], f.width-1)
+		put24(header[9:], f.height-1)
+		put24(header[12:], f.duration)
+		if f.dispose {
+			header[15] |= 0x01
+		}
+		if f.noBlend {
+			header[15] |= 0x02
+		}
+		data := header
+		for _, c := range f.chunks {
+			data = appendChunk(data, c)
+		}
+		chunks = append(chunks, chunk{id: "ANMF", data: data})
+	}
+	return writeWebP(output, chunks)
+}
+
+// readFrame 读取单帧文件并按 +duration+x+y+dispose(+b|-b) 设置帧参数
+func readFrame(path, params string) (frame, error) {
+	w, err := readWebP(path)
+	if err != nil {
+		return frame{}, err
+	}
+	image := w.image
+	if len(w.frames) > 0 {
+		image = w.frames[0].chunks
+	}
+
+	f := frame{width: w.width, height: w.height, chunks: image}
+	var dispose int
+	blend := params[len(params)-2:]
+	if _, err := fmt.Sscanf(params[:len(params)-2], "+%d+%d+%d+%d", &f.duration, &f.x, &f.y, &dispose); err != nil {
+		return frame{}, fmt.Errorf("帧参数无效: %s", params)
+	}
+	f.dispose = dispose == 1
+	f.noBlend = blend == "-b"
+	return f, nil
+}
+
+// cwebp 把输入的图像块原样写到 -o 指定的输出
+func cwebp(args []string) error {
+	if len(args) == 1 && args[0] == "-version" {
+		fmt.Println(Version)
+		return nil
+	}
+	for i := 1; i+1 < len(args); i++ {
+		if args[i] == "-o" {
+			data, err := os.ReadFile(args[i-1])
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(args[i+1], data, 0644)
+		}
+	}
+	return fmt.Errorf("缺少输入或输出路径: %v", args)
+}
+
+// dwebp 支持 <input> -png -o <output>
+func dwebp(args []string) error {
+	if len(args) == 1 && args[0] == "-version" {
+		fmt.Println(Version)
+		return nil
+	}
+	if len(args) != 4 || args[1] != "-png" || args[2] != "-o" {
+		return fmt.Errorf("不支持的参数: %v", args)
+	}
+	w, err := readWebP(args[0])
+	if err != nil {
+		return err
+	}
+	return writePNG(args[3], w.width, w.height, 0)
+}
+
+// animDump 支持 -folder <dir> -prefix <prefix> <input>，按 <prefix><4位序号>.png 输出完整画布帧
+// webpinfo 只检查RIFF结构和每帧都含有图像块，-quiet时校验通过不输出
+func webpinfo(args []string) error {
+	if len(args) == 1 && args[0] == "-version" {
+		fmt.Println(Version)
+		return nil
+	}
+	quiet := false
+	var files []string
+	for _, arg := range args {
+		switch {
+		case arg == "-quiet":
+			quiet = true
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("不支持的参数: %v", args)
+		default:
+			files = append(files, arg)
+		}
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("不支持的参数: %v", args)
+	}
+
+	w, err := readWebP(files[0])
+	if err != nil {
+		return err
+	}
+	for i, f := range w.frames {
+		if len(f.chunks) == 0 {
+			return fmt.Errorf("第%d帧缺少图像数据", i+1)
+		}
+	}
+	if !quiet {
+		fmt.Printf("File: %s\nNo error detected.\n", files[0])
+	}
+	return nil
+}
+
+func animDump(args []string) error {
+	if len(args) == 1 && args[0] == "-version" {
+		fmt.Println(Version)
+		return nil
+	}
+	if len(args) != 5 || args[0] != "-folder" || args[2] != "-prefix" {
+		return fmt.Errorf("不支持的参数: %v", args)
+	}
+	w, err := readWebP(args[4])
+	if err != nil {
+		return err
+	}
+	count := max(len(w.frames), 1)
+	for i := 0; i < count; i++ {
+		path := filepath.Join(args[1], fmt.Sprintf("%s%04d.png", args[3], i))
+		if err := writePNG(path, w.width, w.height, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePNG 写出按序号着色的纯色PNG，相邻帧颜色不同
+func writePNG(path string, width, height, index int) error {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	c := color.NRGBA{R: uint8(index * 37), G: uint8(index * 91), B: 200, A: 255}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
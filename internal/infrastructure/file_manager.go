@@ -76,7 +76,7 @@ func (f *LocalFileManager) GetFileSize(path string) (int64, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, errors.ErrFileNotFound.WithContext("file", path)
+			return 0, errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", path)
 		}
 		return 0, errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_INFO", "获取文件信息失败")
 	}
@@ -101,7 +101,7 @@ func (f *LocalFileManager) FileExists(path string) bool {
 func (f *LocalFileManager) CopyFile(src, dst string) error {
 	// 检查源文件
 	if !f.FileExists(src) {
-		return errors.ErrFileNotFound.WithContext("file", src)
+		return errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", src)
 	}
 
 	// 打开源文件
@@ -198,11 +198,11 @@ func (s *SafeFileManager) GetFileSize(path string) (int64, error) {
 	}
 
 	// 检查文件大小限制
-	if size > s.config.Processing.MaxFileSize {
+	if size > s.config.Advanced.OptimizationRules.MaxFileSize {
 		s.logger.Warn("文件大小超过限制",
 			"file", path,
 			"size", size,
-			"limit", s.config.Processing.MaxFileSize,
+			"limit", s.config.Advanced.OptimizationRules.MaxFileSize,
 		)
 	}
 
@@ -225,7 +225,7 @@ func (s *SafeFileManager) CopyFile(src, dst string) error {
 		return err
 	}
 
-	if size > s.config.Processing.MaxFileSize {
+	if size > s.config.Advanced.OptimizationRules.MaxFileSize {
 		return errors.New(errors.ErrorTypeValidation, "FILE_TOO_LARGE",
 			"文件大小超过复制限制")
 	}
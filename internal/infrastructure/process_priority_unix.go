@@ -0,0 +1,28 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"os/exec"
+	"syscall"
+
+	"webpcompressor/internal/domain"
+)
+
+// niceLevels 各优先级对应的nice值
+var niceLevels = map[string]int{
+	domain.PriorityLow:  10,
+	domain.PriorityIdle: 19,
+}
+
+// prepareProcessPriority 在启动前设置子进程属性，类Unix系统只能在启动后调整
+func prepareProcessPriority(cmd *exec.Cmd, priority string) {}
+
+// applyProcessPriority 在子进程启动后调整其nice值
+func applyProcessPriority(cmd *exec.Cmd, priority string) error {
+	nice, ok := niceLevels[priority]
+	if !ok || cmd.Process == nil {
+		return nil
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, cmd.Process.Pid, nice)
+}
@@ -0,0 +1,88 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+// processNice 读取进程的nice值，Linux的getpriority系统调用返回20-nice
+func processNice(t *testing.T, pid int) int {
+	t.Helper()
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid)
+	if err != nil {
+		t.Fatalf("getpriority: %v", err)
+	}
+	if runtime.GOOS == "linux" {
+		return 20 - prio
+	}
+	return prio
+}
+
+func TestApplyProcessPriority(t *testing.T) {
+	base := processNice(t, 0)
+	tests := []struct {
+		priority string
+		expected int
+	}{
+		{domain.PriorityNormal, base},
+		{domain.PriorityLow, niceLevels[domain.PriorityLow]},
+		{domain.PriorityIdle, niceLevels[domain.PriorityIdle]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			if base > tt.expected {
+				t.Skipf("当前进程nice值%d高于%d，无权降低", base, tt.expected)
+			}
+			cmd := exec.Command("sleep", "5")
+			prepareProcessPriority(cmd, tt.priority)
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			defer func() {
+				cmd.Process.Kill()
+				cmd.Wait()
+			}()
+
+			if err := applyProcessPriority(cmd, tt.priority); err != nil {
+				t.Fatalf("applyProcessPriority: %v", err)
+			}
+			if got := processNice(t, cmd.Process.Pid); got != tt.expected {
+				t.Errorf("Expected nice %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyProcessPriority_NotStarted(t *testing.T) {
+	if err := applyProcessPriority(exec.Command("sleep", "1"), domain.PriorityIdle); err != nil {
+		t.Errorf("Expected nil for a process that was not started, got %v", err)
+	}
+}
+
+func TestStartCommand_UsesContextPriority(t *testing.T) {
+	if processNice(t, 0) > niceLevels[domain.PriorityIdle] {
+		t.Skip("当前进程nice值已高于idle")
+	}
+	e := createTestExecutor(0, 1)
+	ctx := domain.WithToolPriority(context.Background(), domain.PriorityIdle)
+
+	cmd := exec.Command("sleep", "5")
+	if err := e.startCommand(ctx, cmd, "sleep"); err != nil {
+		t.Fatalf("startCommand: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	if got := processNice(t, cmd.Process.Pid); got != niceLevels[domain.PriorityIdle] {
+		t.Errorf("Expected nice %d, got %d", niceLevels[domain.PriorityIdle], got)
+	}
+}
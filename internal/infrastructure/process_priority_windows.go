@@ -0,0 +1,39 @@
+//go:build windows
+
+package infrastructure
+
+import (
+	"os/exec"
+	"syscall"
+
+	"webpcompressor/internal/domain"
+)
+
+// Windows进程优先级类
+const (
+	belowNormalPriorityClass = 0x00004000
+	idlePriorityClass        = 0x00000040
+)
+
+// priorityClasses 各优先级对应的Windows进程优先级类
+var priorityClasses = map[string]uint32{
+	domain.PriorityLow:  belowNormalPriorityClass,
+	domain.PriorityIdle: idlePriorityClass,
+}
+
+// prepareProcessPriority 通过创建标志让子进程以指定优先级类启动
+func prepareProcessPriority(cmd *exec.Cmd, priority string) {
+	class, ok := priorityClasses[priority]
+	if !ok {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= class
+}
+
+// applyProcessPriority Windows下优先级已在创建时设置
+func applyProcessPriority(cmd *exec.Cmd, priority string) error {
+	return nil
+}
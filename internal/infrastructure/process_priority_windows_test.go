@@ -0,0 +1,46 @@
+//go:build windows
+
+package infrastructure
+
+import (
+	"os/exec"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestPrepareProcessPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		expected uint32
+	}{
+		{domain.PriorityLow, belowNormalPriorityClass},
+		{domain.PriorityIdle, idlePriorityClass},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("cmd")
+		prepareProcessPriority(cmd, tt.priority)
+		if cmd.SysProcAttr == nil || cmd.SysProcAttr.CreationFlags&tt.expected == 0 {
+			t.Errorf("%s: expected creation flag %#x, got %+v", tt.priority, tt.expected, cmd.SysProcAttr)
+		}
+	}
+}
+
+func TestPrepareProcessPriority_Normal(t *testing.T) {
+	cmd := exec.Command("cmd")
+	prepareProcessPriority(cmd, domain.PriorityNormal)
+	if cmd.SysProcAttr != nil {
+		t.Errorf("Expected no process attributes for normal priority, got %+v", cmd.SysProcAttr)
+	}
+}
+
+func TestPrepareProcessPriority_KeepsExistingFlags(t *testing.T) {
+	const createNewProcessGroup = 0x00000200
+	cmd := exec.Command("cmd")
+	prepareProcessPriority(cmd, domain.PriorityLow)
+	cmd.SysProcAttr.CreationFlags |= createNewProcessGroup
+	prepareProcessPriority(cmd, domain.PriorityLow)
+	if cmd.SysProcAttr.CreationFlags != belowNormalPriorityClass|createNewProcessGroup {
+		t.Errorf("Unexpected creation flags %#x", cmd.SysProcAttr.CreationFlags)
+	}
+}
@@ -1,6 +1,8 @@
 package infrastructure
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -15,6 +17,9 @@ import (
 	"webpcompressor/pkg/logger"
 )
 
+// commandWaitDelay 上下文取消并终止子进程后，等待其输出管道关闭的最长时间
+const commandWaitDelay = 2 * time.Second
+
 // LocalToolExecutor 本地工具执行器
 type LocalToolExecutor struct {
 	config    *config.Config
@@ -63,49 +68,56 @@ func (e *LocalToolExecutor) ExecuteCommandWithOutput(ctx context.Context, toolNa
 	return e.executeCommand(ctx, toolName, true, args...)
 }
 
-// executeCommand 执行命令的核心逻辑
+// executeCommand 执行命令的核心逻辑，暂时性失败按配置退避重试
 func (e *LocalToolExecutor) executeCommand(ctx context.Context, toolName string, captureOutput bool, args ...string) (string, error) {
+	retry := e.newRetryPolicy()
+	for {
+		output, transient, err := e.runCommand(ctx, toolName, captureOutput, args...)
+		if err == nil || !transient || !retry.wait(ctx, toolName, err) {
+			return output, err
+		}
+	}
+}
+
+// runCommand 执行一次命令，返回的transient表示失败看起来是暂时性的
+func (e *LocalToolExecutor) runCommand(ctx context.Context, toolName string, captureOutput bool, args ...string) (string, bool, error) {
 	toolPath := e.GetToolPath(toolName)
 
 	// 创建带超时的上下文
-	timeoutCtx, cancel := context.WithTimeout(ctx, e.config.App.Timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, e.commandTimeout())
 	defer cancel()
 
 	// 创建命令
-	cmd := exec.CommandContext(timeoutCtx, toolPath, args...)
-
-	// 设置工作目录
-	if wd, err := os.Getwd(); err == nil {
-		cmd.Dir = wd
-	}
+	cmd := newCommand(timeoutCtx, toolPath, args...)
 
 	e.logger.Debug("执行命令",
 		"tool", toolName,
 		"path", toolPath,
 		"args", strings.Join(args, " "),
-		"timeout", e.config.App.Timeout,
+		"timeout", e.commandTimeout(),
 	)
 
 	startTime := time.Now()
 
-	var output string
+	var output, stderrOutput string
 	var err error
 
 	if captureOutput {
 		// 捕获输出
-		outputBytes, execErr := cmd.Output()
-		output = string(outputBytes)
-		err = execErr
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err = e.startCommand(ctx, cmd, toolName); err == nil {
+			err = cmd.Wait()
+		}
+		output = stdout.String()
+		stderrOutput = stderr.String()
 
 		// 如果出错，尝试获取标准错误输出
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				stderr := string(exitError.Stderr)
-				if stderr != "" {
-					e.logger.Error("命令标准错误输出", "tool", toolName, "stderr", stderr)
-					output = stderr // 将错误信息作为输出返回
-				}
-			}
+		if err != nil && stderr.Len() > 0 {
+			e.logger.Error("命令标准错误输出", "tool", toolName, "stderr", stderrOutput)
+			output = stderrOutput // 将错误信息作为输出返回
 		}
 	} else {
 		// 捕获标准错误以便调试
@@ -113,11 +125,13 @@ func (e *LocalToolExecutor) executeCommand(ctx context.Context, toolName string,
 		cmd.Stderr = &stderr
 
 		// 执行命令
-		err = cmd.Run()
+		if err = e.startCommand(ctx, cmd, toolName); err == nil {
+			err = cmd.Wait()
+		}
 
 		// 如果出错，记录标准错误
+		stderrOutput = stderr.String()
 		if err != nil && stderr.Len() > 0 {
-			stderrOutput := stderr.String()
 			e.logger.Error("命令标准错误输出", "tool", toolName, "stderr", stderrOutput)
 		}
 	}
@@ -125,39 +139,165 @@ func (e *LocalToolExecutor) executeCommand(ctx context.Context, toolName string,
 	duration := time.Since(startTime)
 
 	if err != nil {
-		// 检查是否是超时错误
-		if timeoutCtx.Err() == context.DeadlineExceeded {
-			e.logger.Error("命令执行超时",
-				"tool", toolName,
-				"timeout", e.config.App.Timeout,
-				"duration", duration,
-			)
-			return output, errors.Wrap(err, errors.ErrorTypeExecution, "COMMAND_TIMEOUT", "命令执行超时")
+		transient := timeoutCtx.Err() == nil && isTransientToolError(err, stderrOutput)
+		return output, transient, e.wrapCommandError(timeoutCtx, toolName, toolPath, err, duration)
+	}
+
+	e.logger.Debug("命令执行成功",
+		"tool", toolName,
+		"duration", duration,
+	)
+
+	return output, false, nil
+}
+
+// ExecuteCommandWithLineHandler 执行命令并逐行处理标准输出
+//
+// 只有在还没有任何输出交给handler时才重试暂时性失败，避免handler收到重复的行
+func (e *LocalToolExecutor) ExecuteCommandWithLineHandler(ctx context.Context, toolName string, handler func(line string), args ...string) error {
+	retry := e.newRetryPolicy()
+	for {
+		delivered := false
+		transient, err := e.runCommandWithLineHandler(ctx, toolName, func(line string) {
+			delivered = true
+			handler(line)
+		}, args...)
+		if err == nil || !transient || delivered || !retry.wait(ctx, toolName, err) {
+			return err
 		}
+	}
+}
+
+// runCommandWithLineHandler 执行一次命令并逐行处理标准输出，返回的transient表示失败看起来是暂时性的
+func (e *LocalToolExecutor) runCommandWithLineHandler(ctx context.Context, toolName string, handler func(line string), args ...string) (bool, error) {
+	toolPath := e.GetToolPath(toolName)
+
+	// 创建带超时的上下文
+	timeoutCtx, cancel := context.WithTimeout(ctx, e.commandTimeout())
+	defer cancel()
+
+	cmd := newCommand(timeoutCtx, toolPath, args...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, errors.Wrap(err, errors.ErrorTypeInternal, "STDOUT_PIPE", "创建标准输出管道失败")
+	}
+
+	e.logger.Debug("流式执行命令",
+		"tool", toolName,
+		"path", toolPath,
+		"args", strings.Join(args, " "),
+		"timeout", e.commandTimeout(),
+	)
+
+	startTime := time.Now()
 
-		// 检查是否是工具不存在
-		if isToolNotFoundError(err) {
-			e.logger.Error("工具不存在",
-				"tool", toolName,
-				"path", toolPath,
-			)
-			return output, errors.Wrap(err, errors.ErrorTypeExecution, "TOOL_NOT_FOUND", "工具不存在")
+	if err := e.startCommand(ctx, cmd, toolName); err != nil {
+		transient := timeoutCtx.Err() == nil && isTransientToolError(err, "")
+		return transient, e.wrapCommandError(timeoutCtx, toolName, toolPath, err, time.Since(startTime))
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		handler(scanner.Text())
+	}
+
+	// 必须读完管道后再等待进程退出
+	err = cmd.Wait()
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	duration := time.Since(startTime)
+
+	if err != nil {
+		if stderr.Len() > 0 {
+			e.logger.Error("命令标准错误输出", "tool", toolName, "stderr", stderr.String())
 		}
+		transient := timeoutCtx.Err() == nil && isTransientToolError(err, stderr.String())
+		return transient, e.wrapCommandError(timeoutCtx, toolName, toolPath, err, duration)
+	}
+
+	e.logger.Debug("命令执行成功",
+		"tool", toolName,
+		"duration", duration,
+	)
+
+	return false, nil
+}
+
+// newCommand 创建在上下文取消或超时时被终止的命令，工作目录为当前目录
+//
+// 终止后最多等待commandWaitDelay，避免子进程遗留的输出管道让Wait一直阻塞
+func newCommand(ctx context.Context, toolPath string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, toolPath, args...)
+	cmd.WaitDelay = commandWaitDelay
+
+	if wd, err := os.Getwd(); err == nil {
+		cmd.Dir = wd
+	}
+	return cmd
+}
+
+// startCommand 按上下文中的优先级启动子进程，调整优先级失败只记录警告
+func (e *LocalToolExecutor) startCommand(ctx context.Context, cmd *exec.Cmd, toolName string) error {
+	priority := domain.ToolPriorityFromContext(ctx)
+	prepareProcessPriority(cmd, priority)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
 
-		e.logger.Error("命令执行失败",
+	if err := applyProcessPriority(cmd, priority); err != nil {
+		e.logger.Warn("调整子进程优先级失败", "tool", toolName, "priority", priority, "error", err)
+	}
+	return nil
+}
+
+// wrapCommandError 将命令执行错误归类为超时、工具不存在或执行失败
+func (e *LocalToolExecutor) wrapCommandError(timeoutCtx context.Context, toolName, toolPath string, err error, duration time.Duration) error {
+	// 检查是否是超时错误
+	if timeoutCtx.Err() == context.DeadlineExceeded {
+		e.logger.Error("命令执行超时",
 			"tool", toolName,
-			"error", err,
+			"timeout", e.commandTimeout(),
 			"duration", duration,
 		)
-		return output, errors.Wrap(err, errors.ErrorTypeExecution, "COMMAND_FAILED", "命令执行失败")
+		return errors.Wrap(err, errors.ErrorTypeExecution, "COMMAND_TIMEOUT", "命令执行超时")
 	}
 
-	e.logger.Debug("命令执行成功",
+	// 调用方取消时子进程已被终止
+	if timeoutCtx.Err() == context.Canceled {
+		e.logger.Info("命令已取消，子进程已终止",
+			"tool", toolName,
+			"duration", duration,
+		)
+		return errors.Wrap(err, errors.ErrorTypeExecution, "COMMAND_CANCELLED", "命令已取消")
+	}
+
+	// 检查是否是工具不存在
+	if isToolNotFoundError(err) {
+		e.logger.Error("工具不存在",
+			"tool", toolName,
+			"path", toolPath,
+		)
+		return errors.Wrap(err, errors.ErrorTypeExecution, "TOOL_NOT_FOUND", "工具不存在")
+	}
+
+	e.logger.Error("命令执行失败",
 		"tool", toolName,
+		"error", err,
 		"duration", duration,
 	)
+	return errors.Wrap(err, errors.ErrorTypeExecution, "COMMAND_FAILED", "命令执行失败")
+}
 
-	return output, nil
+// commandTimeout 单次工具调用的超时
+func (e *LocalToolExecutor) commandTimeout() time.Duration {
+	return time.Duration(e.config.Tools.CommandTimeout) * time.Second
 }
 
 // GetToolPath 获取工具路径
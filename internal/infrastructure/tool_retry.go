@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// transientStderrPatterns 工具标准错误输出中表示暂时性失败的片段（小写）
+var transientStderrPatterns = []string{
+	"being used by another process", // Windows文件被其他进程占用
+	"out of memory",
+	"cannot allocate memory",
+	"resource temporarily unavailable",
+	"text file busy",
+}
+
+// isTransientToolError 判断工具失败是否像是暂时性的：文件被锁定、内存不足、进程被OOM终止等
+func isTransientToolError(err error, stderr string) bool {
+	var errno syscall.Errno
+	if errors.As(err, &errno) && transientErrnos[errno] {
+		return true
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && killedByOOM(exitErr) {
+		return true
+	}
+
+	lower := strings.ToLower(stderr)
+	for _, pattern := range transientStderrPatterns {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicy 一次工具调用的暂时性失败重试状态
+type retryPolicy struct {
+	e         *LocalToolExecutor
+	remaining int
+	backoff   time.Duration
+}
+
+// newRetryPolicy 按工具配置创建重试状态
+func (e *LocalToolExecutor) newRetryPolicy() *retryPolicy {
+	return &retryPolicy{
+		e:         e,
+		remaining: e.config.Tools.Retries,
+		backoff:   time.Duration(e.config.Tools.RetryBackoff) * time.Millisecond,
+	}
+}
+
+// wait 还有重试次数时等待退避时间并返回true，等待时间每次翻倍；上下文取消时返回false
+func (r *retryPolicy) wait(ctx context.Context, toolName string, err error) bool {
+	if r.remaining <= 0 {
+		return false
+	}
+	r.remaining--
+
+	r.e.logger.Warn("工具暂时性失败，准备重试",
+		"tool", toolName,
+		"backoff", r.backoff,
+		"remaining", r.remaining,
+		"error", err,
+	)
+
+	timer := time.NewTimer(r.backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		r.backoff *= 2
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
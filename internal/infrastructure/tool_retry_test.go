@@ -0,0 +1,95 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/config"
+	"webpcompressor/pkg/logger"
+)
+
+func createTestExecutor(retries, backoffMillis int) *LocalToolExecutor {
+	cfg := config.DefaultConfig()
+	cfg.Tools.Retries = retries
+	cfg.Tools.RetryBackoff = backoffMillis
+	return NewLocalToolExecutor(cfg, logger.NewDefaultLogger())
+}
+
+func TestIsTransientToolError_Errno(t *testing.T) {
+	for errno := range transientErrnos {
+		// 启动失败时errno包在os.PathError或exec.Error中
+		err := &os.PathError{Op: "fork/exec", Path: "cwebp", Err: errno}
+		if !isTransientToolError(err, "") {
+			t.Errorf("errno %d should be transient", errno)
+		}
+		if !isTransientToolError(&exec.Error{Name: "cwebp", Err: errno}, "") {
+			t.Errorf("wrapped errno %d should be transient", errno)
+		}
+	}
+
+	if isTransientToolError(&os.PathError{Op: "fork/exec", Path: "cwebp", Err: os.ErrNotExist}, "") {
+		t.Error("missing executable should not be transient")
+	}
+}
+
+func TestIsTransientToolError_Stderr(t *testing.T) {
+	err := fmt.Errorf("exit status 1")
+	tests := []struct {
+		stderr   string
+		expected bool
+	}{
+		{"The process cannot access the file because it is being used by another process.", true},
+		{"Error: Out of memory while decoding", true},
+		{"mmap: Cannot allocate memory", true},
+		{"fork: Resource temporarily unavailable", true},
+		{"Text file busy", true},
+		{"Error! Could not process file frame.png", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isTransientToolError(err, tt.stderr); got != tt.expected {
+			t.Errorf("isTransientToolError(%q) = %v, want %v", tt.stderr, got, tt.expected)
+		}
+	}
+}
+
+func TestRetryPolicy_WaitDoublesBackoff(t *testing.T) {
+	retry := createTestExecutor(3, 1).newRetryPolicy()
+	err := fmt.Errorf("busy")
+
+	for _, expected := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond} {
+		if retry.backoff != expected {
+			t.Errorf("Expected backoff %v, got %v", expected, retry.backoff)
+		}
+		if !retry.wait(context.Background(), "cwebp", err) {
+			t.Fatal("wait should allow a retry")
+		}
+	}
+	if retry.wait(context.Background(), "cwebp", err) {
+		t.Error("wait should stop after the configured retries")
+	}
+}
+
+func TestRetryPolicy_WaitContextCancelled(t *testing.T) {
+	retry := createTestExecutor(1, int(time.Hour/time.Millisecond)).newRetryPolicy()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if retry.wait(ctx, "cwebp", fmt.Errorf("busy")) {
+		t.Error("wait should not retry after the context is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait blocked for %v after cancel", elapsed)
+	}
+}
+
+func TestRetryPolicy_NoRetries(t *testing.T) {
+	if createTestExecutor(0, 1).newRetryPolicy().wait(context.Background(), "cwebp", fmt.Errorf("busy")) {
+		t.Error("wait should not retry when retries are disabled")
+	}
+}
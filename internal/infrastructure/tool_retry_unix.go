@@ -0,0 +1,21 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// transientErrnos 启动工具时表示暂时性失败的错误码
+var transientErrnos = map[syscall.Errno]bool{
+	syscall.ETXTBSY: true, // 可执行文件正在被写入
+	syscall.ENOMEM:  true,
+	syscall.EAGAIN:  true, // 进程数或资源暂时不足
+}
+
+// killedByOOM 进程被SIGKILL终止时多半是内存不足被系统终止（上下文取消导致的终止由调用方排除）
+func killedByOOM(exitErr *exec.ExitError) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGKILL
+}
@@ -0,0 +1,127 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestTool 写出一个shell脚本作为工具，每次运行在calls文件中追加一行以统计调用次数
+func writeTestTool(t *testing.T, e *LocalToolExecutor, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	path := filepath.Join(dir, "tool.sh")
+	content := "#!/bin/sh\necho x >> " + calls + "\n" + script + "\n"
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatalf("write tool: %v", err)
+	}
+	e.toolPaths["tool"] = path
+	return calls
+}
+
+func countCalls(t *testing.T, calls string) int {
+	t.Helper()
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestIsTransientToolError_SIGKILL(t *testing.T) {
+	err := exec.Command("/bin/sh", "-c", "kill -9 $$").Run()
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Fatalf("Expected ExitError, got %v", err)
+	}
+	if !isTransientToolError(err, "") {
+		t.Error("process killed by SIGKILL should be transient")
+	}
+
+	err = exec.Command("/bin/sh", "-c", "kill -15 $$").Run()
+	if isTransientToolError(err, "") {
+		t.Error("process killed by SIGTERM should not be transient")
+	}
+
+	err = exec.Command("/bin/sh", "-c", "exit 1").Run()
+	if isTransientToolError(err, "") {
+		t.Error("plain non-zero exit should not be transient")
+	}
+}
+
+func TestExecuteCommand_RetriesTransientFailure(t *testing.T) {
+	e := createTestExecutor(2, 1)
+	calls := writeTestTool(t, e, `
+n=$(wc -l < "$(dirname "$0")/calls")
+if [ "$n" -lt 2 ]; then echo "out of memory" >&2; exit 1; fi
+echo ok`)
+
+	output, err := e.ExecuteCommandWithOutput(context.Background(), "tool")
+	if err != nil {
+		t.Fatalf("Expected success after retry, got %v", err)
+	}
+	if strings.TrimSpace(output) != "ok" {
+		t.Errorf("Expected output ok, got %q", output)
+	}
+	if n := countCalls(t, calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+}
+
+func TestExecuteCommand_NoRetryOnPermanentFailure(t *testing.T) {
+	e := createTestExecutor(2, 1)
+	calls := writeTestTool(t, e, `echo "Error! Could not process file" >&2; exit 1`)
+
+	if err := e.ExecuteCommand(context.Background(), "tool"); err == nil {
+		t.Fatal("Expected error")
+	}
+	if n := countCalls(t, calls); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestExecuteCommandWithLineHandler_RetriesBeforeOutput(t *testing.T) {
+	e := createTestExecutor(2, 1)
+	calls := writeTestTool(t, e, `
+n=$(wc -l < "$(dirname "$0")/calls")
+if [ "$n" -lt 2 ]; then echo "text file busy" >&2; exit 1; fi
+echo line1
+echo line2`)
+
+	var lines []string
+	if err := e.ExecuteCommandWithLineHandler(context.Background(), "tool", func(line string) {
+		lines = append(lines, line)
+	}); err != nil {
+		t.Fatalf("Expected success after retry, got %v", err)
+	}
+	if strings.Join(lines, ",") != "line1,line2" {
+		t.Errorf("Expected each line once, got %v", lines)
+	}
+	if n := countCalls(t, calls); n != 2 {
+		t.Errorf("Expected 2 calls, got %d", n)
+	}
+}
+
+func TestExecuteCommandWithLineHandler_NoRetryAfterPartialOutput(t *testing.T) {
+	e := createTestExecutor(2, 1)
+	calls := writeTestTool(t, e, `echo line1; echo "out of memory" >&2; exit 1`)
+
+	var lines []string
+	err := e.ExecuteCommandWithLineHandler(context.Background(), "tool", func(line string) {
+		lines = append(lines, line)
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if len(lines) != 1 || lines[0] != "line1" {
+		t.Errorf("Expected line1 delivered exactly once, got %v", lines)
+	}
+	if n := countCalls(t, calls); n != 1 {
+		t.Errorf("Expected no retry after output was delivered, got %d calls", n)
+	}
+}
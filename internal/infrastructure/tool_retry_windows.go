@@ -0,0 +1,21 @@
+//go:build windows
+
+package infrastructure
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// transientErrnos 启动工具时表示暂时性失败的Windows错误码
+var transientErrnos = map[syscall.Errno]bool{
+	8:  true, // ERROR_NOT_ENOUGH_MEMORY
+	14: true, // ERROR_OUTOFMEMORY
+	32: true, // ERROR_SHARING_VIOLATION，文件被其他进程占用
+	33: true, // ERROR_LOCK_VIOLATION
+}
+
+// killedByOOM Windows下无法从退出状态区分内存不足，依赖标准错误输出判断
+func killedByOOM(exitErr *exec.ExitError) bool {
+	return false
+}
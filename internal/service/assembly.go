@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// AssemblyManifestFile 组装清单文件名
+const AssemblyManifestFile = "assembly.json"
+
+// assemblyManifestBuilder 在压缩阶段逐帧记录组装清单，可并发使用
+type assemblyManifestBuilder struct {
+	mu      sync.Mutex
+	entries []domain.AssemblyEntry
+	failed  domain.FrameErrors
+
+	sizeLimit int64 // 输出大小上限，0表示不限制
+	expected  int   // 预计暂存的帧数，未知时为0
+	staged    int64 // 已暂存帧的大小之和
+}
+
+// newAssemblyManifestBuilder 创建组装清单构建器
+func newAssemblyManifestBuilder() *assemblyManifestBuilder {
+	return &assemblyManifestBuilder{}
+}
+
+// add 记录一帧压缩结果，checksum为空表示未能计算
+func (b *assemblyManifestBuilder) add(frame *domain.FrameInfo, size int64, checksum string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.staged += size
+	b.entries = append(b.entries, domain.AssemblyEntry{
+		Index:    frame.Index,
+		File:     frame.Path,
+		Size:     size,
+		Checksum: checksum,
+		Duration: int(frame.Duration.Milliseconds()),
+		X:        frame.X,
+		Y:        frame.Y,
+		Width:    frame.Width,
+		Height:   frame.Height,
+		Dispose:  frame.Dispose,
+		Blend:    frame.Blend,
+	})
+}
+
+// limitOutputSize 设置输出大小上限，0表示不限制
+func (b *assemblyManifestBuilder) limitOutputSize(limit int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sizeLimit = limit
+}
+
+// setExpectedFrames 设置预计暂存的帧数，用于预测输出总大小
+func (b *assemblyManifestBuilder) setExpectedFrames(count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expected = count
+}
+
+// checkOutputSize 按已暂存帧的平均大小预测输出总大小，超过上限时返回OUTPUT_TOO_LARGE
+//
+// 首帧通常是完整画布，暂存sizeGuardMinFrames帧之前只比较已暂存的大小
+func (b *assemblyManifestBuilder) checkOutputSize() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sizeLimit <= 0 {
+		return nil
+	}
+	projected := b.staged
+	if count := len(b.entries); b.expected > count && count >= sizeGuardMinFrames {
+		projected = b.staged * int64(b.expected) / int64(count)
+	}
+	if projected > b.sizeLimit {
+		return outputTooLargeError(projected, b.sizeLimit)
+	}
+	return nil
+}
+
+// skip 记录一帧因处理失败被丢弃
+func (b *assemblyManifestBuilder) skip(frame *domain.FrameInfo, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failed = append(b.failed, &domain.FrameError{Frame: frame, Err: err})
+}
+
+// failures 返回被丢弃帧的错误，按帧序号排列
+func (b *assemblyManifestBuilder) failures() domain.FrameErrors {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := make(domain.FrameErrors, len(b.failed))
+	copy(failed, b.failed)
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Frame.Index < failed[j].Frame.Index })
+	return failed
+}
+
+// build 按帧序号排序生成清单，被丢弃帧的时长并入前一帧（第一帧被丢弃时并入后一帧），保持总时长不变
+func (b *assemblyManifestBuilder) build(outputPath string, loopCount int) *domain.AssemblyManifest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]domain.AssemblyEntry, len(b.entries))
+	copy(entries, b.entries)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Index < entries[j].Index })
+
+	if len(entries) > 0 {
+		for _, failure := range b.failed {
+			target := 0
+			for i, entry := range entries {
+				if entry.Index < failure.Frame.Index {
+					target = i
+				}
+			}
+			entries[target].Duration += int(failure.Frame.Duration.Milliseconds())
+		}
+	}
+
+	return &domain.AssemblyManifest{
+		Output: outputPath,
+		Loop:   loopCount,
+		Frames: entries,
+	}
+}
+
+// stageFrame 组装前暂存检查：确认压缩后的帧文件存在且非空，并记录到清单
+func (s *WebPService) stageFrame(builder *assemblyManifestBuilder, frame *domain.FrameInfo) error {
+	if !s.fileManager.FileExists(frame.Path) {
+		return errors.New(errors.ErrorTypeIO, "FRAME_FILE_NOT_FOUND",
+			fmt.Sprintf("帧文件不存在: %s (索引: %d)", frame.Path, frame.Index))
+	}
+
+	size, err := s.fileManager.GetFileSize(frame.Path)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "FRAME_FILE_NOT_FOUND",
+			fmt.Sprintf("帧文件不可用: %s (索引: %d)", frame.Path, frame.Index))
+	}
+	if size == 0 {
+		return errors.New(errors.ErrorTypeIO, "EMPTY_FRAME_FILE",
+			fmt.Sprintf("帧文件为空: %s (索引: %d)", frame.Path, frame.Index))
+	}
+
+	checksum, err := fileChecksum(frame.Path)
+	if err != nil {
+		s.logger.Debug("计算帧文件校验和失败，不参与重复帧检查", "path", frame.Path, "error", err)
+	}
+
+	builder.add(frame, size, checksum)
+	s.logger.Debug("帧文件已暂存",
+		"index", frame.Index,
+		"path", frame.Path,
+		"size", size,
+	)
+	return builder.checkOutputSize()
+}
+
+// fileChecksum 计算文件内容的SHA-256
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fitManifestToCanvas 检查各帧区域是否超出画布，fix为true时把能放进画布的帧平移回画布内，返回移动的帧数
+//
+// 部分编码器输出的偏移加尺寸超出画布，webpmux照样组装但浏览器渲染错误；比画布还大的帧无法平移，返回FRAME_OUT_OF_BOUNDS
+func fitManifestToCanvas(manifest *domain.AssemblyManifest, width, height int, fix bool) (int, error) {
+	moved := 0
+	for i := range manifest.Frames {
+		entry := &manifest.Frames[i]
+		if entry.X+entry.Width <= width && entry.Y+entry.Height <= height {
+			continue
+		}
+		if !fix || entry.Width > width || entry.Height > height {
+			return moved, errors.New(errors.ErrorTypeValidation, "FRAME_OUT_OF_BOUNDS",
+				fmt.Sprintf("第%d帧区域 %dx%d+%d+%d 超出画布 %dx%d",
+					entry.Index, entry.Width, entry.Height, entry.X, entry.Y, width, height))
+		}
+
+		// ANMF中偏移按2像素存储，向下取偶数仍在画布内
+		if entry.X+entry.Width > width {
+			entry.X = (width - entry.Width) &^ 1
+		}
+		if entry.Y+entry.Height > height {
+			entry.Y = (height - entry.Height) &^ 1
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// dedupeManifest 按校验和找出压缩结果字节相同的帧，返回重复帧数和合并掉的帧数
+//
+// WebP容器无法让两帧共享数据，重复帧改为引用第一次出现的文件；相邻的重复帧在位置相同、
+// 前一帧不清除背景且后一帧不混合时，再画一次不会改变画面，此时把后一帧的时长并入前一帧，
+// 合并后的帧沿用后一帧的处理方式，保证下一帧绘制前画布按原样清除
+func dedupeManifest(manifest *domain.AssemblyManifest) (duplicates, merged int) {
+	first := make(map[string]string)
+	kept := manifest.Frames[:0]
+	for _, entry := range manifest.Frames {
+		if entry.Checksum == "" {
+			kept = append(kept, entry)
+			continue
+		}
+
+		file, seen := first[entry.Checksum]
+		if !seen {
+			first[entry.Checksum] = entry.File
+			kept = append(kept, entry)
+			continue
+		}
+
+		duplicates++
+		entry.File = file
+		if len(kept) > 0 {
+			prev := &kept[len(kept)-1]
+			if prev.Checksum == entry.Checksum && prev.X == entry.X && prev.Y == entry.Y &&
+				prev.Dispose == domain.DisposeNone && entry.Blend == domain.BlendNo &&
+				prev.Duration+entry.Duration <= maxFrameDuration {
+				prev.Duration += entry.Duration
+				prev.Dispose = entry.Dispose
+				merged++
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+
+	manifest.Frames = kept
+	return duplicates, merged
+}
+
+// validateAssemblyManifest 组装前校验清单
+func (s *WebPService) validateAssemblyManifest(manifest *domain.AssemblyManifest) error {
+	if len(manifest.Frames) == 0 {
+		return errors.New(errors.ErrorTypeValidation, "EMPTY_ASSEMBLY_MANIFEST", "组装清单中没有帧")
+	}
+	if manifest.Output == "" {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_ASSEMBLY_MANIFEST", "组装清单缺少输出路径")
+	}
+
+	seen := make(map[int]bool, len(manifest.Frames))
+	for _, entry := range manifest.Frames {
+		if seen[entry.Index] {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_ASSEMBLY_MANIFEST",
+				fmt.Sprintf("帧序号重复: %d", entry.Index))
+		}
+		seen[entry.Index] = true
+
+		// 记录后文件不应再被修改
+		size, err := s.fileManager.GetFileSize(entry.File)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrorTypeIO, "FRAME_FILE_NOT_FOUND",
+				fmt.Sprintf("帧文件不存在: %s (索引: %d)", entry.File, entry.Index))
+		}
+		if size != entry.Size {
+			return errors.New(errors.ErrorTypeValidation, "FRAME_FILE_CHANGED",
+				fmt.Sprintf("帧文件在记录后被修改: %s (记录: %d, 当前: %d)", entry.File, entry.Size, size))
+		}
+	}
+
+	return nil
+}
+
+// buildAssemblyArgs 由组装清单生成webpmux参数
+func buildAssemblyArgs(manifest *domain.AssemblyManifest) ([]string, error) {
+	muxArgs := &webpmuxAssembleArgs{
+		Frames: make([]webpmuxFrame, len(manifest.Frames)),
+		Loop:   manifest.Loop,
+		Output: manifest.Output,
+	}
+	for i, entry := range manifest.Frames {
+		muxArgs.Frames[i] = webpmuxFrame{
+			File:     entry.File,
+			Duration: entry.Duration,
+			X:        entry.X,
+			Y:        entry.Y,
+			Dispose:  entry.Dispose,
+			Blend:    entry.Blend,
+		}
+	}
+	return muxArgs.Render()
+}
+
+// writeAssemblyManifest 将组装清单写入目录，便于审计和重放
+func writeAssemblyManifest(manifest *domain.AssemblyManifest, dir string) (string, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeInternal, "ENCODE_MANIFEST", "序列化组装清单失败")
+	}
+
+	path := filepath.Join(dir, AssemblyManifestFile)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "WRITE_MANIFEST", "写入组装清单失败")
+	}
+	return path, nil
+}
+
+// assembleFromManifest 校验组装清单并调用webpmux组装动画
+//
+// manifestDir非空时先把清单写入该目录，写入失败只记录警告
+func (s *WebPService) assembleFromManifest(ctx context.Context, manifest *domain.AssemblyManifest, manifestDir string) error {
+	s.logger.Info("开始重新组装动画", "output", manifest.Output, "total_frames", len(manifest.Frames))
+
+	if err := s.validateAssemblyManifest(manifest); err != nil {
+		return err
+	}
+
+	if manifestDir != "" {
+		if path, err := writeAssemblyManifest(manifest, manifestDir); err != nil {
+			s.logger.Warn("写入组装清单失败", "dir", manifestDir, "error", err)
+		} else {
+			s.logger.Debug("组装清单已写入", "path", path)
+		}
+	}
+
+	// 确保输出目录存在
+	outputDir := filepath.Dir(manifest.Output)
+	if outputDir != "." && outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT_DIR",
+				fmt.Sprintf("创建输出目录失败: %s", outputDir))
+		}
+		s.logger.Debug("创建输出目录", "dir", outputDir)
+	}
+
+	args, err := buildAssemblyArgs(manifest)
+	if err != nil {
+		return err
+	}
+
+	// 记录完整的命令
+	s.logger.Info("执行webpmux命令",
+		"args", strings.Join(args, " "),
+		"total_frames", len(manifest.Frames),
+	)
+
+	// 文件被锁定、内存不足等暂时性失败由工具执行器重试，webpmux的标准错误输出保留在错误详情中
+	stderr, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "webpmux", args...)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExecution, "ASSEMBLE_ANIMATION", "重新组装动画失败").
+			WithDetails(stderr)
+	}
+	return nil
+}
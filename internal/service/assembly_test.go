@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestAssembleFromManifest_ArgsAndManifestFile(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	builder := newAssemblyManifestBuilder()
+	// 乱序记录，清单按帧序号排序
+	frames := []*domain.FrameInfo{
+		{Index: 2, Path: "frame_compressed_2.webp", Duration: 50 * time.Millisecond, X: 4, Y: 2, Dispose: domain.DisposeBackground, Blend: domain.BlendYes},
+		{Index: 1, Path: "frame_compressed_1.webp", Duration: 100 * time.Millisecond},
+	}
+	for _, frame := range frames {
+		if err := service.stageFrame(builder, frame); err != nil {
+			t.Fatalf("stageFrame failed: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	manifest := builder.build("out.webp", 3)
+	if err := service.assembleFromManifest(context.Background(), manifest, dir); err != nil {
+		t.Fatalf("assembleFromManifest failed: %v", err)
+	}
+
+	expected := "webpmux -frame frame_compressed_1.webp +100+0+0+0-b -frame frame_compressed_2.webp +50+4+2+1+b -loop 3 -o out.webp"
+	if len(mockToolExecutor.commands) != 1 || mockToolExecutor.commands[0] != expected {
+		t.Errorf("Unexpected commands: %v", mockToolExecutor.commands)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, AssemblyManifestFile))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var replay domain.AssemblyManifest
+	if err := json.Unmarshal(data, &replay); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	// 写出的清单可以重放出相同的参数
+	original, _ := buildAssemblyArgs(manifest)
+	replayed, err := buildAssemblyArgs(&replay)
+	if err != nil || strings.Join(replayed, " ") != strings.Join(original, " ") {
+		t.Errorf("Replayed args differ: %v (%v)", replayed, err)
+	}
+}
+
+func TestValidateAssemblyManifest_FileChanged(t *testing.T) {
+	service := createTestWebPService()
+	mockFileManager := service.fileManager.(*MockFileManager)
+
+	builder := newAssemblyManifestBuilder()
+	frame := &domain.FrameInfo{Index: 1, Path: "frame_compressed_1.webp", Duration: 100 * time.Millisecond}
+	if err := service.stageFrame(builder, frame); err != nil {
+		t.Fatalf("stageFrame failed: %v", err)
+	}
+
+	mockFileManager.SetFileSize(frame.Path, 10)
+
+	err := service.validateAssemblyManifest(builder.build("out.webp", 0))
+	if !errors.IsCode(err, "FRAME_FILE_CHANGED") {
+		t.Errorf("Expected FRAME_FILE_CHANGED, got %v", err)
+	}
+}
+
+func TestValidateAssemblyManifest_DuplicateIndex(t *testing.T) {
+	service := createTestWebPService()
+
+	manifest := &domain.AssemblyManifest{
+		Output: "out.webp",
+		Frames: []domain.AssemblyEntry{
+			{Index: 1, File: "a.webp", Size: 1024},
+			{Index: 1, File: "b.webp", Size: 1024},
+		},
+	}
+
+	err := service.validateAssemblyManifest(manifest)
+	if !errors.IsCode(err, "INVALID_ASSEMBLY_MANIFEST") {
+		t.Errorf("Expected INVALID_ASSEMBLY_MANIFEST, got %v", err)
+	}
+}
+
+func TestAssembleFromManifest_KeepsStderr(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	manifest := &domain.AssemblyManifest{
+		Output: "out.webp",
+		Frames: []domain.AssemblyEntry{{Index: 1, File: "a.webp", Size: 1024, Duration: 100}},
+	}
+	args, err := buildAssemblyArgs(manifest)
+	if err != nil {
+		t.Fatalf("buildAssemblyArgs failed: %v", err)
+	}
+	command := "webpmux " + strings.Join(args, " ")
+	mockToolExecutor.SetMockError(command, fmt.Errorf("exit status 1"))
+	mockToolExecutor.SetMockOutput(command, "Failed to create mux object")
+
+	err = service.assembleFromManifest(context.Background(), manifest, "")
+	if !errors.IsCode(err, "ASSEMBLE_ANIMATION") {
+		t.Fatalf("Expected ASSEMBLE_ANIMATION, got %v", err)
+	}
+	// 暂时性失败由工具执行器重试，组装本身不再重试
+	if len(mockToolExecutor.commands) != 1 {
+		t.Errorf("Expected 1 attempt, got %d", len(mockToolExecutor.commands))
+	}
+	if appErr := err.(*errors.AppError); appErr.Details != "Failed to create mux object" {
+		t.Errorf("Expected stderr in details, got %q", appErr.Details)
+	}
+}
+
+func TestDedupeManifest_ReferencesAndMergesDuplicates(t *testing.T) {
+	service := createTestWebPService()
+	dir := t.TempDir()
+
+	contents := []string{"loop-a", "loop-a", "loop-b", "loop-a"}
+	builder := newAssemblyManifestBuilder()
+	for i, content := range contents {
+		path := filepath.Join(dir, fmt.Sprintf("frame_compressed_%d.webp", i+1))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write frame: %v", err)
+		}
+		frame := &domain.FrameInfo{Index: i + 1, Path: path, Duration: 100 * time.Millisecond}
+		if err := service.stageFrame(builder, frame); err != nil {
+			t.Fatalf("stageFrame failed: %v", err)
+		}
+	}
+
+	manifest := builder.build("out.webp", 0)
+	duplicates, merged := dedupeManifest(manifest)
+	if duplicates != 2 || merged != 1 {
+		t.Fatalf("Expected 2 duplicates and 1 merged, got %d and %d", duplicates, merged)
+	}
+
+	// 帧2与帧1相邻且相同，时长并入帧1；帧4与帧1相同但不相邻，改为引用帧1的文件
+	if len(manifest.Frames) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", manifest.Frames)
+	}
+	first := manifest.Frames[0].File
+	if manifest.Frames[0].Duration != 200 {
+		t.Errorf("Expected merged duration 200, got %d", manifest.Frames[0].Duration)
+	}
+	if last := manifest.Frames[2]; last.Index != 4 || last.File != first {
+		t.Errorf("Expected frame 4 to reference %s, got %+v", first, last)
+	}
+}
+
+func TestDedupeManifest_KeepsFramesThatRedraw(t *testing.T) {
+	manifest := &domain.AssemblyManifest{
+		Output: "out.webp",
+		Frames: []domain.AssemblyEntry{
+			{Index: 1, File: "a.webp", Checksum: "same", Duration: 100, Dispose: domain.DisposeBackground},
+			{Index: 2, File: "b.webp", Checksum: "same", Duration: 100},
+			{Index: 3, File: "c.webp", Duration: 100},
+		},
+	}
+
+	// 前一帧会清除背景，重复帧必须保留才能重新绘制
+	duplicates, merged := dedupeManifest(manifest)
+	if duplicates != 1 || merged != 0 || len(manifest.Frames) != 3 {
+		t.Fatalf("Unexpected result: %d duplicates, %d merged, %+v", duplicates, merged, manifest.Frames)
+	}
+	if manifest.Frames[1].File != "a.webp" {
+		t.Errorf("Expected duplicate to reference a.webp, got %s", manifest.Frames[1].File)
+	}
+}
+
+func TestDedupeManifest_MergeKeepsLaterDispose(t *testing.T) {
+	manifest := &domain.AssemblyManifest{
+		Output: "out.webp",
+		Frames: []domain.AssemblyEntry{
+			{Index: 1, File: "a.webp", Checksum: "same", Duration: 100, Width: 100, Height: 100},
+			{Index: 2, File: "b.webp", Checksum: "same", Duration: 100, Width: 100, Height: 100, Dispose: domain.DisposeBackground},
+			{Index: 3, File: "c.webp", Duration: 100, X: 20, Y: 20, Width: 40, Height: 40, Blend: domain.BlendYes},
+		},
+	}
+
+	// 帧2并入帧1后仍须在帧3绘制前清除背景，否则帧3会叠加在旧画面上
+	duplicates, merged := dedupeManifest(manifest)
+	if duplicates != 1 || merged != 1 || len(manifest.Frames) != 2 {
+		t.Fatalf("Unexpected result: %d duplicates, %d merged, %+v", duplicates, merged, manifest.Frames)
+	}
+	if first := manifest.Frames[0]; first.Duration != 200 || first.Dispose != domain.DisposeBackground {
+		t.Errorf("Expected merged frame with duration 200 and background dispose, got %+v", first)
+	}
+}
+
+func TestFitManifestToCanvas(t *testing.T) {
+	newManifest := func() *domain.AssemblyManifest {
+		return &domain.AssemblyManifest{
+			Output: "out.webp",
+			Frames: []domain.AssemblyEntry{
+				{Index: 1, File: "a.webp", Width: 100, Height: 100},
+				{Index: 2, File: "b.webp", X: 60, Y: 40, Width: 50, Height: 71},
+			},
+		}
+	}
+
+	manifest := newManifest()
+	moved, err := fitManifestToCanvas(manifest, 100, 100, true)
+	if err != nil || moved != 1 {
+		t.Fatalf("Expected 1 frame moved, got %d (%v)", moved, err)
+	}
+	// 偏移取偶数且帧仍在画布内
+	if entry := manifest.Frames[1]; entry.X != 50 || entry.Y != 28 {
+		t.Errorf("Expected frame 2 at +50+28, got +%d+%d", entry.X, entry.Y)
+	}
+
+	if _, err := fitManifestToCanvas(newManifest(), 100, 100, false); !errors.IsCode(err, "FRAME_OUT_OF_BOUNDS") {
+		t.Errorf("Expected FRAME_OUT_OF_BOUNDS with fail policy, got %v", err)
+	}
+
+	// 比画布还大的帧无法平移
+	if _, err := fitManifestToCanvas(newManifest(), 80, 80, true); !errors.IsCode(err, "FRAME_OUT_OF_BOUNDS") {
+		t.Errorf("Expected FRAME_OUT_OF_BOUNDS for oversized frame, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// compressFrameAutoQuality 在0到config.Quality之间二分搜索满足失真下限的最低质量
+//
+// 每次试编码后用get_disto与源帧比较；上限质量仍不满足时按上限编码并记录警告
+func (s *WebPService) compressFrameAutoQuality(ctx context.Context, frame *domain.FrameInfo, compressedPath string, config *domain.CompressionConfig) error {
+	trial := *config
+	low, high := 0, config.Quality
+	best := -1
+	lastEncoded := -1
+
+	for low <= high {
+		quality := (low + high) / 2
+		trial.Quality = quality
+		if err := s.encodeFrame(ctx, frame, compressedPath, &trial); err != nil {
+			return err
+		}
+		lastEncoded = quality
+
+		ok, err := s.meetsDistortionFloor(ctx, compressedPath, frame.Path, config)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			best = quality
+			high = quality - 1
+		} else {
+			low = quality + 1
+		}
+	}
+
+	if best < 0 {
+		best = config.Quality
+		s.logger.Warn("最高质量仍未达到失真下限",
+			"index", frame.Index,
+			"quality", best,
+			"min_ssim", config.MinSSIM,
+			"min_psnr", config.MinPSNR,
+		)
+	}
+
+	// 最后一次试编码不是最终选择时重新编码
+	if lastEncoded != best {
+		trial.Quality = best
+		if err := s.encodeFrame(ctx, frame, compressedPath, &trial); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Debug("自动质量选择完成", "index", frame.Index, "quality", best)
+	return nil
+}
+
+// meetsDistortionFloor 检查压缩结果是否满足SSIM/PSNR下限
+func (s *WebPService) meetsDistortionFloor(ctx context.Context, compressedPath, sourcePath string, config *domain.CompressionConfig) (bool, error) {
+	if config.MinSSIM > 0 {
+		db, err := s.measureDistortion(ctx, "-ssim", compressedPath, sourcePath)
+		if err != nil {
+			return false, err
+		}
+		if ssimFromDB(db) < config.MinSSIM {
+			return false, nil
+		}
+	}
+
+	if config.MinPSNR > 0 {
+		db, err := s.measureDistortion(ctx, "-psnr", compressedPath, sourcePath)
+		if err != nil {
+			return false, err
+		}
+		if db < config.MinPSNR {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// measureDistortion 使用get_disto测量失真，返回整体失真值(dB)
+func (s *WebPService) measureDistortion(ctx context.Context, metric, compressedPath, sourcePath string) (float64, error) {
+	output, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "get_disto", metric, compressedPath, sourcePath)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeExecution, "MEASURE_DISTORTION", "get_disto测量失真失败")
+	}
+
+	return parseGetDistoOutput(output)
+}
+
+// parseGetDistoOutput 解析get_disto输出
+//
+// 输出格式: <压缩文件大小> <整体失真> <分量失真...> [ <bpp> bpp ]，失真单位为dB
+func parseGetDistoOutput(output string) (float64, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 2 {
+		return 0, errors.New(errors.ErrorTypeExecution, "INVALID_DISTO_OUTPUT",
+			fmt.Sprintf("无法解析get_disto输出: %q", output))
+	}
+
+	db, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeExecution, "INVALID_DISTO_OUTPUT",
+			fmt.Sprintf("无法解析get_disto输出: %q", output))
+	}
+	return db, nil
+}
+
+// ssimFromDB 将get_disto以dB表示的SSIM(-10*log10(1-ssim))换算回0-1
+func ssimFromDB(db float64) float64 {
+	return 1 - math.Pow(10, -db/10)
+}
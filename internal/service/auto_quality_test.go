@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestParseGetDistoOutput(t *testing.T) {
+	db, err := parseGetDistoOutput("12345 38.52    40.10 36.20 35.90 99.00 [ 1.23 bpp ]\n")
+	if err != nil {
+		t.Fatalf("parseGetDistoOutput failed: %v", err)
+	}
+	if db != 38.52 {
+		t.Errorf("Expected 38.52, got %v", db)
+	}
+
+	if _, err := parseGetDistoOutput("error"); err == nil {
+		t.Error("Expected error for malformed output")
+	}
+
+	// 20dB 对应 SSIM 0.99
+	if ssim := ssimFromDB(20); math.Abs(ssim-0.99) > 1e-9 {
+		t.Errorf("Expected SSIM 0.99, got %v", ssim)
+	}
+}
+
+func encodedQualities(commands []string) []string {
+	var qualities []string
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, "cwebp -q ") {
+			qualities = append(qualities, strings.Fields(cmd)[2])
+		}
+	}
+	return qualities
+}
+
+func TestCompressFrameAutoQuality_PicksLowestPassing(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	// 13dB ≈ SSIM 0.95，总是满足下限
+	mockToolExecutor.SetMockOutput("get_disto -ssim frame_compressed_1.webp frame_1.webp", "100 13.5 13.5 13.5 13.5 0.0 [ 1.0 bpp ]")
+
+	config := domain.DefaultCompressionConfig(60)
+	config.AutoQuality = true
+	config.MinSSIM = 0.95
+
+	frame := &domain.FrameInfo{Index: 1, Path: "frame_1.webp"}
+	if err := service.compressFrame(context.Background(), frame, config); err != nil {
+		t.Fatalf("compressFrame failed: %v", err)
+	}
+
+	qualities := encodedQualities(mockToolExecutor.commands)
+	if got := strings.Join(qualities, ","); got != "30,14,6,2,0" {
+		t.Errorf("Unexpected quality search: %s", got)
+	}
+	if frame.Path != "frame_compressed_1.webp" {
+		t.Errorf("Unexpected frame path: %s", frame.Path)
+	}
+}
+
+func TestCompressFrameAutoQuality_FallsBackToCeiling(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("get_disto -ssim frame_compressed_1.webp frame_1.webp", "100 5.0 5.0 5.0 5.0 0.0 [ 1.0 bpp ]")
+
+	config := domain.DefaultCompressionConfig(60)
+	config.AutoQuality = true
+	config.MinSSIM = 0.95
+
+	frame := &domain.FrameInfo{Index: 1, Path: "frame_1.webp"}
+	if err := service.compressFrame(context.Background(), frame, config); err != nil {
+		t.Fatalf("compressFrame failed: %v", err)
+	}
+
+	qualities := encodedQualities(mockToolExecutor.commands)
+	if len(qualities) == 0 || qualities[len(qualities)-1] != "60" {
+		t.Errorf("Expected final encode at ceiling 60, got %v", qualities)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// resultCacheVersion 缓存键的格式版本，处理流程改变输出时递增使旧缓存失效
+const resultCacheVersion = 2
+
+// resultCacheKey 由输入文件内容和影响输出的设置计算缓存键
+//
+// 并发数、优先级等只影响处理速度的设置不参与计算，努力程度已展开为具体设置；
+// 处理配置中决定输出的帧越界策略一并计入
+func (s *WebPService) resultCacheKey(inputPath string, config *domain.CompressionConfig) (string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "READ_INPUT", "读取输入文件失败")
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "READ_INPUT", "读取输入文件失败")
+	}
+
+	settings := *config
+	settings.EnableParallel = false
+	settings.MaxConcurrency = 0
+	settings.Priority = ""
+	settings.Effort = 0
+	settings.Learn = ""
+	data, err := json.Marshal(struct {
+		Version           int                       `json:"version"`
+		Settings          *domain.CompressionConfig `json:"settings"`
+		FFmpegCodec       string                    `json:"ffmpeg_codec,omitempty"`
+		FrameBoundsPolicy string                    `json:"frame_bounds_policy"`
+	}{resultCacheVersion, &settings, s.ffmpegCodecFor(config), s.config.Processing.FrameBoundsPolicy})
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeInternal, "ENCODE_CACHE_KEY", "序列化缓存设置失败")
+	}
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ffmpegCodecFor 使用ffmpeg编码后端时返回配置的编码器，它同样决定输出
+func (s *WebPService) ffmpegCodecFor(config *domain.CompressionConfig) string {
+	if config.Encoder != domain.EncoderFFmpeg {
+		return ""
+	}
+	return s.config.Tools.FFmpegCodec
+}
+
+// cachePaths 返回缓存键对应的输出文件和结果文件路径，按键的前两位分子目录
+func (s *WebPService) cachePaths(key string) (string, string) {
+	dir := filepath.Join(s.config.Processing.CacheDir, key[:2])
+	return filepath.Join(dir, key+".webp"), filepath.Join(dir, key+".json")
+}
+
+// lookupCachedResult 查找缓存的压缩结果，命中时把缓存的输出复制到outputPath
+//
+// 未命中返回nil；缓存文件损坏或与记录的大小不符时视为未命中
+func (s *WebPService) lookupCachedResult(key, outputPath string) *domain.CompressResult {
+	outputFile, resultFile := s.cachePaths(key)
+
+	data, err := os.ReadFile(resultFile)
+	if err != nil {
+		return nil
+	}
+	var result domain.CompressResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		s.logger.Warn("缓存结果无法解析，忽略", "file", resultFile, "error", err)
+		return nil
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Size() != result.CompressedSize {
+		s.logger.Warn("缓存的输出文件缺失或已改变，忽略", "file", outputFile)
+		return nil
+	}
+
+	if err := copyFileAtomic(outputFile, outputPath); err != nil {
+		s.logger.Warn("复制缓存的输出失败，重新压缩", "file", outputFile, "error", err)
+		return nil
+	}
+	result.Cached = true
+	return &result
+}
+
+// storeCachedResult 把压缩输出和结果写入缓存，失败只记录警告
+//
+// 先写输出再写结果文件，结果文件存在即表示输出完整
+func (s *WebPService) storeCachedResult(key, outputPath string, result *domain.CompressResult) {
+	outputFile, resultFile := s.cachePaths(key)
+
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		s.logger.Warn("创建缓存目录失败", "dir", filepath.Dir(outputFile), "error", err)
+		return
+	}
+	if err := copyFileAtomic(outputPath, outputFile); err != nil {
+		s.logger.Warn("写入缓存输出失败", "file", outputFile, "error", err)
+		return
+	}
+
+	cached := *result
+	cached.Suggested = nil
+	data, err := json.MarshalIndent(&cached, "", "  ")
+	if err != nil {
+		s.logger.Warn("序列化缓存结果失败", "error", err)
+		return
+	}
+	if err := writeFileAtomic(resultFile, bytes.NewReader(data)); err != nil {
+		s.logger.Warn("写入缓存结果失败", "file", resultFile, "error", err)
+	}
+}
+
+// copyFileAtomic 先复制到同目录的临时文件再重命名，读者不会看到写了一半的文件
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileAtomic(dst, in)
+}
+
+// writeFileAtomic 把r的内容写入同目录的临时文件后重命名为path，并发写入同一路径时互不干扰
+func writeFileAtomic(path string, r io.Reader) error {
+	out, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := out.Name()
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
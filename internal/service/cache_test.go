@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestCompressAnimation_ReusesCachedResult(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)))
+	// 模拟的工具不写输出文件，预先写好第一次压缩的输出
+	firstOutput := filepath.Join(dir, "first.webp")
+	compressed := []byte("compressed")
+	if err := os.WriteFile(firstOutput, compressed, 0644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+	secondOutput := filepath.Join(dir, "second.webp")
+
+	service := createTestWebPService()
+	service.config.Processing.CacheDir = filepath.Join(dir, "cache")
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info "+inputPath, verifyTestOutputInfo)
+	mockFileManager := service.fileManager.(*MockFileManager)
+	mockFileManager.SetFileSize(firstOutput, int64(len(compressed)))
+
+	config := domain.DefaultCompressionConfig(50)
+	first, err := service.CompressAnimation(context.Background(), inputPath, firstOutput, config)
+	if err != nil {
+		t.Fatalf("first CompressAnimation failed: %v", err)
+	}
+	if first.Cached {
+		t.Fatal("First run should not come from the cache")
+	}
+	commands := len(mockToolExecutor.commands)
+
+	// 只改变并发数不影响输出，仍然命中缓存
+	config.MaxConcurrency = 1
+	second, err := service.CompressAnimation(context.Background(), inputPath, secondOutput, config)
+	if err != nil {
+		t.Fatalf("second CompressAnimation failed: %v", err)
+	}
+	if !second.Cached || second.FramesProcessed != first.FramesProcessed {
+		t.Errorf("Expected cached result, got %+v", second)
+	}
+	if len(mockToolExecutor.commands) != commands {
+		t.Errorf("Expected no tool runs on a cache hit, got %v", mockToolExecutor.commands[commands:])
+	}
+	if data, err := os.ReadFile(secondOutput); err != nil || string(data) != string(compressed) {
+		t.Errorf("Expected cached output copied, got %q (%v)", data, err)
+	}
+
+	// 改变质量后缓存键不同
+	config.Quality = 60
+	third, err := service.CompressAnimation(context.Background(), inputPath, secondOutput, config)
+	if err != nil {
+		t.Fatalf("third CompressAnimation failed: %v", err)
+	}
+	if third.Cached {
+		t.Error("Different quality should not hit the cache")
+	}
+}
+
+func TestResultCacheKey_FrameBoundsPolicy(t *testing.T) {
+	inputPath := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)))
+	service := createTestWebPService()
+	config := domain.DefaultCompressionConfig(50)
+
+	fixKey, err := service.resultCacheKey(inputPath, config)
+	if err != nil {
+		t.Fatalf("resultCacheKey failed: %v", err)
+	}
+
+	// fix会移动越界帧而fail直接报错，两者的结果不能共用缓存
+	service.config.Processing.FrameBoundsPolicy = "fail"
+	failKey, err := service.resultCacheKey(inputPath, config)
+	if err != nil {
+		t.Fatalf("resultCacheKey failed: %v", err)
+	}
+	if fixKey == failKey {
+		t.Error("Frame bounds policy should change the cache key")
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// CompareAnimations 比较两个WebP动画
+//
+// anim_diff给出是否完全一致及其报告的差异，webp_quality估计两者的质量因子；
+// 画布尺寸相同时再用anim_dump解码完整画布帧，逐帧用get_disto计算PSNR和SSIM
+func (s *WebPService) CompareAnimations(ctx context.Context, pathA, pathB string) (*domain.CompareReport, error) {
+	opLogger := logger.NewOperationLogger(s.logger, "动画比较").
+		WithContext("file_a", pathA).
+		WithContext("file_b", pathB)
+
+	opLogger.Start()
+
+	for _, path := range []string{pathA, pathB} {
+		if !s.fileManager.FileExists(path) {
+			err := errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", path)
+			opLogger.Error(err)
+			return nil, err
+		}
+	}
+
+	infoA, err := s.ParseAnimation(ctx, pathA)
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+	infoB, err := s.ParseAnimation(ctx, pathB)
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	report := &domain.CompareReport{
+		FileA:       pathA,
+		FileB:       pathB,
+		Differences: compareAnimationInfo(infoA, infoB),
+		QualityA:    s.estimateQuality(ctx, pathA),
+		QualityB:    s.estimateQuality(ctx, pathB),
+	}
+
+	report.Identical, err = s.runAnimDiff(ctx, pathA, pathB, report)
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	// 画布尺寸不同的帧无法逐像素比较
+	if infoA.Width == infoB.Width && infoA.Height == infoB.Height {
+		if err := s.compareFrames(ctx, pathA, pathB, infoA, infoB, report); err != nil {
+			opLogger.Error(err)
+			return nil, err
+		}
+	}
+
+	opLogger.Success()
+	return report, nil
+}
+
+// compareAnimationInfo 比较画布尺寸、帧数和循环次数
+func compareAnimationInfo(a, b *domain.AnimationInfo) []string {
+	var diffs []string
+	if a.Width != b.Width || a.Height != b.Height {
+		diffs = append(diffs, fmt.Sprintf("画布尺寸不同: %dx%d vs %dx%d", a.Width, a.Height, b.Width, b.Height))
+	}
+	if len(a.Frames) != len(b.Frames) {
+		diffs = append(diffs, fmt.Sprintf("帧数不同: %d vs %d", len(a.Frames), len(b.Frames)))
+	}
+	if a.LoopCount != b.LoopCount {
+		diffs = append(diffs, fmt.Sprintf("循环次数不同: %d vs %d", a.LoopCount, b.LoopCount))
+	}
+	return diffs
+}
+
+// runAnimDiff 执行anim_diff，文件不一致时把其报告的差异追加到报告中
+//
+// anim_diff在文件不一致时以非零状态退出，只有没有任何输出时才视为执行失败
+func (s *WebPService) runAnimDiff(ctx context.Context, pathA, pathB string, report *domain.CompareReport) (bool, error) {
+	output, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "anim_diff", pathA, pathB)
+	if err == nil {
+		return true, nil
+	}
+
+	lines := parseAnimDiffOutput(output)
+	if len(lines) == 0 {
+		return false, errors.Wrap(err, errors.ErrorTypeExecution, "COMPARE_ANIMATIONS", "anim_diff比较失败")
+	}
+
+	report.Differences = append(report.Differences, lines...)
+	return false, nil
+}
+
+// parseAnimDiffOutput 提取anim_diff报告的差异行，去掉结尾的总结行
+func parseAnimDiffOutput(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, "differ.") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// estimateQuality 用webp_quality估计有损编码的质量因子，无法估计时返回-1
+func (s *WebPService) estimateQuality(ctx context.Context, path string) int {
+	output, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "webp_quality", "-quiet", path)
+	if err != nil {
+		s.logger.Debug("无法估计质量因子", "file", path, "error", err)
+		return -1
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return -1
+	}
+	quality, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		s.logger.Debug("无法解析webp_quality输出", "file", path, "output", output)
+		return -1
+	}
+	return quality
+}
+
+// compareFrames 解码两个动画的完整画布帧，逐帧计算PSNR和SSIM，并以平均SSIM作为整体相似度
+func (s *WebPService) compareFrames(ctx context.Context, pathA, pathB string, infoA, infoB *domain.AnimationInfo, report *domain.CompareReport) error {
+	tempDir, err := s.fileManager.CreateTempDir("webp_compare")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	dirA, dirB := filepath.Join(tempDir, "a"), filepath.Join(tempDir, "b")
+	for _, dir := range []string{dirA, dirB} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+		}
+	}
+
+	if err := s.dumpFullCanvasFrames(ctx, pathA, dirA, infoA.Frames, "png"); err != nil {
+		return err
+	}
+	if err := s.dumpFullCanvasFrames(ctx, pathB, dirB, infoB.Frames, "png"); err != nil {
+		return err
+	}
+
+	count := len(infoA.Frames)
+	if len(infoB.Frames) < count {
+		count = len(infoB.Frames)
+	}
+
+	report.Frames = make([]domain.FrameDiff, count)
+	for i := 0; i < count; i++ {
+		frameA, frameB := infoA.Frames[i], infoB.Frames[i]
+
+		psnr, err := s.measureDistortion(ctx, "-psnr", frameB.Path, frameA.Path)
+		if err != nil {
+			return err
+		}
+		ssimDB, err := s.measureDistortion(ctx, "-ssim", frameB.Path, frameA.Path)
+		if err != nil {
+			return err
+		}
+
+		report.Frames[i] = domain.FrameDiff{
+			Index:     frameA.Index,
+			DurationA: int(frameA.Duration / time.Millisecond),
+			DurationB: int(frameB.Duration / time.Millisecond),
+			PSNR:      psnr,
+			SSIM:      ssimFromDB(ssimDB),
+		}
+		report.Similarity += report.Frames[i].SSIM
+	}
+	if count > 0 {
+		report.Similarity /= float64(count)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const compareTestInfoA = `Canvas size: 4 x 4
+Loop Count : 0
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:      4      4   yes         0        0       50    none    no        172      lossy
+  2:      4      4   yes         0        0       50    none    no        118      lossy`
+
+const compareTestInfoB = `Canvas size: 4 x 4
+Loop Count : 0
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:      4      4   yes         0        0       50    none    no        160      lossy
+  2:      4      4   yes         0        0       80    none    no        110      lossy`
+
+func TestCompareAnimations_ReportsFrameDifferences(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := filepath.Join(os.TempDir(), "webp_compare_test")
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			writeTestPNG(t, filepath.Join(dir, sub, fmt.Sprintf("dump_%04d.png", i)), image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+		}
+	}
+
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info a.webp", compareTestInfoA)
+	mockToolExecutor.SetMockOutput("webpmux -info b.webp", compareTestInfoB)
+	mockToolExecutor.SetMockOutput("webp_quality -quiet a.webp", "90")
+	mockToolExecutor.SetMockOutput("webp_quality -quiet b.webp", "40")
+	mockToolExecutor.SetMockOutput("anim_diff a.webp b.webp", "Frame #1, timestamp mismatch: 100 vs 130\n\nFiles a.webp and b.webp differ.\n")
+	mockToolExecutor.SetMockError("anim_diff a.webp b.webp", fmt.Errorf("exit status 254"))
+	for i := 1; i <= 2; i++ {
+		frameA := filepath.Join(dir, "a", fmt.Sprintf("frame_%d.png", i))
+		frameB := filepath.Join(dir, "b", fmt.Sprintf("frame_%d.png", i))
+		mockToolExecutor.SetMockOutput(fmt.Sprintf("get_disto -psnr %s %s", frameB, frameA), "100 40.00 40.00 40.00 40.00 99.00 [ 1.0 bpp ]")
+		mockToolExecutor.SetMockOutput(fmt.Sprintf("get_disto -ssim %s %s", frameB, frameA), "100 20.00 20.00 20.00 20.00 99.00 [ 1.0 bpp ]")
+	}
+
+	report, err := service.CompareAnimations(context.Background(), "a.webp", "b.webp")
+	if err != nil {
+		t.Fatalf("CompareAnimations failed: %v", err)
+	}
+
+	if report.Identical {
+		t.Error("Expected files to differ")
+	}
+	if len(report.Differences) != 1 || report.Differences[0] != "Frame #1, timestamp mismatch: 100 vs 130" {
+		t.Errorf("Unexpected differences: %v", report.Differences)
+	}
+	if report.QualityA != 90 || report.QualityB != 40 {
+		t.Errorf("Unexpected quality estimates: %d/%d", report.QualityA, report.QualityB)
+	}
+	if len(report.Frames) != 2 || report.Frames[1].DurationA != 50 || report.Frames[1].DurationB != 80 {
+		t.Fatalf("Unexpected frames: %+v", report.Frames)
+	}
+	if report.Frames[0].PSNR != 40 || report.Similarity < 0.98 || report.Similarity > 1 {
+		t.Errorf("Unexpected similarity: psnr=%.2f similarity=%.4f", report.Frames[0].PSNR, report.Similarity)
+	}
+}
+
+func TestCompareAnimationInfo_CanvasMismatch(t *testing.T) {
+	service := createTestWebPService()
+	a, err := service.parseWebpmuxOutput(compareTestInfoA)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	b, err := service.parseWebpmuxOutput(compareTestInfoB)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	b.Width, b.LoopCount = 8, 3
+
+	diffs := compareAnimationInfo(a, b)
+	if len(diffs) != 2 {
+		t.Errorf("Expected canvas and loop differences, got %v", diffs)
+	}
+}
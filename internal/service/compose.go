@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// supportedSequenceExts 图像序列支持的文件扩展名
+var supportedSequenceExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// LoadImageSequence 按文件名顺序加载目录中的PNG/JPEG图像序列
+//
+// durations为空时所有帧使用defaultDuration；只给出部分时长时，剩余帧沿用最后一个时长
+func (s *WebPService) LoadImageSequence(dir string, defaultDuration time.Duration, durations []time.Duration) ([]*domain.FrameInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "READ_SEQUENCE_DIR",
+			fmt.Sprintf("读取图像序列目录失败: %s", dir))
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if supportedSequenceExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, errors.New(errors.ErrorTypeValidation, "EMPTY_SEQUENCE",
+			fmt.Sprintf("目录中没有PNG/JPEG图像: %s", dir))
+	}
+
+	frames := make([]*domain.FrameInfo, len(files))
+	for i, name := range files {
+		duration := defaultDuration
+		if len(durations) > 0 {
+			if i < len(durations) {
+				duration = durations[i]
+			} else {
+				duration = durations[len(durations)-1]
+			}
+		}
+
+		frames[i] = &domain.FrameInfo{
+			Index:    i + 1,
+			Duration: duration,
+			Dispose:  domain.DisposeNone,
+			Blend:    domain.BlendNo,
+			Path:     filepath.Join(dir, name),
+		}
+	}
+
+	s.logger.Debug("加载图像序列成功", "dir", dir, "frames", len(frames))
+	return frames, nil
+}
+
+// LoadComposeManifest 加载合成清单，返回帧列表和循环次数
+func (s *WebPService) LoadComposeManifest(manifestPath string) ([]*domain.FrameInfo, int, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, errors.ErrorTypeIO, "READ_MANIFEST",
+			fmt.Sprintf("读取合成清单失败: %s", manifestPath))
+	}
+
+	var manifest domain.ComposeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, 0, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_MANIFEST", "合成清单格式无效")
+	}
+
+	if len(manifest.Frames) == 0 {
+		return nil, 0, errors.New(errors.ErrorTypeValidation, "EMPTY_MANIFEST", "合成清单中没有帧")
+	}
+
+	baseDir := filepath.Dir(manifestPath)
+	frames := make([]*domain.FrameInfo, len(manifest.Frames))
+	for i, item := range manifest.Frames {
+		if item.File == "" {
+			return nil, 0, errors.New(errors.ErrorTypeValidation, "INVALID_MANIFEST",
+				fmt.Sprintf("清单第%d帧缺少文件路径", i+1))
+		}
+		if item.Duration <= 0 {
+			return nil, 0, errors.New(errors.ErrorTypeValidation, "INVALID_MANIFEST",
+				fmt.Sprintf("清单第%d帧持续时间无效: %d", i+1, item.Duration))
+		}
+
+		path := item.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		frames[i] = &domain.FrameInfo{
+			Index:    i + 1,
+			Duration: time.Duration(item.Duration) * time.Millisecond,
+			Dispose:  domain.DisposeNone,
+			Blend:    domain.BlendNo,
+			Path:     path,
+		}
+	}
+
+	return frames, manifest.Loop, nil
+}
+
+// ComposeAnimation 将图像序列合成为WebP动画
+//
+// 启用并行时先用cwebp并行预编码每一帧，再由webpmux组装；否则直接交给img2webp一次完成
+func (s *WebPService) ComposeAnimation(ctx context.Context, frames []*domain.FrameInfo, outputPath string, loopCount int, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	opLogger := logger.NewOperationLogger(s.logger, "图像序列合成").
+		WithContext("frames", len(frames)).
+		WithContext("output", outputPath).
+		WithContext("quality", config.Quality).
+		WithContext("parallel", config.EnableParallel)
+
+	opLogger.Start()
+	startTime := time.Now()
+
+	if err := s.validateComposeInput(frames, config); err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	var originalSize int64
+	for _, frame := range frames {
+		size, err := s.fileManager.GetFileSize(frame.Path)
+		if err != nil {
+			err = errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取文件大小失败")
+			opLogger.Error(err)
+			return nil, err
+		}
+		originalSize += size
+	}
+
+	parallelWorkers := 1
+	if config.EnableParallel && len(frames) > 1 {
+		tempDir, err := s.fileManager.CreateTempDir("webp_compose")
+		if err != nil {
+			err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+			opLogger.Error(err)
+			return nil, err
+		}
+		defer s.fileManager.CleanupTempDir(tempDir)
+
+		encoded, workers, err := s.preEncodeFrames(ctx, frames, tempDir, config)
+		if err != nil {
+			opLogger.Error(err)
+			return nil, err
+		}
+		parallelWorkers = workers
+
+		if err := s.assembleAnimation(ctx, encoded, outputPath, loopCount); err != nil {
+			opLogger.Error(err)
+			return nil, err
+		}
+	} else {
+		if err := s.composeWithImg2webp(ctx, frames, outputPath, loopCount, config); err != nil {
+			opLogger.Error(err)
+			return nil, err
+		}
+	}
+
+	compressedSize, err := s.fileManager.GetFileSize(outputPath)
+	if err != nil {
+		s.logger.Warn("获取合成后文件大小失败", "error", err)
+		compressedSize = 0
+	}
+
+	result := &domain.CompressResult{
+		OriginalSize:    originalSize,
+		CompressedSize:  compressedSize,
+		ProcessingTime:  time.Since(startTime),
+		FramesProcessed: len(frames),
+		ParallelWorkers: parallelWorkers,
+	}
+	result.CalculateCompressionRatio()
+
+	opLogger.Success()
+	return result, nil
+}
+
+// preEncodeFrames 并行将源图像预编码为WebP帧，返回新的帧列表和使用的工作者数量
+func (s *WebPService) preEncodeFrames(ctx context.Context, frames []*domain.FrameInfo, outputDir string, config *domain.CompressionConfig) ([]*domain.FrameInfo, int, error) {
+	maxWorkers := config.MaxConcurrency
+	if maxWorkers <= 0 {
+		maxWorkers = s.config.App.MaxConcurrency
+	}
+	if maxWorkers > len(frames) {
+		maxWorkers = len(frames)
+	}
+
+	s.logger.Info("开始并行预编码帧", "total_frames", len(frames), "workers", maxWorkers)
+
+	// 复制帧信息，避免修改调用方的源路径
+	encoded := make([]*domain.FrameInfo, len(frames))
+	for i, frame := range frames {
+		copied := *frame
+		encoded[i] = &copied
+	}
+
+	workerPool := domain.NewWorkerPool(maxWorkers)
+	workerPool.Start(ctx, s.governed(func(ctx context.Context, frame *domain.FrameInfo) error {
+		return s.encodeImageFrame(ctx, frame, outputDir, config)
+	}))
+
+	var submitErr error
+	for _, frame := range encoded {
+		if submitErr = workerPool.Submit(ctx, frame); submitErr != nil {
+			break
+		}
+	}
+	workerPool.Close()
+
+	errs := workerPool.Wait()
+	if submitErr != nil {
+		return nil, 0, errors.Wrap(submitErr, errors.ErrorTypeExecution, "ENCODE_CANCELLED", "并行预编码已取消")
+	}
+	if len(errs) > 0 {
+		s.logger.Error("并行预编码出现错误", "error_count", len(errs))
+		return nil, 0, errors.Wrap(errs, errors.ErrorTypeExecution, "ENCODE_FRAMES", "并行预编码帧失败")
+	}
+
+	return encoded, maxWorkers, nil
+}
+
+// encodeImageFrame 使用cwebp将单个源图像编码为WebP帧
+func (s *WebPService) encodeImageFrame(ctx context.Context, frame *domain.FrameInfo, outputDir string, config *domain.CompressionConfig) error {
+	encodedPath := filepath.Join(outputDir, fmt.Sprintf("frame_%d.webp", frame.Index))
+
+	if config.Mixed {
+		if err := s.compressFrameMixed(ctx, frame, encodedPath, config); err != nil {
+			return err
+		}
+		frame.Path = encodedPath
+		return nil
+	}
+
+	args, err := s.buildCompressionArgs(config, frame.Path, encodedPath)
+	if err != nil {
+		return err
+	}
+	if err := s.toolExecutor.ExecuteCommand(ctx, "cwebp", args...); err != nil {
+		return errors.Wrapf(err, errors.ErrorTypeExecution, "ENCODE_FRAME",
+			"编码第%d帧失败: %s", frame.Index, frame.Path)
+	}
+
+	if !s.fileManager.FileExists(encodedPath) {
+		return errors.New(errors.ErrorTypeExecution, "ENCODED_FRAME_NOT_CREATED",
+			fmt.Sprintf("第%d帧编码文件未成功创建: %s", frame.Index, encodedPath))
+	}
+
+	frame.Path = encodedPath
+	return nil
+}
+
+// composeWithImg2webp 使用img2webp直接合成动画
+func (s *WebPService) composeWithImg2webp(ctx context.Context, frames []*domain.FrameInfo, outputPath string, loopCount int, config *domain.CompressionConfig) error {
+	outputDir := filepath.Dir(outputPath)
+	if outputDir != "." && outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT_DIR",
+				fmt.Sprintf("创建输出目录失败: %s", outputDir))
+		}
+	}
+
+	args := s.buildImg2webpArgs(frames, outputPath, loopCount, config)
+
+	s.logger.Info("执行img2webp命令", "total_frames", len(frames))
+
+	if err := s.toolExecutor.ExecuteCommand(ctx, "img2webp", args...); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExecution, "COMPOSE_ANIMATION", "img2webp合成动画失败")
+	}
+
+	return nil
+}
+
+// buildImg2webpArgs 构建img2webp参数
+func (s *WebPService) buildImg2webpArgs(frames []*domain.FrameInfo, outputPath string, loopCount int, config *domain.CompressionConfig) []string {
+	args := []string{"-loop", strconv.Itoa(loopCount)}
+
+	switch {
+	case config.Mixed:
+		args = append(args, "-mixed", "-q", strconv.Itoa(config.Quality))
+	case config.Lossless || config.NearLossless > 0:
+		args = append(args, "-lossless")
+	default:
+		args = append(args, "-lossy", "-q", strconv.Itoa(config.Quality))
+	}
+	if config.NearLossless > 0 {
+		args = append(args, "-near_lossless", strconv.Itoa(config.NearLossless))
+	}
+	args = append(args, "-m", strconv.Itoa(config.Method))
+
+	for _, frame := range frames {
+		args = append(args, "-d", strconv.Itoa(int(frame.Duration/time.Millisecond)), frame.Path)
+	}
+
+	return append(args, "-o", outputPath)
+}
+
+// validateComposeInput 验证合成参数
+func (s *WebPService) validateComposeInput(frames []*domain.FrameInfo, config *domain.CompressionConfig) error {
+	if len(frames) == 0 {
+		return errors.New(errors.ErrorTypeValidation, "EMPTY_INPUT", "输入不能为空").WithContext("frames", 0)
+	}
+
+	if config.Quality < 0 || config.Quality > 100 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_QUALITY", "质量参数必须在0-100之间").WithContext("quality", config.Quality)
+	}
+
+	if err := newCwebpArgs(config, "", "").Validate(); err != nil {
+		return err
+	}
+
+	if config.Mixed && config.Lossless {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_MIXED_MODE", "混合模式不能与无损模式同时使用")
+	}
+
+	for _, frame := range frames {
+		if !s.fileManager.FileExists(frame.Path) {
+			return errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", frame.Path)
+		}
+		if frame.Duration <= 0 {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_DURATION",
+				fmt.Sprintf("第%d帧持续时间无效: %v", frame.Index, frame.Duration))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestLoadComposeManifest_Success(t *testing.T) {
+	service := createTestWebPService()
+
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+	manifest := `{"loop": 3, "frames": [{"file": "a.png", "duration": 100}, {"file": "b.png", "duration": 40}]}`
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	frames, loop, err := service.LoadComposeManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadComposeManifest failed: %v", err)
+	}
+
+	if loop != 3 {
+		t.Errorf("Expected loop 3, got %d", loop)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	if frames[0].Path != filepath.Join(dir, "a.png") {
+		t.Errorf("Expected path relative to manifest, got %s", frames[0].Path)
+	}
+	if frames[1].Duration != 40*time.Millisecond {
+		t.Errorf("Expected duration 40ms, got %v", frames[1].Duration)
+	}
+}
+
+func TestLoadImageSequence_SortedWithDurations(t *testing.T) {
+	service := createTestWebPService()
+
+	dir := t.TempDir()
+	for _, name := range []string{"002.png", "001.jpg", "notes.txt", "003.PNG"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	frames, err := service.LoadImageSequence(dir, 100*time.Millisecond,
+		[]time.Duration{50 * time.Millisecond, 70 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("LoadImageSequence failed: %v", err)
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("Expected 3 frames, got %d", len(frames))
+	}
+	if filepath.Base(frames[0].Path) != "001.jpg" {
+		t.Errorf("Expected first frame 001.jpg, got %s", frames[0].Path)
+	}
+	// 未指定时长的帧沿用最后一个时长
+	if frames[2].Duration != 70*time.Millisecond {
+		t.Errorf("Expected duration 70ms, got %v", frames[2].Duration)
+	}
+}
+
+func TestComposeAnimation_Img2webp(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	frames := []*domain.FrameInfo{
+		{Index: 1, Path: "a.png", Duration: 100 * time.Millisecond},
+		{Index: 2, Path: "b.png", Duration: 50 * time.Millisecond},
+	}
+
+	config := domain.DefaultCompressionConfig(60)
+	config.EnableParallel = false
+
+	result, err := service.ComposeAnimation(context.Background(), frames, "out.webp", 0, config)
+	if err != nil {
+		t.Fatalf("ComposeAnimation failed: %v", err)
+	}
+	if result.FramesProcessed != 2 {
+		t.Errorf("Expected 2 frames processed, got %d", result.FramesProcessed)
+	}
+
+	expected := "img2webp -loop 0 -lossy -q 60 -m 6 -d 100 a.png -d 50 b.png -o out.webp"
+	if len(mockToolExecutor.commands) != 1 || mockToolExecutor.commands[0] != expected {
+		t.Errorf("Unexpected commands: %v", mockToolExecutor.commands)
+	}
+}
+
+func TestComposeAnimation_ParallelPreEncode(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	frames := []*domain.FrameInfo{
+		{Index: 1, Path: "a.png", Duration: 100 * time.Millisecond},
+		{Index: 2, Path: "b.png", Duration: 100 * time.Millisecond},
+		{Index: 3, Path: "c.png", Duration: 100 * time.Millisecond},
+	}
+
+	config := domain.DefaultCompressionConfig(60)
+
+	if _, err := service.ComposeAnimation(context.Background(), frames, "out.webp", 2, config); err != nil {
+		t.Fatalf("ComposeAnimation failed: %v", err)
+	}
+
+	cwebpCalls := 0
+	assembled := false
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") {
+			cwebpCalls++
+		}
+		if strings.HasPrefix(cmd, "webpmux ") && strings.Contains(cmd, "-loop 2") {
+			assembled = true
+		}
+	}
+
+	if cwebpCalls != 3 {
+		t.Errorf("Expected 3 cwebp calls, got %d", cwebpCalls)
+	}
+	if !assembled {
+		t.Errorf("Expected webpmux assembly with loop 2, got %v", mockToolExecutor.commands)
+	}
+	// 源帧路径不应被修改
+	if frames[0].Path != "a.png" {
+		t.Errorf("Source frame path mutated: %s", frames[0].Path)
+	}
+}
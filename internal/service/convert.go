@@ -0,0 +1,326 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// ConvertAnimation 将WebP动画导出为GIF或APNG
+//
+// 使用anim_dump解码出完整画布的帧（静态图像回退到dwebp），再按原始时长重新封装
+func (s *WebPService) ConvertAnimation(ctx context.Context, inputPath, outputPath string, format domain.OutputFormat) (*domain.CompressResult, error) {
+	opLogger := logger.NewOperationLogger(s.logger, "WebP动画格式转换").
+		WithContext("input", inputPath).
+		WithContext("output", outputPath).
+		WithContext("format", string(format))
+
+	opLogger.Start()
+	startTime := time.Now()
+
+	if format != domain.OutputFormatGIF && format != domain.OutputFormatAPNG {
+		err := errors.New(errors.ErrorTypeValidation, "UNSUPPORTED_FORMAT",
+			fmt.Sprintf("不支持的导出格式: %s", format))
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if !s.fileManager.FileExists(inputPath) {
+		err := errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", inputPath)
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	originalSize, err := s.fileManager.GetFileSize(inputPath)
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取文件大小失败")
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	tempDir, err := s.fileManager.CreateTempDir("webp_convert")
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+		opLogger.Error(err)
+		return nil, err
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	images, durations, loopCount, err := s.decodeFullFrames(ctx, inputPath, tempDir)
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if outputDir := filepath.Dir(outputPath); outputDir != "." && outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT_DIR",
+				fmt.Sprintf("创建输出目录失败: %s", outputDir))
+			opLogger.Error(err)
+			return nil, err
+		}
+	}
+
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT", "创建输出文件失败")
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if format == domain.OutputFormatGIF {
+		err = encodeGIF(outFile, images, durations, loopCount)
+	} else {
+		err = encodeAPNG(outFile, images, durations, loopCount)
+	}
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = errors.Wrapf(err, errors.ErrorTypeIO, "ENCODE_OUTPUT", "写入%s失败", format)
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	convertedSize, err := s.fileManager.GetFileSize(outputPath)
+	if err != nil {
+		s.logger.Warn("获取转换后文件大小失败", "error", err)
+		convertedSize = 0
+	}
+
+	result := &domain.CompressResult{
+		OriginalSize:    originalSize,
+		CompressedSize:  convertedSize,
+		ProcessingTime:  time.Since(startTime),
+		FramesProcessed: len(images),
+		ParallelWorkers: 1,
+	}
+	result.CalculateCompressionRatio()
+
+	opLogger.Success()
+	return result, nil
+}
+
+// decodeFullFrames 将输入解码为完整画布帧，返回帧图像、帧时长和循环次数
+func (s *WebPService) decodeFullFrames(ctx context.Context, inputPath, tempDir string) ([]image.Image, []time.Duration, int, error) {
+	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		if !errors.IsCode(err, "NO_FRAMES") {
+			return nil, nil, 0, err
+		}
+
+		// 静态图像：直接用dwebp解码为单帧
+		framePath := filepath.Join(tempDir, "frame_0000.png")
+		if err := s.toolExecutor.ExecuteCommand(ctx, "dwebp", inputPath, "-png", "-o", framePath); err != nil {
+			return nil, nil, 0, errors.Wrap(err, errors.ErrorTypeExecution, "DECODE_IMAGE", "dwebp解码失败")
+		}
+		img, err := readPNG(framePath)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return []image.Image{img}, []time.Duration{0}, 0, nil
+	}
+
+	// anim_dump 按 <prefix><4位序号>.png 输出完整画布帧，序号从0开始
+	if err := s.toolExecutor.ExecuteCommand(ctx, "anim_dump",
+		"-folder", tempDir, "-prefix", "frame_", inputPath); err != nil {
+		return nil, nil, 0, errors.Wrap(err, errors.ErrorTypeExecution, "DUMP_FRAMES", "anim_dump提取帧失败")
+	}
+
+	images := make([]image.Image, len(animInfo.Frames))
+	durations := make([]time.Duration, len(animInfo.Frames))
+	for i, frame := range animInfo.Frames {
+		img, err := readPNG(filepath.Join(tempDir, fmt.Sprintf("frame_%04d.png", i)))
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		images[i] = img
+		durations[i] = frame.Duration
+	}
+
+	s.logger.Debug("解码完整画布帧成功", "frames", len(images))
+	return images, durations, animInfo.LoopCount, nil
+}
+
+// readPNG 读取PNG图像
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "OPEN_FRAME",
+			fmt.Sprintf("打开帧文件失败: %s", path))
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "DECODE_FRAME",
+			fmt.Sprintf("解码帧文件失败: %s", path))
+	}
+	return img, nil
+}
+
+// gifPalette GIF调色板：Plan9前255色加一个透明色
+var gifPalette = append(append(color.Palette{}, palette.Plan9[:255]...), color.Transparent)
+
+// encodeGIF 将完整画布帧编码为GIF动画
+func encodeGIF(w io.Writer, images []image.Image, durations []time.Duration, loopCount int) error {
+	anim := &gif.GIF{
+		Image:    make([]*image.Paletted, len(images)),
+		Delay:    make([]int, len(images)),
+		Disposal: make([]byte, len(images)),
+	}
+
+	// WebP循环次数为播放次数，GIF的LoopCount为额外重复次数，-1表示只播放一次
+	switch {
+	case loopCount == 0:
+		anim.LoopCount = 0
+	case loopCount == 1:
+		anim.LoopCount = -1
+	default:
+		anim.LoopCount = loopCount - 1
+	}
+
+	for i, img := range images {
+		bounds := img.Bounds()
+		paletted := image.NewPaletted(bounds, gifPalette)
+		draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+
+		anim.Image[i] = paletted
+		anim.Delay[i] = int(durations[i] / (10 * time.Millisecond))
+		// 每帧都是完整画布，恢复背景以免透明区域残留上一帧
+		anim.Disposal[i] = gif.DisposalBackground
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// encodeAPNG 将完整画布帧编码为APNG动画
+//
+// 所有帧统一按8位RGBA写出，第一帧同时作为默认图像
+func encodeAPNG(w io.Writer, images []image.Image, durations []time.Duration, loopCount int) error {
+	bounds := images[0].Bounds()
+	width, height := uint32(bounds.Dx()), uint32(bounds.Dy())
+
+	if _, err := w.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+		return err
+	}
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8] = 8 // 位深
+	ihdr[9] = 6 // RGBA
+	if err := writePNGChunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(images)))
+	binary.BigEndian.PutUint32(actl[4:8], uint32(loopCount))
+	if err := writePNGChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	var sequence uint32
+	for i, img := range images {
+		delay := durations[i] / time.Millisecond
+		if delay > 0xFFFF {
+			delay = 0xFFFF
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], sequence)
+		binary.BigEndian.PutUint32(fctl[4:8], width)
+		binary.BigEndian.PutUint32(fctl[8:12], height)
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(delay))
+		binary.BigEndian.PutUint16(fctl[22:24], 1000) // 时长单位为毫秒
+		// dispose_op=0(none)、blend_op=0(source)：完整画布直接覆盖
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		sequence++
+
+		data, err := deflateRGBA(img, bounds)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			if err := writePNGChunk(w, "IDAT", data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat[0:4], sequence)
+		copy(fdat[4:], data)
+		if err := writePNGChunk(w, "fdAT", fdat); err != nil {
+			return err
+		}
+		sequence++
+	}
+
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// deflateRGBA 将图像按RGBA扫描线（无滤波）压缩为zlib数据
+func deflateRGBA(img image.Image, bounds image.Rectangle) ([]byte, error) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	rowLen := bounds.Dx() * 4
+	for y := 0; y < bounds.Dy(); y++ {
+		if _, err := zw.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+rowLen]
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writePNGChunk 写出一个PNG数据块
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, part := range [][]byte{header, data, footer} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
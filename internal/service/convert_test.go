@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func createTestFrames() ([]image.Image, []time.Duration) {
+	images := make([]image.Image, 3)
+	for i := range images {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				img.Set(x, y, color.NRGBA{R: uint8(80 * i), G: 40, B: 200, A: 255})
+			}
+		}
+		// 左上角透明
+		img.Set(0, 0, color.NRGBA{})
+		images[i] = img
+	}
+	return images, []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 200 * time.Millisecond}
+}
+
+func TestEncodeGIF_RoundTrip(t *testing.T) {
+	images, durations := createTestFrames()
+
+	var buf bytes.Buffer
+	if err := encodeGIF(&buf, images, durations, 3); err != nil {
+		t.Fatalf("encodeGIF failed: %v", err)
+	}
+
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll failed: %v", err)
+	}
+
+	if len(decoded.Image) != 3 {
+		t.Fatalf("Expected 3 frames, got %d", len(decoded.Image))
+	}
+	if decoded.Delay[2] != 20 {
+		t.Errorf("Expected delay 20, got %d", decoded.Delay[2])
+	}
+	if decoded.LoopCount != 2 {
+		t.Errorf("Expected loop count 2, got %d", decoded.LoopCount)
+	}
+	if _, _, _, a := decoded.Image[0].At(0, 0).RGBA(); a != 0 {
+		t.Errorf("Expected transparent pixel, got alpha %d", a)
+	}
+}
+
+func TestEncodeAPNG_DefaultImageDecodable(t *testing.T) {
+	images, durations := createTestFrames()
+
+	var buf bytes.Buffer
+	if err := encodeAPNG(&buf, images, durations, 0); err != nil {
+		t.Fatalf("encodeAPNG failed: %v", err)
+	}
+
+	data := buf.Bytes()
+	for _, chunk := range []string{"acTL", "fcTL", "fdAT"} {
+		if !bytes.Contains(data, []byte(chunk)) {
+			t.Errorf("Expected %s chunk in output", chunk)
+		}
+	}
+
+	// 不支持APNG的解码器应能读取第一帧
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode failed: %v", err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 3 {
+		t.Errorf("Unexpected bounds: %v", img.Bounds())
+	}
+	if _, _, b, _ := img.At(1, 1).RGBA(); b>>8 != 200 {
+		t.Errorf("Unexpected pixel value: %d", b>>8)
+	}
+}
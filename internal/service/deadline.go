@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// deadlineSampleFrames 估算编码耗时时试编码的帧数
+const deadlineSampleFrames = 3
+
+// methodCostFactors cwebp各-m档位相对-m 0的大致编码耗时
+var methodCostFactors = [7]float64{1, 1.3, 1.7, 2.2, 3.0, 4.0, 6.0}
+
+// selectMethodForDeadline 按配置的压缩方法试编码前几帧估算单帧耗时，
+// 返回能在剩余时间内完成全部帧的最高压缩方法，不会高于配置的方法
+func (s *WebPService) selectMethodForDeadline(ctx context.Context, inputPath, tempDir string, frames []*domain.FrameInfo, config *domain.CompressionConfig, budget time.Duration) (int, error) {
+	sample := frames[:min(len(frames), deadlineSampleFrames)]
+
+	var spent time.Duration
+	for _, frame := range sample {
+		if frame.Path == "" {
+			if err := s.extractFrame(ctx, inputPath, tempDir, frame); err != nil {
+				return 0, err
+			}
+		}
+
+		probePath := filepath.Join(tempDir, fmt.Sprintf("probe_%d.webp", frame.Index))
+		startTime := time.Now()
+		if err := s.encodeFrame(ctx, frame, probePath, config); err != nil {
+			return 0, err
+		}
+		spent += time.Since(startTime)
+
+		if err := os.Remove(probePath); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("删除试编码帧失败", "file", probePath, "error", err)
+		}
+	}
+
+	perFrame := spent / time.Duration(len(sample))
+	method := chooseMethodForDeadline(perFrame, config.Method, len(frames), s.compressWorkers(config), budget-spent)
+
+	s.logger.Info("按截止时间选择压缩方法",
+		"per_frame", perFrame,
+		"budget", budget-spent,
+		"configured_method", config.Method,
+		"method", method,
+	)
+	return method, nil
+}
+
+// chooseMethodForDeadline 由在measuredMethod下测得的单帧耗时推算各档位的总耗时，
+// 返回预计不超过budget的最高档位，都超出时返回0
+func chooseMethodForDeadline(perFrame time.Duration, measuredMethod, frames, workers int, budget time.Duration) int {
+	if workers < 1 {
+		workers = 1
+	}
+	rounds := (frames + workers - 1) / workers
+
+	for method := measuredMethod; method > 0; method-- {
+		ratio := methodCostFactors[method] / methodCostFactors[measuredMethod]
+		estimate := time.Duration(float64(perFrame) * ratio * float64(rounds))
+		if estimate <= budget {
+			return method
+		}
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestChooseMethodForDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		perFrame time.Duration
+		frames   int
+		workers  int
+		budget   time.Duration
+		expected int
+	}{
+		{"充足时间保持配置方法", 100 * time.Millisecond, 10, 1, 10 * time.Second, 6},
+		{"时间不足降低方法", 600 * time.Millisecond, 10, 1, 2500 * time.Millisecond, 3},
+		{"并发缩短预计耗时", 600 * time.Millisecond, 10, 5, 800 * time.Millisecond, 5},
+		{"都来不及时使用最快方法", time.Second, 100, 1, time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := chooseMethodForDeadline(tt.perFrame, 6, tt.frames, tt.workers, tt.budget)
+			if method != tt.expected {
+				t.Errorf("Expected method %d, got %d", tt.expected, method)
+			}
+		})
+	}
+}
+
+func TestCompressAnimation_DeadlineSelectsMethod(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Deadline = time.Minute
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	// mock编码几乎不耗时，应保持配置的方法
+	if result.Method != config.Method {
+		t.Errorf("Expected method %d, got %d", config.Method, result.Method)
+	}
+
+	probes := 0
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") && strings.Contains(cmd, "probe_") {
+			probes++
+		}
+	}
+	if probes != 2 {
+		t.Errorf("Expected 2 probe encodes, got %d", probes)
+	}
+}
+
+func TestValidateInput_InvalidDeadline(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Deadline = -time.Second
+	if err := service.validateInput("test.webp", "out.webp", config); !errors.IsCode(err, "INVALID_DEADLINE") {
+		t.Errorf("Expected INVALID_DEADLINE for negative deadline, got %v", err)
+	}
+
+	config = domain.DefaultCompressionConfig(50)
+	config.Deadline = time.Second
+	config.Pipeline = domain.PipelineImg2webp
+	if err := service.validateInput("test.webp", "out.webp", config); !errors.IsCode(err, "INVALID_DEADLINE") {
+		t.Errorf("Expected INVALID_DEADLINE for img2webp pipeline, got %v", err)
+	}
+}
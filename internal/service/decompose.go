@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// FrameManifestFile 帧清单文件名
+const FrameManifestFile = "manifest.json"
+
+// DecomposeAnimation 将动画逐帧导出为PNG或WebP，并在输出目录写入manifest.json
+//
+// 默认导出webpmux中的原始子帧，清单记录偏移、时长、处理和混合方式；
+// fullCanvas为true时使用anim_dump导出合成后的完整画布帧
+func (s *WebPService) DecomposeAnimation(ctx context.Context, inputPath, outputDir string, format string, fullCanvas bool) (*domain.FrameManifest, error) {
+	opLogger := logger.NewOperationLogger(s.logger, "动画帧提取").
+		WithContext("input", inputPath).
+		WithContext("output_dir", outputDir).
+		WithContext("format", format).
+		WithContext("full_canvas", fullCanvas)
+
+	opLogger.Start()
+
+	if format != "png" && format != "webp" {
+		err := errors.New(errors.ErrorTypeValidation, "UNSUPPORTED_FORMAT",
+			fmt.Sprintf("不支持的帧格式: %s", format))
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if !s.fileManager.FileExists(inputPath) {
+		err := errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", inputPath)
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT_DIR",
+			fmt.Sprintf("创建输出目录失败: %s", outputDir))
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	if fullCanvas {
+		err = s.dumpFullCanvasFrames(ctx, inputPath, outputDir, animInfo.Frames, format)
+	} else {
+		err = s.dumpRawFrames(ctx, inputPath, outputDir, animInfo.Frames, format)
+	}
+	if err != nil {
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	manifest := &domain.FrameManifest{
+		Source:     filepath.Base(inputPath),
+		Width:      animInfo.Width,
+		Height:     animInfo.Height,
+		Loop:       animInfo.LoopCount,
+		FullCanvas: fullCanvas,
+		Frames:     make([]domain.FrameManifestEntry, len(animInfo.Frames)),
+	}
+
+	for i, frame := range animInfo.Frames {
+		entry := domain.FrameManifestEntry{
+			Index:    frame.Index,
+			File:     filepath.Base(frame.Path),
+			Duration: int(frame.Duration / time.Millisecond),
+			X:        frame.X,
+			Y:        frame.Y,
+			Dispose:  frame.Dispose.String(),
+			Blend:    frame.Blend.String(),
+		}
+		// 完整画布帧已经完成合成，重新播放时应直接覆盖
+		if fullCanvas {
+			entry.X, entry.Y = 0, 0
+			entry.Dispose = domain.DisposeNone.String()
+			entry.Blend = domain.BlendNo.String()
+		}
+		manifest.Frames[i] = entry
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeInternal, "ENCODE_MANIFEST", "序列化帧清单失败")
+		opLogger.Error(err)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, FrameManifestFile), data, 0644); err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "WRITE_MANIFEST", "写入帧清单失败")
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	opLogger.Success()
+	return manifest, nil
+}
+
+// dumpRawFrames 使用webpmux导出原始子帧，需要时用dwebp转换为PNG
+func (s *WebPService) dumpRawFrames(ctx context.Context, inputPath, outputDir string, frames []*domain.FrameInfo, format string) error {
+	if err := s.ExtractFrames(ctx, inputPath, outputDir, frames); err != nil {
+		return err
+	}
+
+	if format == "webp" {
+		return nil
+	}
+
+	for _, frame := range frames {
+		pngPath := filepath.Join(outputDir, fmt.Sprintf("frame_%d.png", frame.Index))
+		if err := s.toolExecutor.ExecuteCommand(ctx, "dwebp", frame.Path, "-png", "-o", pngPath); err != nil {
+			return errors.Wrapf(err, errors.ErrorTypeExecution, "DECODE_FRAME",
+				"第%d帧转换为PNG失败", frame.Index)
+		}
+		if err := os.Remove(frame.Path); err != nil {
+			s.logger.Warn("删除中间帧文件失败", "file", frame.Path, "error", err)
+		}
+		frame.Path = pngPath
+	}
+
+	return nil
+}
+
+// dumpFullCanvasFrames 使用anim_dump导出完整画布帧，需要时用cwebp无损转换为WebP
+func (s *WebPService) dumpFullCanvasFrames(ctx context.Context, inputPath, outputDir string, frames []*domain.FrameInfo, format string) error {
+	// anim_dump 按 <prefix><4位序号>.png 输出，序号从0开始
+	if err := s.toolExecutor.ExecuteCommand(ctx, "anim_dump",
+		"-folder", outputDir, "-prefix", "dump_", inputPath); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExecution, "DUMP_FRAMES", "anim_dump提取帧失败")
+	}
+
+	for _, frame := range frames {
+		// webpmux帧序号从1开始且连续，对应anim_dump的第Index-1个输出
+		dumped := filepath.Join(outputDir, fmt.Sprintf("dump_%04d.png", frame.Index-1))
+		target := filepath.Join(outputDir, fmt.Sprintf("frame_%d.%s", frame.Index, format))
+
+		if format == "webp" {
+			err := s.toolExecutor.ExecuteCommand(ctx, "cwebp",
+				"-lossless", "-q", strconv.Itoa(100), "-metadata", "none", dumped, "-o", target)
+			if err != nil {
+				return errors.Wrapf(err, errors.ErrorTypeExecution, "ENCODE_FRAME",
+					"第%d帧转换为WebP失败", frame.Index)
+			}
+			if err := os.Remove(dumped); err != nil {
+				s.logger.Warn("删除中间帧文件失败", "file", dumped, "error", err)
+			}
+		} else if err := os.Rename(dumped, target); err != nil {
+			return errors.Wrapf(err, errors.ErrorTypeIO, "RENAME_FRAME",
+				"重命名第%d帧失败", frame.Index)
+		}
+
+		frame.Path = target
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecomposeAnimation_RawFramesManifest(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	mockOutput := `Canvas size: 100 x 80
+Loop Count : 2
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:    100     80   yes         0        0       70    none    no        172      lossy
+  2:     20     10   yes        15       25       40 background   yes        518      lossy`
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", mockOutput)
+
+	outputDir := t.TempDir()
+	manifest, err := service.DecomposeAnimation(context.Background(), "test.webp", outputDir, "webp", false)
+	if err != nil {
+		t.Fatalf("DecomposeAnimation failed: %v", err)
+	}
+
+	if manifest.Loop != 2 || len(manifest.Frames) != 2 {
+		t.Fatalf("Unexpected manifest: %+v", manifest)
+	}
+
+	frame := manifest.Frames[1]
+	if frame.File != "frame_2.webp" || frame.X != 15 || frame.Y != 25 || frame.Duration != 40 {
+		t.Errorf("Unexpected frame entry: %+v", frame)
+	}
+	if frame.Dispose != "background" || frame.Blend != "yes" {
+		t.Errorf("Unexpected dispose/blend: %s/%s", frame.Dispose, frame.Blend)
+	}
+
+	// 清单可作为合成清单读取
+	frames, loop, err := service.LoadComposeManifest(filepath.Join(outputDir, FrameManifestFile))
+	if err != nil {
+		t.Fatalf("LoadComposeManifest failed: %v", err)
+	}
+	if loop != 2 || frames[0].Path != filepath.Join(outputDir, "frame_1.webp") {
+		t.Errorf("Unexpected compose frames: loop=%d path=%s", loop, frames[0].Path)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"image"
+	"image/draw"
+
+	"webpcompressor/internal/domain"
+)
+
+// deduplicateFrames 提取selected中各帧的完整画布并合并画面相同的连续帧
+//
+// 只有合成后的完整画布才能判断某一帧是否真正改变了画面，因此去重总是基于anim_dump的输出；
+// 返回的帧覆盖整个画布，重复帧的时长并入前一帧
+func (s *WebPService) deduplicateFrames(ctx context.Context, inputPath, tempDir string, animInfo *domain.AnimationInfo, selected []*domain.FrameInfo) ([]*domain.FrameInfo, int, error) {
+	frames := make([]*domain.FrameInfo, len(selected))
+	for i, frame := range selected {
+		copied := *frame
+		frames[i] = &copied
+	}
+
+	if err := s.extractFullCanvasFrames(ctx, inputPath, tempDir, animInfo, frames); err != nil {
+		return nil, 0, err
+	}
+
+	kept := make([]*domain.FrameInfo, 0, len(frames))
+	var lastHash []byte
+	for _, frame := range frames {
+		hash, err := hashDecodedFrame(frame.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if lastHash != nil && bytes.Equal(hash, lastHash) {
+			kept[len(kept)-1].Duration += frame.Duration
+			s.logger.Debug("合并重复帧", "index", frame.Index, "into", kept[len(kept)-1].Index)
+			continue
+		}
+
+		kept = append(kept, frame)
+		lastHash = hash
+	}
+
+	merged := len(frames) - len(kept)
+	s.logger.Info("重复帧去重完成", "frames", len(frames), "merged", merged)
+	return kept, merged, nil
+}
+
+// hashDecodedFrame 计算PNG帧解码后像素的SHA-256
+func hashDecodedFrame(path string) ([]byte, error) {
+	img, err := readPNG(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 统一转换为NRGBA，避免不同PNG颜色类型导致相同画面哈希不同
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	sum := sha256.Sum256(nrgba.Pix)
+	return sum[:], nil
+}
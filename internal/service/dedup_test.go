@@ -0,0 +1,55 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create png: %v", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+}
+
+func TestHashDecodedFrame_IgnoresColorModel(t *testing.T) {
+	dir := t.TempDir()
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			rgba.Set(x, y, color.NRGBA{R: 10, G: 10, B: 10, A: 255})
+			gray.Set(x, y, color.Gray{Y: 10})
+		}
+	}
+	different := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	different.Set(1, 1, color.NRGBA{R: 255, A: 255})
+
+	writeTestPNG(t, filepath.Join(dir, "a.png"), rgba)
+	writeTestPNG(t, filepath.Join(dir, "b.png"), gray)
+	writeTestPNG(t, filepath.Join(dir, "c.png"), different)
+
+	hashA, err := hashDecodedFrame(filepath.Join(dir, "a.png"))
+	if err != nil {
+		t.Fatalf("hashDecodedFrame failed: %v", err)
+	}
+	hashB, _ := hashDecodedFrame(filepath.Join(dir, "b.png"))
+	hashC, _ := hashDecodedFrame(filepath.Join(dir, "c.png"))
+
+	if string(hashA) != string(hashB) {
+		t.Error("Expected identical pixels to produce identical hashes")
+	}
+	if string(hashA) == string(hashC) {
+		t.Error("Expected different pixels to produce different hashes")
+	}
+}
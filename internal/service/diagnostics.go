@@ -0,0 +1,177 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"webpcompressor/internal/config"
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// diagnosticTools 诊断包中记录版本的工具
+var diagnosticTools = []string{"webpmux", "cwebp", "dwebp", "anim_dump"}
+
+// 诊断包中的条目
+const (
+	diagnosticsJobFile      = "job.json"
+	diagnosticsErrorFile    = "error.txt"
+	diagnosticsVersionsFile = "versions.txt"
+	diagnosticsInputDir     = "input"
+)
+
+// diagnosticsJob 诊断包中记录的任务信息，replay据此在本地重新执行失败的任务
+type diagnosticsJob struct {
+	Input      string                   `json:"input"`                // 输入文件在诊断包中的路径
+	Settings   domain.CompressionConfig `json:"settings"`             // 实际生效的压缩设置
+	Processing config.ProcessingConfig  `json:"processing"`           // 生成诊断包时的处理配置
+	ErrorCode  string                   `json:"error_code,omitempty"` // 失败的错误码
+}
+
+// writeDiagnosticsBundle 组装失败时将工作目录打包为诊断zip，返回诊断包路径
+//
+// 诊断包包含输入文件、压缩设置、组装清单、错误信息、webpmux标准错误输出、工具版本以及临时目录中的全部文件，
+// 临时目录随后会被清理，用户可以把诊断包附在问题反馈中，维护者可用replay命令在本地重放
+func (s *WebPService) writeDiagnosticsBundle(ctx context.Context, workspace, inputPath string, settings *domain.CompressionConfig, manifest *domain.AssemblyManifest, assemblyErr error) (string, error) {
+	dir := s.config.Processing.DiagnosticsDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "CREATE_DIAGNOSTICS_DIR",
+			fmt.Sprintf("创建诊断目录失败: %s", dir))
+	}
+
+	// 同一秒内可能有多个任务失败，文件名带随机后缀，避免互相覆盖
+	file, err := os.CreateTemp(dir, fmt.Sprintf("webp_diagnostics_%s_*.zip", time.Now().Format("20060102_150405")))
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "CREATE_DIAGNOSTICS", "创建诊断包失败")
+	}
+	bundlePath := file.Name()
+
+	zw := zip.NewWriter(file)
+	err = s.fillDiagnosticsBundle(ctx, zw, workspace, inputPath, settings, manifest, assemblyErr)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(bundlePath)
+		return "", errors.Wrap(err, errors.ErrorTypeIO, "WRITE_DIAGNOSTICS", "写入诊断包失败")
+	}
+
+	return bundlePath, nil
+}
+
+// fillDiagnosticsBundle 向诊断包写入各项内容
+func (s *WebPService) fillDiagnosticsBundle(ctx context.Context, zw *zip.Writer, workspace, inputPath string, settings *domain.CompressionConfig, manifest *domain.AssemblyManifest, assemblyErr error) error {
+	var details, code string
+	if appErr, ok := assemblyErr.(*errors.AppError); ok {
+		details = appErr.Details
+		code = appErr.Code
+	}
+
+	if err := writeZipEntry(zw, diagnosticsErrorFile, []byte(assemblyErr.Error()+"\n")); err != nil {
+		return err
+	}
+	if err := writeZipEntry(zw, "webpmux_stderr.txt", []byte(details)); err != nil {
+		return err
+	}
+
+	if manifest != nil {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeZipEntry(zw, AssemblyManifestFile, data); err != nil {
+			return err
+		}
+	}
+
+	// 输入和设置用于重放，输入文件读取失败时诊断包仍可用于人工排查
+	job := diagnosticsJob{
+		Input:      diagnosticsInputDir + "/" + filepath.Base(inputPath),
+		Settings:   *settings,
+		Processing: s.config.Processing,
+		ErrorCode:  code,
+	}
+	if err := copyFileToZip(zw, inputPath, job.Input); err != nil {
+		s.logger.Warn("打包输入文件失败", "file", inputPath, "error", err)
+		job.Input = ""
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipEntry(zw, diagnosticsJobFile, data); err != nil {
+		return err
+	}
+
+	if err := writeZipEntry(zw, diagnosticsVersionsFile, []byte(s.toolVersions(ctx))); err != nil {
+		return err
+	}
+
+	// 临时目录不可读时只记录警告，保留已写入的其余内容
+	walkErr := filepath.WalkDir(workspace, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(workspace, path)
+		if err != nil {
+			return err
+		}
+		return copyFileToZip(zw, path, filepath.ToSlash(filepath.Join("workspace", rel)))
+	})
+	if walkErr != nil {
+		s.logger.Warn("打包工作目录失败", "workspace", workspace, "error", walkErr)
+	}
+
+	return nil
+}
+
+// toolVersions 返回诊断工具的版本，每行一个"工具: 版本"
+func (s *WebPService) toolVersions(ctx context.Context) string {
+	var versions strings.Builder
+	for _, tool := range diagnosticTools {
+		output, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, tool, "-version")
+		if err != nil {
+			fmt.Fprintf(&versions, "%s: 不可用 (%v)\n", tool, err)
+			continue
+		}
+		fmt.Fprintf(&versions, "%s: %s\n", tool, strings.TrimSpace(output))
+	}
+	return versions.String()
+}
+
+// writeZipEntry 写入一个zip条目
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// copyFileToZip 将文件复制为zip条目
+func copyFileToZip(zw *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
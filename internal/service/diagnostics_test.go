@@ -0,0 +1,92 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestWriteDiagnosticsBundle(t *testing.T) {
+	service := createTestWebPService()
+	service.config.Processing.DiagnosticsDir = t.TempDir()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -version", "1.3.2")
+
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "frame_compressed_1.webp"), []byte("RIFF"), 0644); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+
+	inputPath := filepath.Join(t.TempDir(), "in.webp")
+	if err := os.WriteFile(inputPath, []byte("RIFF"), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	manifest := &domain.AssemblyManifest{
+		Output: "out.webp",
+		Frames: []domain.AssemblyEntry{{Index: 1, File: "frame_compressed_1.webp", Size: 4, Duration: 100}},
+	}
+	assemblyErr := errors.New(errors.ErrorTypeExecution, "ASSEMBLE_ANIMATION", "重新组装动画失败").
+		WithDetails("Failed to create mux object")
+
+	bundlePath, err := service.writeDiagnosticsBundle(context.Background(), workspace, inputPath, &domain.CompressionConfig{Quality: 40}, manifest, assemblyErr)
+	if err != nil {
+		t.Fatalf("writeDiagnosticsBundle failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer reader.Close()
+
+	names := make(map[string]bool)
+	for _, f := range reader.File {
+		names[f.Name] = true
+	}
+	for _, want := range []string{"error.txt", "webpmux_stderr.txt", "versions.txt", "job.json", "input/in.webp", AssemblyManifestFile, "workspace/frame_compressed_1.webp"} {
+		if !names[want] {
+			t.Errorf("Bundle missing %s, got %v", want, names)
+		}
+	}
+
+	if !strings.HasPrefix(filepath.Base(bundlePath), "webp_diagnostics_") {
+		t.Errorf("Unexpected bundle name: %s", bundlePath)
+	}
+}
+
+func TestWriteDiagnosticsBundle_UniqueNames(t *testing.T) {
+	service := createTestWebPService()
+	service.config.Processing.DiagnosticsDir = t.TempDir()
+
+	inputPath := filepath.Join(t.TempDir(), "in.webp")
+	if err := os.WriteFile(inputPath, []byte("RIFF"), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	manifest := &domain.AssemblyManifest{Output: "out.webp"}
+	assemblyErr := errors.New(errors.ErrorTypeExecution, "ASSEMBLE_ANIMATION", "重新组装动画失败")
+
+	// 同一秒内的多次失败各自保留诊断包
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		bundlePath, err := service.writeDiagnosticsBundle(context.Background(), t.TempDir(), inputPath, &domain.CompressionConfig{Quality: 40}, manifest, assemblyErr)
+		if err != nil {
+			t.Fatalf("writeDiagnosticsBundle failed: %v", err)
+		}
+		if seen[bundlePath] {
+			t.Fatalf("Bundle path reused: %s", bundlePath)
+		}
+		seen[bundlePath] = true
+	}
+
+	entries, err := os.ReadDir(service.config.Processing.DiagnosticsDir)
+	if err != nil || len(entries) != 3 {
+		t.Errorf("Expected 3 bundles, got %d (%v)", len(entries), err)
+	}
+}
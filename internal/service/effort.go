@@ -0,0 +1,112 @@
+package service
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// effortLevel 某一努力程度对应的cwebp压缩方法和分析遍数
+type effortLevel struct {
+	method int
+	pass   int
+}
+
+// effortLevels 努力程度1-9，依次提高压缩方法，高档位再增加遍数
+var effortLevels = [9]effortLevel{
+	{0, 1}, {1, 1}, {2, 1}, {3, 2}, {4, 3}, {5, 4}, {6, 6}, {6, 8}, {6, 10},
+}
+
+// cpuBenchmarkIterations 单核速度测试的迭代次数，在常见桌面CPU上约需数毫秒
+const cpuBenchmarkIterations = 1 << 22
+
+// slowCPUBenchmark 单核速度测试超过此耗时视为慢核心（共享虚拟机等）
+const slowCPUBenchmark = 8 * time.Millisecond
+
+// cpuProfile 缓存机器类别的检测结果，每个服务实例只检测一次
+type cpuProfile struct {
+	once  sync.Once
+	class string
+	cores int
+}
+
+// cpuClass 返回机器类别和可用核心数，配置了Processing.CPUClass时直接使用
+func (s *WebPService) cpuClass() (string, int) {
+	profile := s.cpuProfile
+	profile.once.Do(func() {
+		profile.cores = runtime.NumCPU()
+		if s.config.Processing.CPUClass != "" {
+			profile.class = s.config.Processing.CPUClass
+			return
+		}
+
+		benchmark := measureCPUSpeed()
+		profile.class = classifyCPU(profile.cores, benchmark)
+		s.logger.Debug("机器类别检测完成", "class", profile.class, "cores", profile.cores, "benchmark", benchmark)
+	})
+	return profile.class, profile.cores
+}
+
+// measureCPUSpeed 执行固定次数的整数运算，返回单核耗时
+func measureCPUSpeed() time.Duration {
+	startTime := time.Now()
+	x := uint64(88172645463325252)
+	for i := 0; i < cpuBenchmarkIterations; i++ {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+	}
+	elapsed := time.Since(startTime)
+	runtime.KeepAlive(x)
+	return elapsed
+}
+
+// classifyCPU 按核心数和单核速度划分机器类别：核心多且不慢为服务器，核心少或单核慢为CI运行器，其余为笔记本
+func classifyCPU(cores int, benchmark time.Duration) string {
+	slow := benchmark > slowCPUBenchmark
+	switch {
+	case cores >= 16 && !slow:
+		return domain.CPUClassServer
+	case cores <= 4 || slow:
+		return domain.CPUClassCI
+	default:
+		return domain.CPUClassLaptop
+	}
+}
+
+// applyEffort 按努力程度和机器类别返回调整了Method、Pass和并发数的配置副本，不修改调用方的配置
+func (s *WebPService) applyEffort(config *domain.CompressionConfig) *domain.CompressionConfig {
+	class, cores := s.cpuClass()
+	tuned := effortConfig(config, class, cores)
+
+	s.logger.Info("按努力程度调整压缩参数",
+		"effort", config.Effort,
+		"cpu_class", class,
+		"method", tuned.Method,
+		"pass", tuned.Pass,
+		"concurrency", tuned.MaxConcurrency,
+	)
+	return tuned
+}
+
+// effortConfig 由努力程度表和机器类别计算配置副本
+func effortConfig(config *domain.CompressionConfig, class string, cores int) *domain.CompressionConfig {
+	level := effortLevels[config.Effort-1]
+
+	tuned := *config
+	tuned.Method = level.method
+	tuned.Pass = level.pass
+	tuned.EnableParallel = true
+	tuned.MaxConcurrency = cores
+
+	switch class {
+	case domain.CPUClassLaptop:
+		tuned.MaxConcurrency = max(1, cores/2)
+		tuned.Pass = min(tuned.Pass, 6)
+	case domain.CPUClassCI:
+		tuned.Pass = min(tuned.Pass, 4)
+	}
+	return &tuned
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestClassifyCPU(t *testing.T) {
+	tests := []struct {
+		cores     int
+		benchmark time.Duration
+		expected  string
+	}{
+		{32, 2 * time.Millisecond, domain.CPUClassServer},
+		{32, 20 * time.Millisecond, domain.CPUClassCI},
+		{2, 2 * time.Millisecond, domain.CPUClassCI},
+		{8, 2 * time.Millisecond, domain.CPUClassLaptop},
+	}
+
+	for _, tt := range tests {
+		if class := classifyCPU(tt.cores, tt.benchmark); class != tt.expected {
+			t.Errorf("classifyCPU(%d, %v): expected %s, got %s", tt.cores, tt.benchmark, tt.expected, class)
+		}
+	}
+}
+
+func TestEffortConfig(t *testing.T) {
+	config := domain.DefaultCompressionConfig(50)
+	config.Effort = 9
+
+	tests := []struct {
+		class       string
+		pass        int
+		concurrency int
+	}{
+		{domain.CPUClassServer, 10, 16},
+		{domain.CPUClassCI, 4, 16},
+		{domain.CPUClassLaptop, 6, 8},
+	}
+
+	for _, tt := range tests {
+		tuned := effortConfig(config, tt.class, 16)
+		if tuned.Method != 6 || tuned.Pass != tt.pass || tuned.MaxConcurrency != tt.concurrency {
+			t.Errorf("%s: expected -m 6 -pass %d x%d, got -m %d -pass %d x%d",
+				tt.class, tt.pass, tt.concurrency, tuned.Method, tuned.Pass, tuned.MaxConcurrency)
+		}
+	}
+
+	if config.Pass != 0 || config.MaxConcurrency != 4 {
+		t.Error("effortConfig should not modify the caller's config")
+	}
+}
+
+func TestCompressAnimation_EffortSetsCwebpArgs(t *testing.T) {
+	service := createTestWebPService()
+	service.config.Processing.CPUClass = domain.CPUClassServer
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Effort = 2
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Method != 1 {
+		t.Errorf("Expected method 1, got %d", result.Method)
+	}
+
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") && (!strings.Contains(cmd, "-m 1 ") || !strings.Contains(cmd, "-pass 1 ")) {
+			t.Errorf("Expected -m 1 -pass 1, got %s", cmd)
+		}
+	}
+}
+
+func TestValidateInput_InvalidEffort(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Effort = 10
+	if err := service.validateInput("test.webp", "out.webp", config); !errors.IsCode(err, "INVALID_EFFORT") {
+		t.Errorf("Expected INVALID_EFFORT, got %v", err)
+	}
+}
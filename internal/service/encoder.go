@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// cwebpEncoder 使用libwebp自带的cwebp编码单帧
+type cwebpEncoder struct {
+	toolExecutor domain.ToolExecutor
+}
+
+// newCwebpEncoder 创建cwebp编码后端
+func newCwebpEncoder(toolExecutor domain.ToolExecutor) *cwebpEncoder {
+	return &cwebpEncoder{toolExecutor: toolExecutor}
+}
+
+func (e *cwebpEncoder) Name() string {
+	return domain.EncoderCwebp
+}
+
+// Available cwebp在启动时随其他必需工具一起检查
+func (e *cwebpEncoder) Available(ctx context.Context) error {
+	return nil
+}
+
+func (e *cwebpEncoder) EncodeFrame(ctx context.Context, frame *domain.FrameInfo, outputPath string, config *domain.CompressionConfig) error {
+	args, err := newCwebpArgs(config, frame.Path, outputPath).Render()
+	if err != nil {
+		return err
+	}
+
+	if err := e.toolExecutor.ExecuteCommand(ctx, "cwebp", args...); err != nil {
+		return errors.Wrapf(err, errors.ErrorTypeExecution, "COMPRESS_FRAME",
+			"压缩第%d帧失败", frame.Index)
+	}
+	return nil
+}
+
+// ffmpegEncoder 使用ffmpeg编码单帧，编码器由配置指定
+type ffmpegEncoder struct {
+	toolExecutor domain.ToolExecutor
+	logger       logger.Logger
+	codec        string
+
+	// 可用编码器的探测结果，每个实例只探测一次
+	once     sync.Once
+	encoders map[string]bool
+	err      error
+}
+
+// newFFmpegEncoder 创建使用指定ffmpeg编码器的编码后端
+func newFFmpegEncoder(toolExecutor domain.ToolExecutor, logger logger.Logger, codec string) *ffmpegEncoder {
+	return &ffmpegEncoder{toolExecutor: toolExecutor, logger: logger, codec: codec}
+}
+
+func (e *ffmpegEncoder) Name() string {
+	return domain.EncoderFFmpeg
+}
+
+// Available 检查ffmpeg支持配置的编码器，首次调用时执行ffmpeg -encoders探测
+func (e *ffmpegEncoder) Available(ctx context.Context) error {
+	e.once.Do(func() {
+		output, err := e.toolExecutor.ExecuteCommandWithOutput(ctx, "ffmpeg", "-hide_banner", "-encoders")
+		if err != nil {
+			e.err = errors.Wrap(err, errors.ErrorTypeConfiguration, "ENCODER_UNAVAILABLE", "探测ffmpeg编码器失败")
+			return
+		}
+		e.encoders = parseFFmpegEncoders(output)
+		e.logger.Debug("ffmpeg编码器探测完成", "count", len(e.encoders))
+	})
+	if e.err != nil {
+		return e.err
+	}
+	if !e.encoders[e.codec] {
+		return errors.New(errors.ErrorTypeConfiguration, "ENCODER_UNAVAILABLE",
+			fmt.Sprintf("ffmpeg不支持编码器: %s", e.codec))
+	}
+	return nil
+}
+
+func (e *ffmpegEncoder) EncodeFrame(ctx context.Context, frame *domain.FrameInfo, outputPath string, config *domain.CompressionConfig) error {
+	args, err := newFFmpegWebPArgs(e.codec, config, frame.Path, outputPath).Render()
+	if err != nil {
+		return err
+	}
+
+	if err := e.toolExecutor.ExecuteCommand(ctx, "ffmpeg", args...); err != nil {
+		return errors.Wrapf(err, errors.ErrorTypeExecution, "COMPRESS_FRAME",
+			"ffmpeg压缩第%d帧失败", frame.Index)
+	}
+	return nil
+}
+
+// parseFFmpegEncoders 解析ffmpeg -encoders输出中的视频编码器名称
+//
+// 编码器行格式: " V....D libwebp   libwebp WebP image (codec webp)"，首列为能力标志
+func parseFFmpegEncoders(output string) map[string]bool {
+	encoders := make(map[string]bool)
+	started := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		// 标志说明之后以 "------" 分隔编码器列表
+		if len(fields) == 1 && strings.HasPrefix(fields[0], "---") {
+			started = true
+			continue
+		}
+		if started && len(fields) >= 2 && strings.HasPrefix(fields[0], "V") {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// RegisterEncoder 注册逐帧编码后端，已有同名后端时替换，应在开始处理前调用
+func (s *WebPService) RegisterEncoder(encoder domain.FrameEncoder) {
+	s.encoders[encoder.Name()] = encoder
+}
+
+// frameEncoder 返回任务选择的编码后端，未指定时使用cwebp
+func (s *WebPService) frameEncoder(config *domain.CompressionConfig) (domain.FrameEncoder, error) {
+	name := config.Encoder
+	if name == "" {
+		name = domain.EncoderCwebp
+	}
+	encoder, ok := s.encoders[name]
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeValidation, "INVALID_ENCODER",
+			fmt.Sprintf("不支持的编码后端: %s", config.Encoder))
+	}
+	return encoder, nil
+}
+
+// ensureEncoderAvailable 检查任务选择的编码后端在本机可用
+func (s *WebPService) ensureEncoderAvailable(ctx context.Context, config *domain.CompressionConfig) error {
+	encoder, err := s.frameEncoder(config)
+	if err != nil {
+		return err
+	}
+	return encoder.Available(ctx)
+}
+
+// encodeFrame 用任务选择的编码后端编码单帧
+func (s *WebPService) encodeFrame(ctx context.Context, frame *domain.FrameInfo, outputPath string, config *domain.CompressionConfig) error {
+	encoder, err := s.frameEncoder(config)
+	if err != nil {
+		return err
+	}
+	return encoder.EncodeFrame(ctx, frame, outputPath, config)
+}
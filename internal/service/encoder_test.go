@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+const ffmpegEncodersOutput = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libwebp_anim         libwebp WebP image (codec webp)
+ V....D libwebp              libwebp WebP image (codec webp)
+ A....D aac                  AAC (Advanced Audio Coding)`
+
+func TestParseFFmpegEncoders(t *testing.T) {
+	encoders := parseFFmpegEncoders(ffmpegEncodersOutput)
+
+	if !encoders["libwebp"] || !encoders["libwebp_anim"] {
+		t.Errorf("Expected libwebp encoders, got %v", encoders)
+	}
+	if encoders["aac"] || encoders["="] {
+		t.Errorf("Unexpected non-video encoders: %v", encoders)
+	}
+}
+
+func TestCompressAnimation_FFmpegEncoder(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+	mockToolExecutor.SetMockOutput("ffmpeg -hide_banner -encoders", ffmpegEncodersOutput)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Encoder = domain.EncoderFFmpeg
+	if _, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config); err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	encoded := 0
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") {
+			t.Errorf("Unexpected cwebp call: %s", cmd)
+		}
+		if strings.HasPrefix(cmd, "ffmpeg ") && strings.Contains(cmd, "-c:v libwebp") {
+			encoded++
+		}
+	}
+	if encoded != 2 {
+		t.Errorf("Expected 2 ffmpeg encodes, got %d", encoded)
+	}
+}
+
+func TestCompressAnimation_FFmpegCodecUnavailable(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("ffmpeg -hide_banner -encoders", ffmpegEncodersOutput)
+	service.RegisterEncoder(newFFmpegEncoder(mockToolExecutor, service.logger, "vendor_webp"))
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Encoder = domain.EncoderFFmpeg
+	_, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if !errors.IsCode(err, "ENCODER_UNAVAILABLE") {
+		t.Errorf("Expected ENCODER_UNAVAILABLE, got %v", err)
+	}
+}
+
+// recordingEncoder 记录编码过的帧，不调用任何工具
+type recordingEncoder struct {
+	mu     sync.Mutex
+	frames []int
+}
+
+func (e *recordingEncoder) Name() string { return "recording" }
+
+func (e *recordingEncoder) Available(ctx context.Context) error { return nil }
+
+func (e *recordingEncoder) EncodeFrame(ctx context.Context, frame *domain.FrameInfo, outputPath string, config *domain.CompressionConfig) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.frames = append(e.frames, frame.Index)
+	return nil
+}
+
+func TestCompressAnimation_RegisteredEncoder(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	encoder := &recordingEncoder{}
+	service.RegisterEncoder(encoder)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Encoder = encoder.Name()
+	if _, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config); err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	if len(encoder.frames) != 2 {
+		t.Errorf("Expected 2 frames encoded by registered encoder, got %v", encoder.frames)
+	}
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") || strings.HasPrefix(cmd, "ffmpeg ") {
+			t.Errorf("Unexpected tool call: %s", cmd)
+		}
+	}
+}
+
+func TestCwebpEncoder_EncodeFrame(t *testing.T) {
+	executor := NewMockToolExecutor()
+	encoder := newCwebpEncoder(executor)
+
+	frame := &domain.FrameInfo{Index: 3, Path: "in.webp"}
+	if err := encoder.EncodeFrame(context.Background(), frame, "out.webp", domain.DefaultCompressionConfig(60)); err != nil {
+		t.Fatalf("EncodeFrame failed: %v", err)
+	}
+	if len(executor.commands) != 1 || !strings.HasPrefix(executor.commands[0], "cwebp ") ||
+		!strings.Contains(executor.commands[0], "-q 60") {
+		t.Errorf("Unexpected commands: %v", executor.commands)
+	}
+
+	executor.SetMockError(executor.commands[0], fmt.Errorf("exit status 1"))
+	if err := encoder.EncodeFrame(context.Background(), frame, "out.webp", domain.DefaultCompressionConfig(60)); !errors.IsCode(err, "COMPRESS_FRAME") {
+		t.Errorf("Expected COMPRESS_FRAME, got %v", err)
+	}
+}
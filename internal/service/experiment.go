@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// experimentStrategies A/B实验组可用的策略，在任务配置的副本上修改
+var experimentStrategies = map[string]func(config *domain.CompressionConfig){
+	"img2webp": func(config *domain.CompressionConfig) {
+		config.Pipeline = domain.PipelineImg2webp
+		config.Best = false
+	},
+	"mixed": func(config *domain.CompressionConfig) { config.Mixed = true },
+	"dedup": func(config *domain.CompressionConfig) { config.Deduplicate = true },
+	"best":  func(config *domain.CompressionConfig) { config.Best = true },
+}
+
+// experimentRecord 一次实验任务的指标，按行写入实验记录文件
+type experimentRecord struct {
+	Time           time.Time     `json:"time"`
+	Strategy       string        `json:"strategy"`
+	Group          string        `json:"group"`
+	Input          string        `json:"input"`
+	OriginalSize   int64         `json:"original_size,omitempty"`
+	CompressedSize int64         `json:"compressed_size,omitempty"`
+	Ratio          float64       `json:"compression_ratio,omitempty"`
+	Duration       time.Duration `json:"duration"`
+	Frames         int           `json:"frames,omitempty"`
+	Pipeline       string        `json:"pipeline,omitempty"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// experimentGroup 按输入路径的哈希稳定地分组，同一输入总是落在同一组
+func experimentGroup(inputPath, strategy string, percent int) string {
+	h := fnv.New32a()
+	h.Write([]byte(strategy))
+	h.Write([]byte{0})
+	h.Write([]byte(inputPath))
+	if int(h.Sum32()%100) < percent {
+		return domain.ExperimentTreatment
+	}
+	return domain.ExperimentControl
+}
+
+// assignExperiment 为任务分组，实验组返回应用了实验策略的配置副本
+//
+// 未配置实验时返回空分组；实验策略与任务的其他选项冲突时记录警告并按对照组处理，实验不应导致任务失败
+func (s *WebPService) assignExperiment(inputPath, outputPath string, config *domain.CompressionConfig) (string, *domain.CompressionConfig) {
+	strategy := s.config.Processing.ExperimentStrategy
+	apply, ok := experimentStrategies[strategy]
+	if !ok {
+		return "", config
+	}
+
+	group := experimentGroup(inputPath, strategy, s.config.Processing.ExperimentPercent)
+	if group == domain.ExperimentControl {
+		return group, config
+	}
+
+	tuned := *config
+	apply(&tuned)
+	if err := s.validateInput(inputPath, outputPath, &tuned); err != nil {
+		s.logger.Warn("实验策略与任务配置冲突，按对照组处理", "strategy", strategy, "error", err)
+		return domain.ExperimentControl, config
+	}
+
+	s.logger.Info("任务进入实验组", "strategy", strategy, "input", inputPath)
+	return group, &tuned
+}
+
+// recordExperiment 把实验任务的指标追加到实验记录文件，失败只记录警告
+func (s *WebPService) recordExperiment(group, inputPath string, result *domain.CompressResult, duration time.Duration, taskErr error) {
+	record := experimentRecord{
+		Time:     time.Now(),
+		Strategy: s.config.Processing.ExperimentStrategy,
+		Group:    group,
+		Input:    inputPath,
+		Duration: duration,
+	}
+	if taskErr != nil {
+		record.Error = taskErr.Error()
+	} else {
+		record.OriginalSize = result.OriginalSize
+		record.CompressedSize = result.CompressedSize
+		record.Ratio = result.CompressionRatio
+		record.Frames = result.FramesProcessed
+		record.Pipeline = result.Pipeline
+	}
+
+	s.logger.Info("实验指标",
+		"strategy", record.Strategy,
+		"group", group,
+		"compression_ratio", record.Ratio,
+		"duration", duration,
+		"failed", taskErr != nil,
+	)
+
+	path := s.config.Processing.ExperimentLog
+	if path == "" {
+		return
+	}
+
+	s.experimentMu.Lock()
+	defer s.experimentMu.Unlock()
+	if err := appendJSONLine(path, record); err != nil {
+		s.logger.Warn("写入实验记录失败", "file", path, "error", err)
+	}
+}
+
+// ExperimentReport 汇总实验记录文件中各分组的任务数、失败数、平均压缩率和平均处理时间
+func (s *WebPService) ExperimentReport(path string) (*domain.ExperimentReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "READ_EXPERIMENT_LOG", "打开实验记录文件失败")
+	}
+	defer file.Close()
+
+	groups := map[string]*domain.ExperimentGroupStats{
+		domain.ExperimentControl:   {Group: domain.ExperimentControl},
+		domain.ExperimentTreatment: {Group: domain.ExperimentTreatment},
+	}
+	var ratioSums = make(map[string]float64)
+	var durationSums = make(map[string]time.Duration)
+	report := &domain.ExperimentReport{}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record experimentRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, errors.Wrapf(err, errors.ErrorTypeValidation, "INVALID_EXPERIMENT_LOG", "实验记录第%d行格式错误", line)
+		}
+		stats, ok := groups[record.Group]
+		if !ok {
+			continue
+		}
+
+		// 策略更换后旧记录没有可比性，只汇总最新策略的记录
+		if record.Strategy != report.Strategy {
+			report.Strategy = record.Strategy
+			for _, g := range groups {
+				*g = domain.ExperimentGroupStats{Group: g.Group}
+			}
+			ratioSums = make(map[string]float64)
+			durationSums = make(map[string]time.Duration)
+		}
+
+		stats.Jobs++
+		if record.Error != "" {
+			stats.Failures++
+			continue
+		}
+		ratioSums[record.Group] += record.Ratio
+		durationSums[record.Group] += record.Duration
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "READ_EXPERIMENT_LOG", "读取实验记录文件失败")
+	}
+
+	for _, group := range []string{domain.ExperimentControl, domain.ExperimentTreatment} {
+		stats := groups[group]
+		if succeeded := stats.Jobs - stats.Failures; succeeded > 0 {
+			stats.AvgRatio = ratioSums[group] / float64(succeeded)
+			stats.AvgDuration = durationSums[group] / time.Duration(succeeded)
+		}
+		report.Groups = append(report.Groups, *stats)
+	}
+	return report, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestExperimentGroup(t *testing.T) {
+	treatment := 0
+	for i := 0; i < 1000; i++ {
+		input := fmt.Sprintf("input_%d.webp", i)
+		if experimentGroup(input, "mixed", 0) != domain.ExperimentControl {
+			t.Fatal("Expected all jobs in control at 0%")
+		}
+		if experimentGroup(input, "mixed", 100) != domain.ExperimentTreatment {
+			t.Fatal("Expected all jobs in treatment at 100%")
+		}
+		if experimentGroup(input, "mixed", 30) == domain.ExperimentTreatment {
+			treatment++
+		}
+	}
+
+	if treatment < 200 || treatment > 400 {
+		t.Errorf("Expected about 30%% of jobs in treatment, got %d/1000", treatment)
+	}
+	if experimentGroup("a.webp", "mixed", 30) != experimentGroup("a.webp", "mixed", 30) {
+		t.Error("Expected the same input to stay in the same group")
+	}
+}
+
+func TestCompressAnimation_ExperimentTreatment(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "experiments.jsonl")
+
+	service := createTestWebPService()
+	service.config.Processing.ExperimentStrategy = "mixed"
+	service.config.Processing.ExperimentPercent = 100
+	service.config.Processing.ExperimentLog = logPath
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.ExperimentGroup != domain.ExperimentTreatment {
+		t.Errorf("Expected treatment group, got %q", result.ExperimentGroup)
+	}
+	if config.Mixed {
+		t.Error("Experiment strategy should not modify the caller's config")
+	}
+
+	lossless := false
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp -lossless") {
+			lossless = true
+		}
+	}
+	if !lossless {
+		t.Error("Expected mixed strategy to try lossless encoding")
+	}
+
+	report, err := service.ExperimentReport(logPath)
+	if err != nil {
+		t.Fatalf("ExperimentReport failed: %v", err)
+	}
+	if report.Strategy != "mixed" || report.Groups[1].Jobs != 1 || report.Groups[0].Jobs != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestExperimentReport_LatestStrategyOnly(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "experiments.jsonl")
+	lines := []string{
+		`{"strategy":"dedup","group":"treatment","compression_ratio":10,"duration":1000}`,
+		`{"strategy":"mixed","group":"control","compression_ratio":60,"duration":2000}`,
+		`{"strategy":"mixed","group":"control","compression_ratio":40,"duration":4000}`,
+		`{"strategy":"mixed","group":"treatment","compression_ratio":30,"duration":3000}`,
+		`{"strategy":"mixed","group":"treatment","error":"boom","duration":100}`,
+	}
+	if err := os.WriteFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	report, err := createTestWebPService().ExperimentReport(logPath)
+	if err != nil {
+		t.Fatalf("ExperimentReport failed: %v", err)
+	}
+
+	control, treatment := report.Groups[0], report.Groups[1]
+	if report.Strategy != "mixed" {
+		t.Errorf("Expected strategy mixed, got %s", report.Strategy)
+	}
+	if control.Jobs != 2 || control.AvgRatio != 50 || control.AvgDuration != 3000 {
+		t.Errorf("Unexpected control stats: %+v", control)
+	}
+	if treatment.Jobs != 2 || treatment.Failures != 1 || treatment.AvgRatio != 30 {
+		t.Errorf("Unexpected treatment stats: %+v", treatment)
+	}
+}
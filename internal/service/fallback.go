@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// compressWithFallbacks 主处理管线失败后依次尝试其他策略，返回第一个成功的结果
+//
+// 顺序为另一处理管线（--best已尝试过两种管线时跳过）、GIF往返、原样复制；全部失败时返回主管线的错误
+func (s *WebPService) compressWithFallbacks(ctx context.Context, inputPath, outputPath string, config *domain.CompressionConfig, primaryErr error) (*domain.CompressResult, error) {
+	var strategies []string
+	if !config.Best {
+		if config.Pipeline == domain.PipelineImg2webp {
+			strategies = append(strategies, domain.FallbackWebpmux)
+		} else {
+			strategies = append(strategies, domain.FallbackImg2webp)
+		}
+	}
+	strategies = append(strategies, domain.FallbackGIF, domain.FallbackPassthrough)
+
+	for _, strategy := range strategies {
+		if ctx.Err() != nil {
+			break
+		}
+
+		s.logger.Warn("处理失败，尝试回退策略", "strategy", strategy, "error", primaryErr)
+		result, err := s.compressWithStrategy(ctx, strategy, inputPath, outputPath, config)
+		if err != nil {
+			s.logger.Warn("回退策略失败", "strategy", strategy, "error", err)
+			continue
+		}
+
+		result.Fallback = strategy
+		s.logger.Info("回退策略成功", "strategy", strategy)
+		return result, nil
+	}
+
+	return nil, primaryErr
+}
+
+// compressWithStrategy 按单个回退策略处理
+func (s *WebPService) compressWithStrategy(ctx context.Context, strategy, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	switch strategy {
+	case domain.FallbackWebpmux, domain.FallbackImg2webp:
+		return s.compressWithPipeline(ctx, strategy, inputPath, outputPath, fallbackPipelineConfig(strategy, config))
+	case domain.FallbackGIF:
+		return s.compressViaGIF(ctx, inputPath, outputPath, config)
+	default:
+		if err := s.fileManager.CopyFile(inputPath, outputPath); err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeIO, "COPY_OUTPUT", "原样复制输入失败")
+		}
+		return &domain.CompressResult{}, nil
+	}
+}
+
+// fallbackPipelineConfig 去掉目标管线不支持的选项，返回配置副本
+func fallbackPipelineConfig(pipeline string, config *domain.CompressionConfig) *domain.CompressionConfig {
+	fallback := *config
+	fallback.Pipeline = pipeline
+	fallback.Best = false
+	if pipeline == domain.PipelineImg2webp {
+		// img2webp整体编码，逐帧的选项不适用
+		fallback.AutoQuality = false
+		fallback.QualityReport = false
+		fallback.Encoder = ""
+		fallback.Deadline = 0
+		fallback.RecompressAbove = 0
+	}
+	return &fallback
+}
+
+// compressViaGIF 先把动画导出为GIF，再由gif2webp重新编码
+//
+// 不经过逐帧压缩和webpmux组装，代价是GIF调色板带来的画质损失
+func (s *WebPService) compressViaGIF(ctx context.Context, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_gif_fallback")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	gifPath := filepath.Join(tempDir, "fallback.gif")
+	converted, err := s.ConvertAnimation(ctx, inputPath, gifPath, domain.OutputFormatGIF)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.toolExecutor.ExecuteCommand(ctx, "gif2webp", buildGif2webpArgs(gifPath, outputPath, config)...); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeExecution, "GIF2WEBP", "gif2webp重新编码失败")
+	}
+	if !s.fileManager.FileExists(outputPath) {
+		return nil, errors.New(errors.ErrorTypeExecution, "OUTPUT_NOT_CREATED", "gif2webp未生成输出文件")
+	}
+
+	return &domain.CompressResult{
+		FramesProcessed: converted.FramesProcessed,
+		ParallelWorkers: 1,
+	}, nil
+}
+
+// buildGif2webpArgs 由压缩配置生成gif2webp参数
+func buildGif2webpArgs(inputPath, outputPath string, config *domain.CompressionConfig) []string {
+	var args []string
+	switch {
+	case config.Mixed:
+		args = append(args, "-mixed")
+	case !config.Lossless:
+		args = append(args, "-lossy")
+	}
+	args = append(args, "-q", strconv.Itoa(config.Quality), "-m", strconv.Itoa(config.Method))
+	return append(args, inputPath, "-o", outputPath)
+}
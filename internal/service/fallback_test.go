@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// failingToolExecutor 让指定工具的调用全部失败
+type failingToolExecutor struct {
+	*MockToolExecutor
+	tools map[string]bool
+}
+
+func (e *failingToolExecutor) ExecuteCommand(ctx context.Context, toolName string, args ...string) error {
+	if e.tools[toolName] {
+		e.mu.Lock()
+		e.commands = append(e.commands, toolName+" "+strings.Join(args, " "))
+		e.mu.Unlock()
+		return fmt.Errorf("exit status 1")
+	}
+	return e.MockToolExecutor.ExecuteCommand(ctx, toolName, args...)
+}
+
+func newFallbackService(t *testing.T, failingTools ...string) (*WebPService, *failingToolExecutor) {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	// ConvertAnimation读取anim_dump输出的完整画布帧
+	dir := filepath.Join(os.TempDir(), "webp_convert_test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		writeTestPNG(t, filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i)), image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	}
+
+	service := createTestWebPService()
+	executor := &failingToolExecutor{
+		MockToolExecutor: service.toolExecutor.(*MockToolExecutor),
+		tools:            make(map[string]bool),
+	}
+	for _, tool := range failingTools {
+		executor.tools[tool] = true
+	}
+	service = NewWebPService(service.config, executor, service.fileManager, service.logger)
+	executor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+	return service, executor
+}
+
+func TestCompressAnimation_FallsBackToGIF(t *testing.T) {
+	service, executor := newFallbackService(t, "cwebp", "img2webp")
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Fallback = true
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Fallback != domain.FallbackGIF || result.FramesProcessed != 2 {
+		t.Errorf("Expected gif2webp fallback with 2 frames, got %q with %d", result.Fallback, result.FramesProcessed)
+	}
+
+	last := executor.commands[len(executor.commands)-1]
+	if !strings.HasPrefix(last, "gif2webp -lossy -q 50 -m 6 ") || !strings.HasSuffix(last, " -o out.webp") {
+		t.Errorf("Unexpected gif2webp command: %s", last)
+	}
+}
+
+func TestCompressAnimation_FallsBackToPassthrough(t *testing.T) {
+	service, _ := newFallbackService(t, "cwebp", "img2webp", "gif2webp")
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Fallback = true
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Fallback != domain.FallbackPassthrough {
+		t.Errorf("Expected passthrough fallback, got %q", result.Fallback)
+	}
+}
+
+func TestCompressAnimation_NoFallbackByDefault(t *testing.T) {
+	service, _ := newFallbackService(t, "cwebp")
+
+	_, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", domain.DefaultCompressionConfig(50))
+	if !errors.IsCode(err, "COMPRESS_FRAME") {
+		t.Errorf("Expected COMPRESS_FRAME, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// selectFrameRange 按帧序号范围和时间范围筛选帧
+//
+// 帧序号范围为闭区间；时间范围按帧的开始时间判断，保留 TrimStart <= 开始时间 < TrimEnd 的帧。
+// 返回保留的帧（原帧的副本）和裁剪掉的帧数，没有帧被保留时返回错误
+func selectFrameRange(frames []*domain.FrameInfo, config *domain.CompressionConfig) ([]*domain.FrameInfo, int, error) {
+	kept := make([]*domain.FrameInfo, 0, len(frames))
+
+	var start time.Duration
+	for _, frame := range frames {
+		inRange := (config.FrameStart == 0 || frame.Index >= config.FrameStart) &&
+			(config.FrameEnd == 0 || frame.Index <= config.FrameEnd) &&
+			start >= config.TrimStart &&
+			(config.TrimEnd == 0 || start < config.TrimEnd)
+		start += frame.Duration
+
+		if !inRange {
+			continue
+		}
+
+		copied := *frame
+		kept = append(kept, &copied)
+	}
+
+	if len(kept) == 0 {
+		return nil, 0, errors.New(errors.ErrorTypeValidation, "EMPTY_FRAME_RANGE",
+			fmt.Sprintf("指定范围内没有帧: 帧 %d-%d, 时间 %v-%v (共%d帧)",
+				config.FrameStart, config.FrameEnd, config.TrimStart, config.TrimEnd, len(frames)))
+	}
+
+	return kept, len(frames) - len(kept), nil
+}
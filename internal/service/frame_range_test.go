@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestSelectFrameRange_ByIndex(t *testing.T) {
+	frames := createUniformFrames(10, 50*time.Millisecond)
+
+	kept, trimmed, err := selectFrameRange(frames, &domain.CompressionConfig{FrameStart: 3, FrameEnd: 6})
+	if err != nil {
+		t.Fatalf("selectFrameRange failed: %v", err)
+	}
+
+	if trimmed != 6 || len(kept) != 4 {
+		t.Fatalf("Expected 4 kept/6 trimmed, got %d/%d", len(kept), trimmed)
+	}
+	if kept[0].Index != 3 || kept[3].Index != 6 {
+		t.Errorf("Unexpected range: %d-%d", kept[0].Index, kept[3].Index)
+	}
+}
+
+func TestSelectFrameRange_ByTime(t *testing.T) {
+	// 帧开始时间: 0, 100, 200, ... 900ms
+	frames := createUniformFrames(10, 100*time.Millisecond)
+
+	kept, _, err := selectFrameRange(frames, &domain.CompressionConfig{
+		TrimStart: 250 * time.Millisecond,
+		TrimEnd:   600 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("selectFrameRange failed: %v", err)
+	}
+
+	if len(kept) != 3 || kept[0].Index != 4 || kept[2].Index != 6 {
+		t.Errorf("Expected frames 4-6, got %d frames starting at %d", len(kept), kept[0].Index)
+	}
+}
+
+func TestSelectFrameRange_Empty(t *testing.T) {
+	frames := createUniformFrames(5, 100*time.Millisecond)
+
+	_, _, err := selectFrameRange(frames, &domain.CompressionConfig{FrameStart: 8})
+	if !errors.IsCode(err, "EMPTY_FRAME_RANGE") {
+		t.Errorf("Expected EMPTY_FRAME_RANGE, got %v", err)
+	}
+}
+
+func TestParseFrameRange(t *testing.T) {
+	tests := []struct {
+		value      string
+		start, end int
+		wantErr    bool
+	}{
+		{"10-60", 10, 60, false},
+		{"10-", 10, 0, false},
+		{"-60", 0, 60, false},
+		{"7", 7, 7, false},
+		{"60-10", 0, 0, true},
+		{"a-b", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		start, end, err := domain.ParseFrameRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFrameRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("ParseFrameRange(%q) = %d-%d, want %d-%d", tt.value, start, end, tt.start, tt.end)
+		}
+	}
+}
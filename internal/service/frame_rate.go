@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// reduceFrameRate 按丢帧间隔和最大帧率筛选帧，被丢弃帧的时长并入前一个保留帧
+//
+// 返回保留的帧（原帧的副本）和丢弃的帧数，第一帧总是保留
+func reduceFrameRate(frames []*domain.FrameInfo, maxFPS float64, dropEveryN int) ([]*domain.FrameInfo, int) {
+	kept := make([]*domain.FrameInfo, 0, len(frames))
+
+	var minInterval time.Duration
+	if maxFPS > 0 {
+		minInterval = time.Duration(float64(time.Second) / maxFPS)
+	}
+
+	var sinceLastKept time.Duration
+	for i, frame := range frames {
+		drop := false
+		if len(kept) > 0 {
+			if dropEveryN > 1 && (i+1)%dropEveryN == 0 {
+				drop = true
+			}
+			if minInterval > 0 && sinceLastKept < minInterval {
+				drop = true
+			}
+		}
+
+		if drop {
+			last := kept[len(kept)-1]
+			last.Duration += frame.Duration
+			sinceLastKept += frame.Duration
+			continue
+		}
+
+		copied := *frame
+		kept = append(kept, &copied)
+		sinceLastKept = frame.Duration
+	}
+
+	return kept, len(frames) - len(kept)
+}
+
+// needsFullCanvasFrames 判断压缩前是否需要改用完整画布帧
+//
+// 丢帧或裁剪后如果动画的帧依赖前一帧的画面（局部更新或混合），直接丢弃子帧会破坏后续画面，
+// 此时改用anim_dump提取完整画布帧，并把帧改为覆盖整个画布
+func needsFullCanvasFrames(animInfo *domain.AnimationInfo, framesRemoved bool) bool {
+	return framesRemoved && !animInfo.IsSelfContained()
+}
+
+// extractFullCanvasFrames 使用anim_dump提取完整画布帧，并把帧改为覆盖整个画布
+func (s *WebPService) extractFullCanvasFrames(ctx context.Context, inputPath, tempDir string, animInfo *domain.AnimationInfo, frames []*domain.FrameInfo) error {
+	if err := s.dumpFullCanvasFrames(ctx, inputPath, tempDir, frames, "png"); err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		frame.X, frame.Y = 0, 0
+		frame.Width, frame.Height = animInfo.Width, animInfo.Height
+		frame.Dispose = domain.DisposeNone
+		frame.Blend = domain.BlendNo
+	}
+
+	return nil
+}
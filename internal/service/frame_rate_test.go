@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func createUniformFrames(count int, duration time.Duration) []*domain.FrameInfo {
+	frames := make([]*domain.FrameInfo, count)
+	for i := range frames {
+		frames[i] = &domain.FrameInfo{Index: i + 1, Duration: duration}
+	}
+	return frames
+}
+
+func TestReduceFrameRate_MaxFPS(t *testing.T) {
+	// 50fps动画限制到25fps，应隔帧保留且总时长不变
+	frames := createUniformFrames(10, 20*time.Millisecond)
+
+	kept, dropped := reduceFrameRate(frames, 25, 0)
+
+	if dropped != 5 || len(kept) != 5 {
+		t.Fatalf("Expected 5 kept/5 dropped, got %d/%d", len(kept), dropped)
+	}
+
+	var total time.Duration
+	for i, frame := range kept {
+		if frame.Index != 2*i+1 {
+			t.Errorf("Expected frame index %d, got %d", 2*i+1, frame.Index)
+		}
+		total += frame.Duration
+	}
+	if total != 200*time.Millisecond {
+		t.Errorf("Expected total duration 200ms, got %v", total)
+	}
+
+	// 原始帧不应被修改
+	if frames[0].Duration != 20*time.Millisecond {
+		t.Errorf("Source frame mutated: %v", frames[0].Duration)
+	}
+}
+
+func TestReduceFrameRate_DropEveryN(t *testing.T) {
+	frames := createUniformFrames(9, 30*time.Millisecond)
+
+	kept, dropped := reduceFrameRate(frames, 0, 3)
+
+	if dropped != 3 || len(kept) != 6 {
+		t.Fatalf("Expected 6 kept/3 dropped, got %d/%d", len(kept), dropped)
+	}
+	// 第3帧并入第2帧
+	if kept[1].Index != 2 || kept[1].Duration != 60*time.Millisecond {
+		t.Errorf("Unexpected merged frame: index=%d duration=%v", kept[1].Index, kept[1].Duration)
+	}
+}
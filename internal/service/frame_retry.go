@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// compressFrameWithRetries 压缩单帧，失败后按Processing.FrameRetries重试，上下文取消后不再重试
+func (s *WebPService) compressFrameWithRetries(ctx context.Context, frame *domain.FrameInfo, config *domain.CompressionConfig) error {
+	attempts := s.config.Processing.FrameRetries + 1
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = s.compressFrame(ctx, frame, config); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if attempt < attempts {
+			s.logger.Warn("帧压缩失败，准备重试", "index", frame.Index, "attempt", attempt, "max_attempts", attempts, "error", err)
+		}
+	}
+	return err
+}
+
+// keptFrames 去掉处理失败而被丢弃的帧
+func keptFrames(frames []*domain.FrameInfo, failures domain.FrameErrors) []*domain.FrameInfo {
+	failed := make(map[int]bool, len(failures))
+	for _, failure := range failures {
+		failed[failure.Frame.Index] = true
+	}
+
+	kept := make([]*domain.FrameInfo, 0, len(frames)-len(failures))
+	for _, frame := range frames {
+		if !failed[frame.Index] {
+			kept = append(kept, frame)
+		}
+	}
+	return kept
+}
+
+// manifestFrames 由组装清单还原输出动画应有的帧序列
+func manifestFrames(manifest *domain.AssemblyManifest) []*domain.FrameInfo {
+	frames := make([]*domain.FrameInfo, len(manifest.Frames))
+	for i, entry := range manifest.Frames {
+		frames[i] = &domain.FrameInfo{
+			Index:    entry.Index,
+			Duration: time.Duration(entry.Duration) * time.Millisecond,
+		}
+	}
+	return frames
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// failingFrameExecutor 让指定源帧的cwebp调用失败，failures为剩余失败次数，负数表示一直失败
+type failingFrameExecutor struct {
+	*MockToolExecutor
+	frame    string
+	failures int
+}
+
+func (e *failingFrameExecutor) ExecuteCommand(ctx context.Context, toolName string, args ...string) error {
+	if toolName == "cwebp" && strings.Contains(strings.Join(args, " "), e.frame) {
+		e.mu.Lock()
+		fail := e.failures != 0
+		if e.failures > 0 {
+			e.failures--
+		}
+		e.mu.Unlock()
+		if fail {
+			return fmt.Errorf("exit status 255")
+		}
+	}
+	return e.MockToolExecutor.ExecuteCommand(ctx, toolName, args...)
+}
+
+func newFailingFrameService(failures int) (*WebPService, *failingFrameExecutor) {
+	service := createTestWebPService()
+	executor := &failingFrameExecutor{
+		MockToolExecutor: service.toolExecutor.(*MockToolExecutor),
+		frame:            "frame_2.webp",
+		failures:         failures,
+	}
+	service = NewWebPService(service.config, executor, service.fileManager, service.logger)
+	executor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+	return service, executor
+}
+
+func TestCompressAnimation_FailedFrameAbortsByDefault(t *testing.T) {
+	service, _ := newFailingFrameService(-1)
+
+	_, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", domain.DefaultCompressionConfig(50))
+	if !errors.IsCode(err, "COMPRESS_FRAME") {
+		t.Errorf("Expected COMPRESS_FRAME, got %v", err)
+	}
+}
+
+func TestCompressAnimation_RetriesFailedFrame(t *testing.T) {
+	service, _ := newFailingFrameService(1)
+	service.config.Processing.FrameRetries = 1
+
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", domain.DefaultCompressionConfig(50))
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.FramesProcessed != 2 || result.FramesFailed != 0 {
+		t.Errorf("Expected 2 processed and 0 failed, got %d/%d", result.FramesProcessed, result.FramesFailed)
+	}
+}
+
+func TestCompressAnimation_ContinueOnErrorDropsFailedFrame(t *testing.T) {
+	service, executor := newFailingFrameService(-1)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.ContinueOnError = true
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.FramesProcessed != 1 || result.FramesFailed != 1 {
+		t.Errorf("Expected 1 processed and 1 failed, got %d/%d", result.FramesProcessed, result.FramesFailed)
+	}
+
+	// 第2帧的70ms并入第1帧
+	assembled := false
+	for _, cmd := range executor.commands {
+		if strings.HasPrefix(cmd, "webpmux -frame") {
+			assembled = true
+			if !strings.Contains(cmd, "+120+") || strings.Contains(cmd, "frame_compressed_2") {
+				t.Errorf("Unexpected assembly command: %s", cmd)
+			}
+		}
+	}
+	if !assembled {
+		t.Error("Expected webpmux assembly")
+	}
+}
+
+func TestCompressAnimation_ContinueOnErrorAllFramesFailed(t *testing.T) {
+	service, executor := newFailingFrameService(-1)
+	executor.frame = "frame_"
+
+	config := domain.DefaultCompressionConfig(50)
+	config.ContinueOnError = true
+	_, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if !errors.IsCode(err, "ALL_FRAMES_FAILED") {
+		t.Fatalf("Expected ALL_FRAMES_FAILED, got %v", err)
+	}
+
+	frameErrors, ok := err.(*errors.AppError).Cause.(domain.FrameErrors)
+	if !ok || len(frameErrors) != 2 {
+		t.Errorf("Expected 2 aggregated frame errors, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"path/filepath"
+	"time"
+
+	"webpcompressor/pkg/errors"
+)
+
+// gifEstimateSamples 估算等价GIF大小时最多实际编码的帧数
+const gifEstimateSamples = 10
+
+// estimateGIFSize 估算输入转换为GIF动画后的大小
+//
+// 只把均匀抽取的部分帧编码为GIF，再按总帧数外推。GIF每帧都是完整画布，
+// 抽样帧的平均大小可以代表整体
+func (s *WebPService) estimateGIFSize(ctx context.Context, inputPath string) (int64, error) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_gif_estimate")
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		if !errors.IsCode(err, "NO_FRAMES") {
+			return 0, err
+		}
+		// 静态图像只有一帧，直接完整编码
+		images, durations, loopCount, err := s.decodeFullFrames(ctx, inputPath, tempDir)
+		if err != nil {
+			return 0, err
+		}
+		return encodedGIFSize(images, durations, loopCount)
+	}
+
+	if err := s.toolExecutor.ExecuteCommand(ctx, "anim_dump",
+		"-folder", tempDir, "-prefix", "frame_", inputPath); err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeExecution, "DUMP_FRAMES", "anim_dump提取帧失败")
+	}
+
+	indexes := sampleFrameIndexes(len(animInfo.Frames), gifEstimateSamples)
+	images := make([]image.Image, len(indexes))
+	durations := make([]time.Duration, len(indexes))
+	for i, index := range indexes {
+		img, err := readPNG(filepath.Join(tempDir, fmt.Sprintf("frame_%04d.png", index)))
+		if err != nil {
+			return 0, err
+		}
+		images[i] = img
+		durations[i] = animInfo.Frames[index].Duration
+	}
+
+	size, err := encodedGIFSize(images, durations, animInfo.LoopCount)
+	if err != nil {
+		return 0, err
+	}
+	estimated := size * int64(len(animInfo.Frames)) / int64(len(indexes))
+
+	s.logger.Debug("等价GIF大小估算完成", "sampled", len(indexes), "frames", len(animInfo.Frames), "size", estimated)
+	return estimated, nil
+}
+
+// sampleFrameIndexes 从total帧中均匀抽取最多limit帧的序号
+func sampleFrameIndexes(total, limit int) []int {
+	if total <= limit {
+		limit = total
+	}
+	indexes := make([]int, limit)
+	for i := range indexes {
+		indexes[i] = i * total / limit
+	}
+	return indexes
+}
+
+// encodedGIFSize 返回帧编码为GIF动画后的字节数
+func encodedGIFSize(images []image.Image, durations []time.Duration, loopCount int) (int64, error) {
+	var counter byteCounter
+	if err := encodeGIF(&counter, images, durations, loopCount); err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeInternal, "ENCODE_GIF", "GIF编码失败")
+	}
+	return int64(counter), nil
+}
+
+// byteCounter 只统计写入字节数的io.Writer
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
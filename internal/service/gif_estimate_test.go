@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestSampleFrameIndexes(t *testing.T) {
+	tests := []struct {
+		total, limit int
+		expected     []int
+	}{
+		{3, 10, []int{0, 1, 2}},
+		{10, 5, []int{0, 2, 4, 6, 8}},
+		{7, 3, []int{0, 2, 4}},
+	}
+	for _, tt := range tests {
+		if got := sampleFrameIndexes(tt.total, tt.limit); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("sampleFrameIndexes(%d, %d) = %v, want %v", tt.total, tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestCompressAnimation_GIFEstimate(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := filepath.Join(os.TempDir(), "webp_gif_estimate_test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	images, _ := createTestFrames()
+	for i := 0; i < 2; i++ {
+		writeTestPNG(t, filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i)), images[i])
+	}
+
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", img2webpTestInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.GIFEstimate = true
+
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	// 帧数不超过抽样上限时估算值就是完整编码的大小
+	expected, err := encodedGIFSize(images[:2], []time.Duration{50 * time.Millisecond, 50 * time.Millisecond}, 2)
+	if err != nil {
+		t.Fatalf("encodedGIFSize failed: %v", err)
+	}
+	if result.EstimatedGIFSize != expected {
+		t.Errorf("Expected estimated GIF size %d, got %d", expected, result.EstimatedGIFSize)
+	}
+}
+
+func TestCompressAnimation_GIFEstimateFailureIgnored(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", img2webpTestInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.GIFEstimate = true
+
+	// anim_dump没有输出帧，估算失败但压缩照常完成
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.EstimatedGIFSize != 0 {
+		t.Errorf("Expected no estimate, got %d", result.EstimatedGIFSize)
+	}
+}
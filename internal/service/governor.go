@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"webpcompressor/internal/config"
+	"webpcompressor/internal/domain"
+)
+
+// governorSampleInterval 资源使用的采样间隔，也是超限时等待重新检查的间隔
+const governorSampleInterval = 200 * time.Millisecond
+
+// resourceUsage 一次采样得到的进程内存和累计CPU时间
+type resourceUsage struct {
+	memory  uint64        // 字节，不含工具子进程
+	cpuTime time.Duration // 本进程及已结束子进程的累计CPU时间，Windows下只有本进程
+}
+
+// sampleResourceUsage 采样当前进程的资源使用，内存取Go运行时向系统申请的内存
+//
+// 工具子进程的内存无法从这里取得，正在运行的子进程的CPU时间要到其结束后才计入
+func sampleResourceUsage() resourceUsage {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return resourceUsage{memory: stats.Sys, cpuTime: processCPUTime()}
+}
+
+// resourceGovernor 按PerformanceConfig的内存和CPU阈值限制帧任务的派发
+//
+// 超过任一阈值时新的帧任务等待，直到使用量回落；没有正在处理的帧时总是放行，保证任务能够推进。
+// 这只是派发节流，不是强制上限：内存只统计本进程，cwebp等工具子进程占用的内存不在其中；
+// CPU时间在类Unix系统上包含已结束的子进程，Windows下只统计本进程，已派发的帧也不会被中止
+type resourceGovernor struct {
+	maxMemory uint64  // 字节，0表示不限制
+	cpuLimit  float64 // 占全部核心的百分比，0表示不限制
+	cores     int
+	interval  time.Duration
+	sample    func() resourceUsage
+
+	mu         sync.Mutex
+	active     int
+	last       resourceUsage
+	sampledAt  time.Time
+	memory     uint64
+	cpuPercent float64
+}
+
+// newResourceGovernor 按性能配置创建资源调控器，未启用任何限制时返回nil
+func newResourceGovernor(cfg config.PerformanceConfig) *resourceGovernor {
+	governor := &resourceGovernor{
+		cores:    runtime.NumCPU(),
+		interval: governorSampleInterval,
+		sample:   sampleResourceUsage,
+	}
+	if cfg.EnableMemoryLimit && cfg.MaxMemoryUsage > 0 {
+		governor.maxMemory = uint64(cfg.MaxMemoryUsage) * 1024 * 1024
+	}
+	if cfg.EnableCPUThrottling && cfg.CPUUsageLimit > 0 && cfg.CPUUsageLimit < 100 {
+		governor.cpuLimit = float64(cfg.CPUUsageLimit)
+	}
+	if governor.maxMemory == 0 && governor.cpuLimit == 0 {
+		return nil
+	}
+	return governor
+}
+
+// acquire 等待资源使用回落到阈值以下后占用一个处理名额，返回释放函数
+//
+// 调控器为nil时直接放行；上下文取消时返回其错误
+func (g *resourceGovernor) acquire(ctx context.Context) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+
+	for {
+		if g.tryAcquire() {
+			return g.release, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(g.interval):
+		}
+	}
+}
+
+// tryAcquire 未超限或没有正在处理的帧时占用名额
+func (g *resourceGovernor) tryAcquire() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.refresh()
+	if g.active > 0 && g.overLimit() {
+		return false
+	}
+	g.active++
+	return true
+}
+
+// release 释放一个处理名额
+func (g *resourceGovernor) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+}
+
+// refresh 距上次采样超过采样间隔时重新采样，CPU使用率按两次采样间的CPU时间计算
+func (g *resourceGovernor) refresh() {
+	now := time.Now()
+	if !g.sampledAt.IsZero() && now.Sub(g.sampledAt) < g.interval {
+		return
+	}
+
+	usage := g.sample()
+	if !g.sampledAt.IsZero() {
+		wall := now.Sub(g.sampledAt)
+		g.cpuPercent = float64(usage.cpuTime-g.last.cpuTime) / float64(wall*time.Duration(g.cores)) * 100
+	}
+	g.memory = usage.memory
+	g.last = usage
+	g.sampledAt = now
+}
+
+// overLimit 最近一次采样是否超过任一阈值
+func (g *resourceGovernor) overLimit() bool {
+	if g.maxMemory > 0 && g.memory > g.maxMemory {
+		return true
+	}
+	return g.cpuLimit > 0 && g.cpuPercent > g.cpuLimit
+}
+
+// governed 包装帧处理函数，处理前向资源调控器申请名额
+func (s *WebPService) governed(processor domain.FrameProcessor) domain.FrameProcessor {
+	return func(ctx context.Context, frame *domain.FrameInfo) error {
+		release, err := s.governor.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return processor(ctx, frame)
+	}
+}
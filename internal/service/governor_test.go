@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/config"
+)
+
+func TestNewResourceGovernor_DisabledReturnsNil(t *testing.T) {
+	cfg := config.PerformanceConfig{EnableMemoryLimit: false, EnableCPUThrottling: true, CPUUsageLimit: 100}
+	if governor := newResourceGovernor(cfg); governor != nil {
+		t.Errorf("Expected nil governor, got %+v", governor)
+	}
+
+	// nil调控器直接放行
+	var governor *resourceGovernor
+	release, err := governor.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	release()
+}
+
+func TestResourceGovernor_WaitsWhileOverMemoryLimit(t *testing.T) {
+	var memory atomic.Uint64
+	memory.Store(2 << 20)
+
+	governor := newResourceGovernor(config.PerformanceConfig{EnableMemoryLimit: true, MaxMemoryUsage: 1})
+	governor.interval = time.Millisecond
+	governor.sample = func() resourceUsage { return resourceUsage{memory: memory.Load()} }
+
+	// 没有正在处理的帧时即使超限也放行
+	release, err := governor.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := governor.acquire(context.Background())
+		if err == nil {
+			second()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second acquire to wait while over the memory limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	memory.Store(512 << 10)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second acquire after memory dropped")
+	}
+	release()
+}
+
+func TestResourceGovernor_CPUPercentAndCancel(t *testing.T) {
+	governor := newResourceGovernor(config.PerformanceConfig{EnableCPUThrottling: true, CPUUsageLimit: 50})
+	governor.cores = 1
+	governor.interval = 5 * time.Millisecond
+	// 单核上CPU时间以墙钟时间的两倍增长，使用率约200%
+	start := time.Now()
+	governor.sample = func() resourceUsage { return resourceUsage{cpuTime: 2 * time.Since(start)} }
+
+	release, err := governor.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	defer release()
+	time.Sleep(governor.interval)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := governor.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if governor.cpuPercent <= 50 {
+		t.Errorf("Expected CPU usage above limit, got %.1f", governor.cpuPercent)
+	}
+}
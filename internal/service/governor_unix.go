@@ -0,0 +1,21 @@
+//go:build !windows
+
+package service
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime 返回本进程及已结束子进程（cwebp等工具）的累计用户态和内核态CPU时间
+func processCPUTime() time.Duration {
+	var total time.Duration
+	for _, who := range []int{syscall.RUSAGE_SELF, syscall.RUSAGE_CHILDREN} {
+		var usage syscall.Rusage
+		if err := syscall.Getrusage(who, &usage); err != nil {
+			continue
+		}
+		total += time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+	}
+	return total
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package service
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime 返回本进程的累计用户态和内核态CPU时间，Windows下无法取得已结束子进程的CPU时间
+func processCPUTime() time.Duration {
+	handle, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	return filetimeDuration(kernel) + filetimeDuration(user)
+}
+
+// filetimeDuration 将以100纳秒为单位的Filetime时长转换为time.Duration
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	ticks := int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)
+	return time.Duration(ticks * 100)
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// maxHistoryRecords 历史记录文件最多保留的记录数，超出时丢弃最早的记录
+const maxHistoryRecords = 1000
+
+// fingerprintClusterDistance 首帧感知哈希的汉明距离不超过此值时视为相似输入
+const fingerprintClusterDistance = 10
+
+// historyRecord 一次成功压缩的输入指纹、使用的设置和效果
+type historyRecord struct {
+	Fingerprint string                 `json:"fingerprint"` // 首帧dHash，16位十六进制
+	Quality     int                    `json:"quality"`
+	Settings    domain.LearnedSettings `json:"settings"`
+	Ratio       float64                `json:"compression_ratio"`
+	RecordedAt  time.Time              `json:"recorded_at"`
+}
+
+// inputHistory 当前输入的指纹和查到的历史最佳设置
+type inputHistory struct {
+	fingerprint uint64
+	suggested   *domain.LearnedSettings
+}
+
+// lookupHistory 计算输入的指纹并查找相似输入在相同质量下的历史最佳设置
+//
+// 指纹或历史记录不可用时只记录警告并返回nil，不影响压缩
+func (s *WebPService) lookupHistory(ctx context.Context, inputPath string, config *domain.CompressionConfig) *inputHistory {
+	fingerprint, err := s.inputFingerprint(ctx, inputPath)
+	if err != nil {
+		s.logger.Warn("计算输入指纹失败，不使用历史记录", "file", inputPath, "error", err)
+		return nil
+	}
+
+	history := &inputHistory{fingerprint: fingerprint}
+	if config.Learn == "" {
+		return history
+	}
+
+	s.historyMu.Lock()
+	records, err := loadHistory(s.config.Processing.HistoryFile)
+	s.historyMu.Unlock()
+	if err != nil {
+		s.logger.Warn("读取历史记录失败", "file", s.config.Processing.HistoryFile, "error", err)
+		return history
+	}
+
+	history.suggested = bestLearnedSettings(records, fingerprint, config.Quality)
+	if history.suggested != nil {
+		s.logger.Info("找到相似输入的历史最佳设置",
+			"fingerprint", fmt.Sprintf("%016x", fingerprint),
+			"pipeline", history.suggested.Pipeline,
+			"method", history.suggested.Method,
+			"mode", config.Learn,
+		)
+	}
+	return history
+}
+
+// recordHistory 把本次压缩使用的设置和效果追加到历史记录文件，失败只记录警告
+func (s *WebPService) recordHistory(history *inputHistory, config *domain.CompressionConfig, result *domain.CompressResult) {
+	settings := domain.LearnedSettings{
+		Pipeline:     result.Pipeline,
+		Preset:       config.Preset,
+		Method:       config.Method,
+		Pass:         config.Pass,
+		Mixed:        config.Mixed,
+		NearLossless: config.NearLossless,
+	}
+	// 截止时间可能降低了实际使用的压缩方法
+	if result.Pipeline == domain.PipelineWebpmux {
+		settings.Method = result.Method
+	}
+
+	record := historyRecord{
+		Fingerprint: fmt.Sprintf("%016x", history.fingerprint),
+		Quality:     config.Quality,
+		Settings:    settings,
+		Ratio:       result.CompressionRatio,
+		RecordedAt:  time.Now(),
+	}
+
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	path := s.config.Processing.HistoryFile
+	records, err := loadHistory(path)
+	if err != nil {
+		s.logger.Warn("读取历史记录失败，重新开始记录", "file", path, "error", err)
+		records = nil
+	}
+
+	records = append(records, record)
+	if len(records) > maxHistoryRecords {
+		records = records[len(records)-maxHistoryRecords:]
+	}
+	if err := saveHistory(path, records); err != nil {
+		s.logger.Warn("写入历史记录失败", "file", path, "error", err)
+	}
+}
+
+// inputFingerprint 解码输入的第一帧并计算感知哈希
+func (s *WebPService) inputFingerprint(ctx context.Context, inputPath string) (uint64, error) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_fingerprint")
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	frame := &domain.FrameInfo{Index: 1}
+	if err := s.dumpRawFrames(ctx, inputPath, tempDir, []*domain.FrameInfo{frame}, "png"); err != nil {
+		return 0, err
+	}
+
+	img, err := readPNG(frame.Path)
+	if err != nil {
+		return 0, err
+	}
+	return perceptualHash(img), nil
+}
+
+// perceptualHash 计算图像的dHash：缩小为9x8灰度图，逐行比较相邻像素的亮度
+//
+// 画面相近的图像哈希的汉明距离也小，不受尺寸和轻微压缩失真影响
+func perceptualHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	var gray [8][9]uint32
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			px := bounds.Min.X + (2*x+1)*bounds.Dx()/18
+			py := bounds.Min.Y + (2*y+1)*bounds.Dy()/16
+			r, g, b, _ := img.At(px, py).RGBA()
+			gray[y][x] = (299*r + 587*g + 114*b) / 1000
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// bestLearnedSettings 在相同质量、指纹相似的记录中返回压缩率最低（输出最小）的设置，没有时返回nil
+func bestLearnedSettings(records []historyRecord, fingerprint uint64, quality int) *domain.LearnedSettings {
+	var best *historyRecord
+	for i := range records {
+		record := &records[i]
+		if record.Quality != quality {
+			continue
+		}
+		hash, err := strconv.ParseUint(record.Fingerprint, 16, 64)
+		if err != nil || bits.OnesCount64(hash^fingerprint) > fingerprintClusterDistance {
+			continue
+		}
+		if best == nil || record.Ratio < best.Ratio {
+			best = record
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	settings := best.Settings
+	return &settings
+}
+
+// applyLearnedSettings 返回使用历史最佳设置的配置副本，不修改调用方的配置
+func applyLearnedSettings(config *domain.CompressionConfig, settings *domain.LearnedSettings) *domain.CompressionConfig {
+	tuned := *config
+	tuned.Pipeline = settings.Pipeline
+	tuned.Best = false
+	tuned.Preset = settings.Preset
+	tuned.Method = settings.Method
+	tuned.Pass = settings.Pass
+	tuned.Mixed = settings.Mixed
+	tuned.NearLossless = settings.NearLossless
+	return &tuned
+}
+
+// loadHistory 读取历史记录文件，文件不存在时返回空记录
+func loadHistory(path string) ([]historyRecord, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "READ_HISTORY", "读取历史记录失败")
+	}
+
+	var records []historyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_HISTORY", "解析历史记录失败")
+	}
+	return records, nil
+}
+
+// saveHistory 先写入临时文件再重命名，避免中断时留下不完整的历史记录
+func saveHistory(path string, records []historyRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeInternal, "ENCODE_HISTORY", "序列化历史记录失败")
+	}
+
+	if dir := filepath.Dir(path); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeIO, "WRITE_HISTORY", "创建历史记录目录失败")
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "WRITE_HISTORY", "写入历史记录失败")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "WRITE_HISTORY", "替换历史记录文件失败")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// gradientImage 生成水平渐变图像，invert为true时方向相反
+func gradientImage(size int, invert bool, offset uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := uint8(x*255/size) + offset
+			if invert {
+				v = 255 - v
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash_SimilarImages(t *testing.T) {
+	base := perceptualHash(gradientImage(64, false, 0))
+	similar := perceptualHash(gradientImage(128, false, 0))
+	different := perceptualHash(gradientImage(64, true, 0))
+
+	if d := bits.OnesCount64(base ^ similar); d > fingerprintClusterDistance {
+		t.Errorf("Expected similar images within cluster distance, got %d", d)
+	}
+	if d := bits.OnesCount64(base ^ different); d <= fingerprintClusterDistance {
+		t.Errorf("Expected different images outside cluster distance, got %d", d)
+	}
+}
+
+func TestBestLearnedSettings(t *testing.T) {
+	fingerprint := uint64(0xf0f0f0f0f0f0f0f0)
+	records := []historyRecord{
+		{Fingerprint: fmt.Sprintf("%016x", fingerprint), Quality: 50, Ratio: 60, Settings: domain.LearnedSettings{Method: 6}},
+		{Fingerprint: fmt.Sprintf("%016x", fingerprint^0x7), Quality: 50, Ratio: 40, Settings: domain.LearnedSettings{Method: 4}},
+		{Fingerprint: fmt.Sprintf("%016x", fingerprint), Quality: 80, Ratio: 10, Settings: domain.LearnedSettings{Method: 1}},
+		{Fingerprint: fmt.Sprintf("%016x", ^fingerprint), Quality: 50, Ratio: 5, Settings: domain.LearnedSettings{Method: 2}},
+	}
+
+	settings := bestLearnedSettings(records, fingerprint, 50)
+	if settings == nil || settings.Method != 4 {
+		t.Errorf("Expected method 4 from the smallest similar record, got %+v", settings)
+	}
+	if settings := bestLearnedSettings(records, fingerprint, 30); settings != nil {
+		t.Errorf("Expected no settings for unseen quality, got %+v", settings)
+	}
+}
+
+func TestCompressAnimation_LearnApply(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := filepath.Join(os.TempDir(), "webp_fingerprint_test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	img := gradientImage(32, false, 0)
+	writeTestPNG(t, filepath.Join(dir, "frame_1.png"), img)
+
+	historyFile := filepath.Join(t.TempDir(), "history.json")
+	seed := []historyRecord{{
+		Fingerprint: fmt.Sprintf("%016x", perceptualHash(img)),
+		Quality:     50,
+		Ratio:       30,
+		Settings:    domain.LearnedSettings{Pipeline: domain.PipelineWebpmux, Preset: "drawing", Method: 3, Pass: 2},
+	}}
+	if err := saveHistory(historyFile, seed); err != nil {
+		t.Fatalf("saveHistory failed: %v", err)
+	}
+
+	service := createTestWebPService()
+	service.config.Processing.HistoryFile = historyFile
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Learn = domain.LearnApply
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Suggested == nil || result.Suggested.Method != 3 {
+		t.Fatalf("Expected learned settings in result, got %+v", result.Suggested)
+	}
+
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") && (!strings.Contains(cmd, "-m 3 ") || !strings.Contains(cmd, "-preset drawing")) {
+			t.Errorf("Expected learned settings in cwebp args, got %s", cmd)
+		}
+	}
+	if config.Method != 6 {
+		t.Error("Learned settings should not modify the caller's config")
+	}
+
+	records, err := loadHistory(historyFile)
+	if err != nil {
+		t.Fatalf("loadHistory failed: %v", err)
+	}
+	if len(records) != 2 || records[1].Settings.Method != 3 {
+		t.Errorf("Expected this run to be recorded, got %+v", records)
+	}
+}
+
+func TestValidateInput_LearnRequiresHistoryFile(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Learn = domain.LearnSuggest
+	if err := service.validateInput("test.webp", "out.webp", config); !errors.IsCode(err, "INVALID_LEARN_MODE") {
+		t.Errorf("Expected INVALID_LEARN_MODE, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// compressWithImg2webp 用anim_dump解码完整画布帧，再由img2webp整体重新编码
+//
+// 完整画布帧不依赖前一帧，img2webp可以自行选择子帧区域和混合方式，
+// 对局部更新较多的动画往往比逐帧压缩后重新组装更小
+func (s *WebPService) compressWithImg2webp(ctx context.Context, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_img2webp")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := s.selectFrames(ctx, inputPath, tempDir, animInfo, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// anim_dump和img2webp各自一次处理所有帧，运行期间报告心跳
+	extractProgress := newProgressReporter(config.Progress, domain.StageExtract, len(selection.frames))
+	if !selection.fullCanvas {
+		stopHeartbeat := extractProgress.heartbeat("")
+		err := s.extractFullCanvasFrames(ctx, inputPath, tempDir, animInfo, selection.frames)
+		stopHeartbeat()
+		if err != nil {
+			return nil, err
+		}
+	}
+	extractProgress.finish()
+
+	// img2webp一次完成编码和组装
+	assembleProgress := newProgressReporter(config.Progress, domain.StageAssemble, len(selection.frames))
+	encodeCtx, stopWatch := watchOutputSize(ctx, outputPath, s.outputSizeLimit(inputPath))
+	stopHeartbeat := assembleProgress.heartbeat(outputPath)
+	err = s.composeWithImg2webp(encodeCtx, selection.frames, outputPath, animInfo.LoopCount, config)
+	stopHeartbeat()
+	if sizeErr := stopWatch(); sizeErr != nil {
+		return nil, sizeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	assembleProgress.finish()
+
+	expected := &domain.AnimationInfo{Width: animInfo.Width, Height: animInfo.Height, Frames: selection.frames}
+	if err := s.checkOutput(ctx, outputPath, expected); err != nil {
+		return nil, err
+	}
+
+	return &domain.CompressResult{
+		FramesProcessed: len(selection.frames),
+		FramesDropped:   selection.dropped,
+		FramesMerged:    selection.merged,
+		FramesTrimmed:   selection.trimmed,
+		ParallelWorkers: 1,
+		Pipeline:        domain.PipelineImg2webp,
+	}, nil
+}
+
+// compressBest 依次尝试两种处理管线，保留输出较小的结果
+//
+// 某一管线失败时记录警告并使用另一管线的结果，都失败时返回第一个错误
+func (s *WebPService) compressBest(ctx context.Context, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_best")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	var (
+		best     *domain.CompressResult
+		bestPath string
+		bestSize int64
+		firstErr error
+	)
+
+	for _, pipeline := range []string{domain.PipelineWebpmux, domain.PipelineImg2webp} {
+		candidatePath := filepath.Join(tempDir, "candidate_"+pipeline+".webp")
+
+		pipelineConfig := *config
+		if pipeline == domain.PipelineImg2webp {
+			// img2webp整体编码，逐帧的自动质量和画质报告不适用
+			pipelineConfig.AutoQuality = false
+			pipelineConfig.QualityReport = false
+		}
+
+		result, err := s.compressWithPipeline(ctx, pipeline, inputPath, candidatePath, &pipelineConfig)
+		if err != nil {
+			s.logger.Warn("处理管线失败", "pipeline", pipeline, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		size, err := s.fileManager.GetFileSize(candidatePath)
+		if err != nil {
+			s.logger.Warn("获取候选结果大小失败", "pipeline", pipeline, "error", err)
+			continue
+		}
+
+		s.logger.Info("处理管线完成", "pipeline", pipeline, "size", formatFileSize(size))
+		if best == nil || size < bestSize {
+			best, bestPath, bestSize = result, candidatePath, size
+		}
+	}
+
+	if best == nil {
+		if firstErr == nil {
+			firstErr = errors.New(errors.ErrorTypeExecution, "NO_PIPELINE_RESULT", "所有处理管线均未生成结果")
+		}
+		return nil, firstErr
+	}
+
+	if err := s.fileManager.CopyFile(bestPath, outputPath); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "COPY_OUTPUT", "写出最佳结果失败")
+	}
+
+	s.logger.Info("已选择较小的处理管线结果", "pipeline", best.Pipeline, "size", formatFileSize(bestSize))
+	return best, nil
+}
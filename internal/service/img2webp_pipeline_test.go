@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+const img2webpTestInfo = `Canvas size: 4 x 4
+Loop Count : 2
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:      4      4   yes         0        0       50    none    no        172      lossy
+  2:      2      2   yes         2        2       50    none   yes        118      lossy`
+
+// prepareDumpedFrames 在模拟临时目录中放好anim_dump的输出
+func prepareDumpedFrames(t *testing.T, prefix string, count int) string {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	dir := filepath.Join(os.TempDir(), prefix+"_test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		writeTestPNG(t, filepath.Join(dir, fmt.Sprintf("dump_%04d.png", i)), image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	}
+	return dir
+}
+
+func TestCompressAnimation_Img2webpPipeline(t *testing.T) {
+	dir := prepareDumpedFrames(t, "webp_img2webp", 2)
+
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", img2webpTestInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Pipeline = domain.PipelineImg2webp
+
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Pipeline != domain.PipelineImg2webp || result.FramesProcessed != 2 {
+		t.Errorf("Unexpected result: pipeline=%s frames=%d", result.Pipeline, result.FramesProcessed)
+	}
+
+	expected := "img2webp -loop 2 -lossy -q 50 -m 6 -d 50 " + filepath.Join(dir, "frame_1.png") +
+		" -d 50 " + filepath.Join(dir, "frame_2.png") + " -o out.webp"
+	found := false
+	for _, cmd := range mockToolExecutor.commands {
+		if cmd == expected {
+			found = true
+		}
+		if strings.HasPrefix(cmd, "webpmux -get frame") {
+			t.Errorf("img2webp pipeline should not extract raw frames: %s", cmd)
+		}
+	}
+	if !found {
+		t.Errorf("Expected %q, got %v", expected, mockToolExecutor.commands)
+	}
+}
+
+func TestCompressAnimation_BestPicksSmaller(t *testing.T) {
+	prepareDumpedFrames(t, "webp_img2webp", 2)
+
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockFileManager := service.fileManager.(*MockFileManager)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", img2webpTestInfo)
+
+	bestDir := filepath.Join(os.TempDir(), "webp_best_test")
+	mockFileManager.SetFileSize(filepath.Join(bestDir, "candidate_webpmux.webp"), 3000)
+	mockFileManager.SetFileSize(filepath.Join(bestDir, "candidate_img2webp.webp"), 2000)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Best = true
+
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Pipeline != domain.PipelineImg2webp {
+		t.Errorf("Expected img2webp to win, got %s", result.Pipeline)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// maxLoopCount ANIM块中循环次数为16位
+const maxLoopCount = 1<<16 - 1
+
+// CheckIntegrity 检查动画的完整性，返回逐项问题
+//
+// 依次用webpinfo校验码流、检查RIFF块、解析帧信息并核对时长、偏移和循环次数，
+// 最后用anim_dump解码每一帧；只有输入不存在时返回错误，其余问题都写入报告
+func (s *WebPService) CheckIntegrity(ctx context.Context, inputPath string) (*domain.IntegrityReport, error) {
+	opLogger := logger.NewOperationLogger(s.logger, "动画完整性检查").
+		WithContext("file", inputPath)
+
+	opLogger.Start()
+
+	if !s.fileManager.FileExists(inputPath) {
+		err := errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", inputPath)
+		opLogger.Error(err)
+		return nil, err
+	}
+
+	report := &domain.IntegrityReport{File: inputPath}
+	addIssue := func(severity, code string, frame int, message string) {
+		report.Issues = append(report.Issues, domain.IntegrityIssue{
+			Severity: severity, Code: code, Frame: frame, Message: message,
+		})
+	}
+
+	if _, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "webpinfo", "-quiet", inputPath); err != nil {
+		addIssue(domain.SeverityError, "INVALID_BITSTREAM", 0, fmt.Sprintf("webpinfo校验失败: %v", err))
+	}
+
+	features, err := scanUnsupportedFeatures(inputPath)
+	if err != nil {
+		addIssue(domain.SeverityError, "INVALID_CONTAINER", 0, err.Error())
+	}
+	for _, feature := range features {
+		addIssue(domain.SeverityWarning, "UNSUPPORTED_CHUNK", 0, fmt.Sprintf("含有无法重新组装的块: %s", feature))
+	}
+
+	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		addIssue(domain.SeverityError, "PARSE_FAILED", 0, fmt.Sprintf("解析动画信息失败: %v", err))
+	} else {
+		report.Width, report.Height = animInfo.Width, animInfo.Height
+		report.Frames, report.LoopCount = len(animInfo.Frames), animInfo.LoopCount
+		checkAnimationLayout(animInfo, addIssue)
+		s.checkFrameDecoding(ctx, inputPath, animInfo, addIssue)
+	}
+
+	report.Healthy = true
+	for _, issue := range report.Issues {
+		if issue.Severity == domain.SeverityError {
+			report.Healthy = false
+			break
+		}
+	}
+
+	opLogger.Success()
+	return report, nil
+}
+
+// checkAnimationLayout 核对循环次数以及每帧的时长和在画布中的位置
+func checkAnimationLayout(animInfo *domain.AnimationInfo, addIssue func(severity, code string, frame int, message string)) {
+	if animInfo.LoopCount < 0 || animInfo.LoopCount > maxLoopCount {
+		addIssue(domain.SeverityError, "INVALID_LOOP_COUNT", 0,
+			fmt.Sprintf("循环次数超出范围(0-%d): %d", maxLoopCount, animInfo.LoopCount))
+	}
+	if animInfo.FrameCount > 0 && animInfo.FrameCount != len(animInfo.Frames) {
+		addIssue(domain.SeverityError, "FRAME_COUNT_MISMATCH", 0,
+			fmt.Sprintf("声明的帧数%d与实际帧数%d不一致", animInfo.FrameCount, len(animInfo.Frames)))
+	}
+
+	for _, frame := range animInfo.Frames {
+		duration := frame.Duration.Round(time.Millisecond).Milliseconds()
+		switch {
+		case duration < 0 || duration > maxFrameDuration:
+			addIssue(domain.SeverityError, "INVALID_DURATION", frame.Index,
+				fmt.Sprintf("帧时长超出范围(0-%d毫秒): %d", maxFrameDuration, duration))
+		case duration == 0:
+			// 浏览器通常把0时长按约100毫秒播放，与其他播放器表现不同
+			addIssue(domain.SeverityWarning, "ZERO_DURATION", frame.Index, "帧时长为0，不同播放器的播放速度可能不一致")
+		}
+
+		if frame.X < 0 || frame.Y < 0 || frame.X+frame.Width > animInfo.Width || frame.Y+frame.Height > animInfo.Height {
+			addIssue(domain.SeverityError, "FRAME_OUT_OF_BOUNDS", frame.Index,
+				fmt.Sprintf("帧区域 %dx%d+%d+%d 超出画布 %dx%d",
+					frame.Width, frame.Height, frame.X, frame.Y, animInfo.Width, animInfo.Height))
+		}
+	}
+}
+
+// checkFrameDecoding 用anim_dump解码每一帧，核对解码出的帧数和画布尺寸
+func (s *WebPService) checkFrameDecoding(ctx context.Context, inputPath string, animInfo *domain.AnimationInfo, addIssue func(severity, code string, frame int, message string)) {
+	tempDir, err := s.fileManager.CreateTempDir("webp_integrity")
+	if err != nil {
+		addIssue(domain.SeverityWarning, "DECODE_SKIPPED", 0, fmt.Sprintf("创建临时目录失败，未解码帧: %v", err))
+		return
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	if err := s.toolExecutor.ExecuteCommand(ctx, "anim_dump", "-folder", tempDir, "-prefix", "frame_", inputPath); err != nil {
+		addIssue(domain.SeverityError, "DECODE_FAILED", 0, fmt.Sprintf("anim_dump解码失败: %v", err))
+		return
+	}
+
+	for i, frame := range animInfo.Frames {
+		img, err := readPNG(filepath.Join(tempDir, fmt.Sprintf("frame_%04d.png", i)))
+		if err != nil {
+			addIssue(domain.SeverityError, "DECODE_FAILED", frame.Index, fmt.Sprintf("帧解码失败: %v", err))
+			continue
+		}
+		if bounds := img.Bounds(); bounds.Dx() != animInfo.Width || bounds.Dy() != animInfo.Height {
+			addIssue(domain.SeverityError, "DECODE_SIZE_MISMATCH", frame.Index,
+				fmt.Sprintf("解码尺寸 %dx%d 与画布 %dx%d 不一致", bounds.Dx(), bounds.Dy(), animInfo.Width, animInfo.Height))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+// prepareIntegrityFrames 写出anim_dump在完整性检查临时目录中应生成的完整画布帧
+func prepareIntegrityFrames(t *testing.T, size int, count int) {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	dir := filepath.Join(os.TempDir(), "webp_integrity_test")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		writeTestPNG(t, filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i)), image.NewNRGBA(image.Rect(0, 0, size, size)))
+	}
+}
+
+func TestCheckIntegrity_Healthy(t *testing.T) {
+	input := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)))
+	prepareIntegrityFrames(t, 288, 2)
+
+	service := createTestWebPService()
+	service.toolExecutor.(*MockToolExecutor).SetMockOutput("webpmux -info "+input, verifyTestOutputInfo)
+
+	report, err := service.CheckIntegrity(context.Background(), input)
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if !report.Healthy || len(report.Issues) != 0 || report.Frames != 2 || report.Width != 288 {
+		t.Errorf("Expected healthy 2-frame report, got %+v", report)
+	}
+}
+
+func TestCheckIntegrity_ReportsIssues(t *testing.T) {
+	input := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)))
+	// 只有第一帧能解码
+	prepareIntegrityFrames(t, 288, 1)
+
+	service := createTestWebPService()
+	service.toolExecutor.(*MockToolExecutor).SetMockOutput("webpmux -info "+input, `Canvas size: 288 x 288
+Loop Count : 0
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:    288    288   yes         0        0        0    none    no        172      lossy
+  2:    100    100   yes       200      200       70    none    no        518      lossy`)
+
+	report, err := service.CheckIntegrity(context.Background(), input)
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if report.Healthy {
+		t.Error("Expected unhealthy report")
+	}
+
+	expected := []domain.IntegrityIssue{
+		{Severity: domain.SeverityWarning, Code: "ZERO_DURATION", Frame: 1},
+		{Severity: domain.SeverityError, Code: "FRAME_OUT_OF_BOUNDS", Frame: 2},
+		{Severity: domain.SeverityError, Code: "DECODE_FAILED", Frame: 2},
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %+v", len(expected), report.Issues)
+	}
+	for i, want := range expected {
+		got := report.Issues[i]
+		if got.Severity != want.Severity || got.Code != want.Code || got.Frame != want.Frame {
+			t.Errorf("Issue %d: expected %s/%s frame %d, got %+v", i, want.Severity, want.Code, want.Frame, got)
+		}
+	}
+}
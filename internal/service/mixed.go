@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// compressFrameMixed 混合模式：分别按有损和无损（或近无损）编码单帧，保留较小的结果
+//
+// 与img2webp -mixed类似，有损候选沿用固定质量或自动质量，无损候选沿用NearLossless设置
+func (s *WebPService) compressFrameMixed(ctx context.Context, frame *domain.FrameInfo, compressedPath string, config *domain.CompressionConfig) error {
+	lossyConfig := *config
+	lossyConfig.NearLossless = 0
+	if err := s.compressFrameLossy(ctx, frame, compressedPath, &lossyConfig); err != nil {
+		return err
+	}
+
+	losslessPath := strings.TrimSuffix(compressedPath, ".webp") + "_lossless.webp"
+	losslessConfig := *config
+	losslessConfig.Lossless = true
+	if err := s.encodeFrame(ctx, frame, losslessPath, &losslessConfig); err != nil {
+		return err
+	}
+
+	lossySize, err := s.fileManager.GetFileSize(compressedPath)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE",
+			fmt.Sprintf("获取第%d帧有损编码大小失败", frame.Index))
+	}
+	losslessSize, err := s.fileManager.GetFileSize(losslessPath)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE",
+			fmt.Sprintf("获取第%d帧无损编码大小失败", frame.Index))
+	}
+
+	if losslessSize < lossySize {
+		if err := os.Rename(losslessPath, compressedPath); err != nil {
+			return errors.Wrapf(err, errors.ErrorTypeIO, "RENAME_FRAME", "替换第%d帧编码结果失败", frame.Index)
+		}
+	} else if err := os.Remove(losslessPath); err != nil {
+		s.logger.Warn("删除无损候选帧失败", "file", losslessPath, "error", err)
+	}
+
+	s.logger.Debug("混合模式选择完成",
+		"index", frame.Index,
+		"lossy_size", lossySize,
+		"lossless_size", losslessSize,
+		"lossless", losslessSize < lossySize,
+	)
+	return nil
+}
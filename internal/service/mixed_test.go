@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestCompressFrameMixed_KeepsSmallerLossless(t *testing.T) {
+	service := createTestWebPService()
+	mockFileManager := service.fileManager.(*MockFileManager)
+
+	dir := t.TempDir()
+	compressedPath := filepath.Join(dir, "frame_compressed_1.webp")
+	losslessPath := filepath.Join(dir, "frame_compressed_1_lossless.webp")
+	// 模拟cwebp的输出文件
+	for _, path := range []string{compressedPath, losslessPath} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	mockFileManager.SetFileSize(compressedPath, 2000)
+	mockFileManager.SetFileSize(losslessPath, 1500)
+
+	config := domain.DefaultCompressionConfig(60)
+	config.Mixed = true
+	config.NearLossless = 60
+
+	frame := &domain.FrameInfo{Index: 1, Path: filepath.Join(dir, "frame_1.webp")}
+	if err := service.compressFrameMixed(context.Background(), frame, compressedPath, config); err != nil {
+		t.Fatalf("compressFrameMixed failed: %v", err)
+	}
+
+	data, err := os.ReadFile(compressedPath)
+	if err != nil || string(data) != "frame_compressed_1_lossless.webp" {
+		t.Errorf("Expected lossless candidate to replace lossy output, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(losslessPath); !os.IsNotExist(err) {
+		t.Errorf("Expected lossless candidate to be moved, stat err: %v", err)
+	}
+}
+
+func TestCwebpArgs_NearLossless(t *testing.T) {
+	config := domain.DefaultCompressionConfig(60)
+	config.NearLossless = 40
+
+	args, err := newCwebpArgs(config, "in.webp", "out.webp").Render()
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got := strings.Join(args[:3], " "); got != "-lossless -near_lossless 40" {
+		t.Errorf("Unexpected leading args: %s", got)
+	}
+}
+
+func TestBuildImg2webpArgs_Mixed(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(70)
+	config.Mixed = true
+
+	frames := []*domain.FrameInfo{{Index: 1, Path: "a.png", Duration: 100 * time.Millisecond}}
+	args := service.buildImg2webpArgs(frames, "out.webp", 0, config)
+
+	if got := strings.Join(args, " "); got != "-loop 0 -mixed -q 70 -m 6 -d 100 a.png -o out.webp" {
+		t.Errorf("Unexpected args: %s", got)
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// 本文件只实现统计导出需要的Parquet子集：一个行组，每列一个PLAIN编码、不压缩的v1数据页，
+// 元数据用Thrift紧凑协议编码。格式见 https://github.com/apache/parquet-format
+
+// parquetMagic Parquet文件首尾的魔数
+const parquetMagic = "PAR1"
+
+// Parquet列的值类型，值分别为string、int64、float64、bool和time.Time的毫秒时间戳(int64)
+const (
+	parquetString = iota
+	parquetInt64
+	parquetDouble
+	parquetBoolean
+	parquetTimestamp
+)
+
+// Parquet格式中的枚举值
+const (
+	parquetTypeBoolean   = 0
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetRepetitionRequired = 0
+	parquetRepetitionOptional = 1
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetPageData          = 0
+)
+
+// parquetColumn Parquet文件的一列
+type parquetColumn struct {
+	name     string
+	kind     int
+	optional bool // 可选列的值可以为nil
+}
+
+// physicalType 返回列的物理类型和转换类型，没有转换类型时第二个值为-1
+func (c parquetColumn) physicalType() (int32, int32) {
+	switch c.kind {
+	case parquetInt64:
+		return parquetTypeInt64, -1
+	case parquetDouble:
+		return parquetTypeDouble, -1
+	case parquetBoolean:
+		return parquetTypeBoolean, -1
+	case parquetTimestamp:
+		return parquetTypeInt64, parquetConvertedTimestampMillis
+	default:
+		return parquetTypeByteArray, parquetConvertedUTF8
+	}
+}
+
+// writeParquet 把按行给出的值写为Parquet文件，每行的值与columns一一对应
+func writeParquet(w io.Writer, columns []parquetColumn, rows [][]any) error {
+	out := []byte(parquetMagic)
+	chunks := make([]parquetChunk, len(columns))
+	if len(rows) > 0 {
+		for i, column := range columns {
+			page, err := encodeParquetPage(column, i, rows)
+			if err != nil {
+				return err
+			}
+			header := encodeParquetPageHeader(len(rows), len(page))
+			chunks[i] = parquetChunk{offset: int64(len(out)), size: int64(len(header) + len(page))}
+			out = append(out, header...)
+			out = append(out, page...)
+		}
+	}
+
+	footer := encodeParquetFooter(columns, chunks, len(rows))
+	out = append(out, footer...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(footer)))
+	out = append(out, parquetMagic...)
+	_, err := w.Write(out)
+	return err
+}
+
+// parquetChunk 一列数据在文件中的位置
+type parquetChunk struct {
+	offset int64
+	size   int64 // 页头和页数据的总字节数
+}
+
+// encodeParquetPage 编码第index列的数据页：可选列先写定义级别，再按PLAIN编码写非空值
+func encodeParquetPage(column parquetColumn, index int, rows [][]any) ([]byte, error) {
+	var page []byte
+	if column.optional {
+		levels := make([]bool, len(rows))
+		for i, row := range rows {
+			levels[i] = row[index] != nil
+		}
+		encoded := encodeParquetLevels(levels)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(encoded)))
+		page = append(page, encoded...)
+	}
+
+	var bits, nbits int
+	for i, row := range rows {
+		value := row[index]
+		if value == nil {
+			if !column.optional {
+				return nil, fmt.Errorf("第%d行的%s列不能为空", i+1, column.name)
+			}
+			continue
+		}
+
+		ok := true
+		switch column.kind {
+		case parquetInt64, parquetTimestamp:
+			var v int64
+			if v, ok = value.(int64); ok {
+				page = binary.LittleEndian.AppendUint64(page, uint64(v))
+			}
+		case parquetDouble:
+			var v float64
+			if v, ok = value.(float64); ok {
+				page = binary.LittleEndian.AppendUint64(page, math.Float64bits(v))
+			}
+		case parquetBoolean:
+			// 布尔值按位打包，低位在前
+			var v bool
+			if v, ok = value.(bool); ok {
+				if v {
+					bits |= 1 << nbits
+				}
+				if nbits++; nbits == 8 {
+					page = append(page, byte(bits))
+					bits, nbits = 0, 0
+				}
+			}
+		default:
+			var v string
+			if v, ok = value.(string); ok {
+				page = binary.LittleEndian.AppendUint32(page, uint32(len(v)))
+				page = append(page, v...)
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("第%d行的%s列类型不符: %T", i+1, column.name, value)
+		}
+	}
+	if nbits > 0 {
+		page = append(page, byte(bits))
+	}
+	return page, nil
+}
+
+// encodeParquetLevels 用RLE/位打包混合编码写出位宽为1的定义级别，全部使用RLE游程
+func encodeParquetLevels(levels []bool) []byte {
+	var out []byte
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		out = binary.AppendUvarint(out, uint64(end-start)<<1)
+		if levels[start] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		start = end
+	}
+	return out
+}
+
+// encodeParquetPageHeader 编码数据页的页头
+func encodeParquetPageHeader(numValues, pageSize int) []byte {
+	t := newThriftWriter()
+	t.i32(1, parquetPageData)
+	t.i32(2, int32(pageSize))
+	t.i32(3, int32(pageSize))
+	t.beginStruct(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetEncodingPlain)
+	t.i32(3, parquetEncodingRLE)
+	t.i32(4, parquetEncodingRLE)
+	t.end()
+	return t.finish()
+}
+
+// encodeParquetFooter 编码文件元数据，没有行时不写行组
+func encodeParquetFooter(columns []parquetColumn, chunks []parquetChunk, numRows int) []byte {
+	t := newThriftWriter()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(columns)+1)
+	t.beginElement()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.end()
+	for _, column := range columns {
+		typ, converted := column.physicalType()
+		repetition := int32(parquetRepetitionRequired)
+		if column.optional {
+			repetition = parquetRepetitionOptional
+		}
+		t.beginElement()
+		t.i32(1, typ)
+		t.i32(3, repetition)
+		t.binary(4, column.name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		t.end()
+	}
+
+	t.i64(3, int64(numRows))
+
+	if numRows == 0 {
+		t.list(4, thriftStruct, 0)
+	} else {
+		t.list(4, thriftStruct, 1)
+		t.beginElement()
+		t.list(1, thriftStruct, len(columns))
+		var total int64
+		for i, column := range columns {
+			typ, _ := column.physicalType()
+			chunk := chunks[i]
+			total += chunk.size
+
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, typ)
+			t.list(2, thriftI32, 2)
+			t.listI32(parquetEncodingPlain)
+			t.listI32(parquetEncodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.listBinary(column.name)
+			t.i32(4, parquetCodecUncompressed)
+			t.i64(5, int64(numRows))
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, total)
+		t.i64(3, int64(numRows))
+		t.end()
+	}
+
+	t.binary(6, "webpcompressor")
+	return t.finish()
+}
+
+// Thrift紧凑协议的类型
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter Thrift紧凑协议编码器，只包含Parquet元数据用到的类型
+type thriftWriter struct {
+	buf  []byte
+	last []int16 // 每层结构体上一个字段的ID，字段头按与它的差值编码
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	top := len(t.last) - 1
+	if delta := id - t.last[top]; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last[top] = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.listBinary(v)
+}
+
+// list 写出列表字段的头部，随后依次写出size个元素
+func (t *thriftWriter) list(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xf0|elemType)
+		t.buf = binary.AppendUvarint(t.buf, uint64(size))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) listBinary(v string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// beginStruct 开始结构体字段，以end结束
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement 开始列表中的结构体元素，以end结束
+func (t *thriftWriter) beginElement() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// finish 结束最外层结构体并返回编码结果
+func (t *thriftWriter) finish() []byte {
+	t.end()
+	return t.buf
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// thriftReader 测试用的Thrift紧凑协议解码器，结构体解码为以字段ID为键的map
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.varint()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.uvarint())
+		v := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return v
+	case 9:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// readParquet 读取writeParquet写出的文件，返回文件元数据和按列解码的值
+func readParquet(t *testing.T, data []byte) (map[int16]any, [][]any) {
+	t.Helper()
+	if len(data) < 12 || string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatalf("Missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	if footerStart < 4 {
+		t.Fatalf("Invalid footer length %d", footerLen)
+	}
+	footer := (&thriftReader{data: data[footerStart : len(data)-8]}).structure()
+
+	schema := footer[2].([]any)
+	numRows := int(footer[3].(int64))
+	rowGroups := footer[4].([]any)
+	if numRows == 0 {
+		return footer, nil
+	}
+	if len(rowGroups) != 1 {
+		t.Fatalf("Expected 1 row group, got %d", len(rowGroups))
+	}
+
+	var columns [][]any
+	for i, c := range rowGroups[0].(map[int16]any)[1].([]any) {
+		element := schema[i+1].(map[int16]any)
+		meta := c.(map[int16]any)[3].(map[int16]any)
+		reader := &thriftReader{data: data, pos: int(meta[9].(int64))}
+		header := reader.structure()
+		if header[5].(map[int16]any)[1].(int64) != int64(numRows) {
+			t.Fatalf("Column %v: page value count mismatch", element[4])
+		}
+		page := data[reader.pos : reader.pos+int(header[3].(int64))]
+		columns = append(columns, decodeParquetPage(element, page, numRows))
+	}
+	return footer, columns
+}
+
+// decodeParquetPage 解码PLAIN编码的数据页，定义级别只支持RLE游程
+func decodeParquetPage(element map[int16]any, page []byte, numRows int) []any {
+	present := make([]bool, numRows)
+	for i := range present {
+		present[i] = true
+	}
+	if element[3].(int64) == parquetRepetitionOptional {
+		size := int(binary.LittleEndian.Uint32(page))
+		levels := &thriftReader{data: page[4 : 4+size]}
+		for i := 0; levels.pos < size; {
+			run := int(levels.uvarint() >> 1)
+			value := levels.byte() == 1
+			for ; run > 0; run-- {
+				present[i] = value
+				i++
+			}
+		}
+		page = page[4+size:]
+	}
+
+	values := make([]any, numRows)
+	pos, bit := 0, 0
+	for i := range values {
+		if !present[i] {
+			continue
+		}
+		switch element[1].(int64) {
+		case parquetTypeInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(page[pos:]))
+			pos += 8
+		case parquetTypeDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(page[pos:]))
+			pos += 8
+		case parquetTypeBoolean:
+			values[i] = page[bit/8]>>(bit%8)&1 == 1
+			bit++
+		case parquetTypeByteArray:
+			n := int(binary.LittleEndian.Uint32(page[pos:]))
+			values[i] = string(page[pos+4 : pos+4+n])
+			pos += 4 + n
+		}
+	}
+	return values
+}
+
+func TestWriteParquet_RoundTrip(t *testing.T) {
+	columns := []parquetColumn{
+		{name: "time", kind: parquetTimestamp},
+		{name: "name", kind: parquetString},
+		{name: "size", kind: parquetInt64, optional: true},
+		{name: "ratio", kind: parquetDouble, optional: true},
+		{name: "flag", kind: parquetBoolean},
+	}
+	var rows [][]any
+	for i := 0; i < 20; i++ {
+		row := []any{int64(1700000000000 + i), "frame", int64(i * 100), float64(i) / 4, i%3 == 0}
+		if i%4 == 1 {
+			row[2], row[3] = nil, nil
+		}
+		rows = append(rows, row)
+	}
+
+	var out bytes.Buffer
+	if err := writeParquet(&out, columns, rows); err != nil {
+		t.Fatalf("writeParquet failed: %v", err)
+	}
+	footer, values := readParquet(t, out.Bytes())
+
+	if footer[3].(int64) != int64(len(rows)) {
+		t.Errorf("Expected %d rows, got %v", len(rows), footer[3])
+	}
+	schema := footer[2].([]any)
+	if root := schema[0].(map[int16]any); root[5].(int64) != int64(len(columns)) {
+		t.Errorf("Expected %d children in schema root, got %v", len(columns), root[5])
+	}
+	for i, column := range columns {
+		element := schema[i+1].(map[int16]any)
+		if element[4] != column.name {
+			t.Errorf("Expected column %q, got %v", column.name, element[4])
+		}
+		for j, row := range rows {
+			if !reflect.DeepEqual(values[i][j], row[i]) {
+				t.Errorf("Column %s row %d: expected %v, got %v", column.name, j, row[i], values[i][j])
+			}
+		}
+	}
+	if converted := schema[1].(map[int16]any)[6]; converted != int64(parquetConvertedTimestampMillis) {
+		t.Errorf("Expected TIMESTAMP_MILLIS for time column, got %v", converted)
+	}
+}
+
+func TestWriteParquet_NoRows(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeParquet(&out, []parquetColumn{{name: "name", kind: parquetString}}, nil); err != nil {
+		t.Fatalf("writeParquet failed: %v", err)
+	}
+	footer, _ := readParquet(t, out.Bytes())
+	if footer[3].(int64) != 0 || len(footer[4].([]any)) != 0 {
+		t.Errorf("Expected no rows and no row groups, got %v/%v", footer[3], footer[4])
+	}
+}
+
+func TestWriteParquet_RequiredNull(t *testing.T) {
+	rows := [][]any{{nil}}
+	if err := writeParquet(&bytes.Buffer{}, []parquetColumn{{name: "name", kind: parquetString}}, rows); err == nil {
+		t.Error("Expected error for null value in required column")
+	}
+}
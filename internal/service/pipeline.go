@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// errFrameSkipped 阶段处理函数返回此错误时丢弃该帧，不中止流水线
+var errFrameSkipped = errors.New(errors.ErrorTypeInternal, "FRAME_SKIPPED", "帧已丢弃")
+
+// pipelineStage 流水线中的一个处理阶段
+type pipelineStage struct {
+	name    string
+	workers int
+	process domain.FrameProcessor
+}
+
+// runPipeline 以有界通道串联各阶段，每个阶段使用独立的并发数
+//
+// 任一阶段出错后取消其余处理，但各阶段继续消费上游数据直到通道关闭，避免生产者阻塞；返回第一个错误。
+// 返回errFrameSkipped的帧只是不再传给下游阶段
+func runPipeline(parent context.Context, source <-chan *domain.FrameInfo, stages []pipelineStage, queueSize int) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	in := source
+	for _, stage := range stages {
+		out := make(chan *domain.FrameInfo, queueSize)
+		var wg sync.WaitGroup
+
+		for i := 0; i < stage.workers; i++ {
+			wg.Add(1)
+			go func(stage pipelineStage, in <-chan *domain.FrameInfo) {
+				defer wg.Done()
+				for frame := range in {
+					if ctx.Err() != nil {
+						continue
+					}
+					if err := stage.process(ctx, frame); err != nil {
+						if err != errFrameSkipped {
+							fail(err)
+						}
+						continue
+					}
+					out <- frame
+				}
+			}(stage, in)
+		}
+
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+
+		in = out
+	}
+
+	// 排空最后一个阶段的输出
+	for range in {
+	}
+
+	if firstErr == nil && parent.Err() != nil {
+		return parent.Err()
+	}
+	return firstErr
+}
+
+// parseAndProcessFrames 流式解析webpmux -info输出，并经过提取、压缩、组装暂存三个阶段处理每一帧
+//
+// 解析出一帧即进入提取阶段，提取（磁盘密集）与压缩（CPU密集）分别使用独立的并发数；
+// 返回的动画信息中帧路径已指向压缩结果，暂存阶段把每帧记录到组装清单
+func (s *WebPService) parseAndProcessFrames(ctx context.Context, inputPath, tempDir string, config *domain.CompressionConfig, builder *assemblyManifestBuilder) (*domain.AnimationInfo, error) {
+	extractWorkers := s.config.Processing.ExtractWorkers
+	if extractWorkers <= 0 {
+		extractWorkers = 1
+	}
+	compressWorkers := s.compressWorkers(config)
+
+	s.logger.Info("开始分阶段处理帧",
+		"file", inputPath,
+		"extract_workers", extractWorkers,
+		"compress_workers", compressWorkers,
+	)
+
+	queueSize := s.config.Processing.StageQueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	source := make(chan *domain.FrameInfo, queueSize)
+
+	// 帧总数在解析到帧表表头后才知道
+	extractProgress := newProgressReporter(config.Progress, domain.StageExtract, 0)
+	compressProgress := newProgressReporter(config.Progress, domain.StageCompress, 0)
+	stages := s.frameStages(inputPath, tempDir, config, builder, true, extractProgress, compressProgress)
+
+	pipelineErr := make(chan error, 1)
+	go func() {
+		pipelineErr <- runPipeline(ctx, source, stages, queueSize)
+	}()
+
+	var parser *webpmuxInfoParser
+	parser = s.newWebpmuxInfoParser(func(frame *domain.FrameInfo) {
+		if frame.Index == 1 {
+			extractProgress.setTotal(parser.animInfo.FrameCount)
+			compressProgress.setTotal(parser.animInfo.FrameCount)
+			builder.setExpectedFrames(parser.animInfo.FrameCount)
+		}
+		source <- frame
+	})
+	runErr := s.toolExecutor.ExecuteCommandWithLineHandler(ctx, "webpmux", func(line string) {
+		parser.feed(line)
+	}, "-info", inputPath)
+
+	close(source)
+	err := <-pipelineErr
+
+	if runErr != nil {
+		return nil, errors.Wrap(runErr, errors.ErrorTypeExecution, "PARSE_ANIMATION", "执行webpmux失败")
+	}
+
+	animInfo, parseErr := parser.finish()
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("分阶段处理帧完成", "total_frames", len(animInfo.Frames))
+	return animInfo, nil
+}
+
+// processSelectedFrames 让已选定的帧经过提取、压缩、暂存阶段，压缩第N帧时第N+1帧可以同时提取
+//
+// extract为false时帧已经提取（如完整画布帧），只经过压缩和暂存阶段
+func (s *WebPService) processSelectedFrames(ctx context.Context, inputPath, tempDir string, frames []*domain.FrameInfo, config *domain.CompressionConfig, builder *assemblyManifestBuilder, extract bool, extractProgress *progressReporter) error {
+	queueSize := s.config.Processing.StageQueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+
+	s.logger.Info("开始分阶段处理已选定的帧", "frames", len(frames), "extract", extract)
+
+	source := make(chan *domain.FrameInfo, queueSize)
+	go func() {
+		defer close(source)
+		for _, frame := range frames {
+			source <- frame
+		}
+	}()
+
+	builder.setExpectedFrames(len(frames))
+	compressProgress := newProgressReporter(config.Progress, domain.StageCompress, len(frames))
+	stages := s.frameStages(inputPath, tempDir, config, builder, extract, extractProgress, compressProgress)
+
+	return runPipeline(ctx, source, stages, queueSize)
+}
+
+// frameStages 构建逐帧处理的各阶段：提取（可选）、压缩、组装暂存
+//
+// 压缩成功后立即删除临时目录中的源帧，临时磁盘占用随处理进度释放而不是堆积到组装前；
+// 开启ContinueOnError时提取或压缩失败的帧记录到清单构建器后丢弃
+func (s *WebPService) frameStages(inputPath, tempDir string, config *domain.CompressionConfig, builder *assemblyManifestBuilder, extract bool, extractProgress, compressProgress *progressReporter) []pipelineStage {
+	extractWorkers := s.config.Processing.ExtractWorkers
+	if extractWorkers <= 0 {
+		extractWorkers = 1
+	}
+
+	skipFailed := func(ctx context.Context, frame *domain.FrameInfo, err error) error {
+		if !config.ContinueOnError || ctx.Err() != nil {
+			return err
+		}
+		s.logger.Warn("帧处理失败，已从输出中丢弃", "index", frame.Index, "error", err)
+		builder.skip(frame, err)
+		return errFrameSkipped
+	}
+
+	var stages []pipelineStage
+	if extract {
+		stages = append(stages, pipelineStage{
+			name:    "extract",
+			workers: extractWorkers,
+			process: func(ctx context.Context, frame *domain.FrameInfo) error {
+				if err := s.extractFrame(ctx, inputPath, tempDir, frame); err != nil {
+					return skipFailed(ctx, frame, err)
+				}
+				extractProgress.frameDone(frame.Index)
+				return nil
+			},
+		})
+	}
+
+	return append(stages,
+		pipelineStage{
+			name:    "compress",
+			workers: s.compressWorkers(config),
+			process: s.governed(func(ctx context.Context, frame *domain.FrameInfo) error {
+				sourcePath := frame.Path
+				if err := s.compressFrameWithRetries(ctx, frame, config); err != nil {
+					return skipFailed(ctx, frame, err)
+				}
+				if err := os.Remove(sourcePath); err != nil && !os.IsNotExist(err) {
+					s.logger.Warn("删除已压缩的源帧失败", "file", sourcePath, "error", err)
+				}
+				compressProgress.frameDone(frame.Index)
+				return nil
+			}),
+		},
+		pipelineStage{
+			name:    "stage",
+			workers: 1,
+			process: func(ctx context.Context, frame *domain.FrameInfo) error {
+				return s.stageFrame(builder, frame)
+			},
+		},
+	)
+}
+
+// compressWorkers 计算压缩阶段的并发数
+func (s *WebPService) compressWorkers(config *domain.CompressionConfig) int {
+	if !config.EnableParallel {
+		return 1
+	}
+	if config.MaxConcurrency > 0 {
+		return config.MaxConcurrency
+	}
+	if s.config.Processing.CompressWorkers > 0 {
+		return s.config.Processing.CompressWorkers
+	}
+	if s.config.App.MaxConcurrency > 0 {
+		return s.config.App.MaxConcurrency
+	}
+	return 1
+}
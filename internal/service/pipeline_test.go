@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func feedFrames(count int) <-chan *domain.FrameInfo {
+	source := make(chan *domain.FrameInfo)
+	go func() {
+		for i := 1; i <= count; i++ {
+			source <- &domain.FrameInfo{Index: i}
+		}
+		close(source)
+	}()
+	return source
+}
+
+func TestRunPipeline_AllStagesApplied(t *testing.T) {
+	var extracted, compressed int32
+
+	stages := []pipelineStage{
+		{name: "extract", workers: 2, process: func(ctx context.Context, frame *domain.FrameInfo) error {
+			atomic.AddInt32(&extracted, 1)
+			frame.Path = fmt.Sprintf("frame_%d.webp", frame.Index)
+			return nil
+		}},
+		{name: "compress", workers: 3, process: func(ctx context.Context, frame *domain.FrameInfo) error {
+			if frame.Path == "" {
+				return fmt.Errorf("frame %d not extracted", frame.Index)
+			}
+			atomic.AddInt32(&compressed, 1)
+			return nil
+		}},
+	}
+
+	if err := runPipeline(context.Background(), feedFrames(20), stages, 4); err != nil {
+		t.Fatalf("runPipeline failed: %v", err)
+	}
+
+	if extracted != 20 || compressed != 20 {
+		t.Errorf("Expected 20/20 frames, got %d/%d", extracted, compressed)
+	}
+}
+
+func TestRunPipeline_StopsOnError(t *testing.T) {
+	var compressed int32
+
+	stages := []pipelineStage{
+		{name: "extract", workers: 1, process: func(ctx context.Context, frame *domain.FrameInfo) error {
+			if frame.Index == 3 {
+				return fmt.Errorf("extract failed")
+			}
+			return nil
+		}},
+		{name: "compress", workers: 1, process: func(ctx context.Context, frame *domain.FrameInfo) error {
+			atomic.AddInt32(&compressed, 1)
+			return nil
+		}},
+	}
+
+	err := runPipeline(context.Background(), feedFrames(50), stages, 1)
+	if err == nil || err.Error() != "extract failed" {
+		t.Fatalf("Expected extract error, got %v", err)
+	}
+	if compressed >= 50 {
+		t.Errorf("Expected pipeline to stop early, compressed %d", compressed)
+	}
+}
+
+func TestRunPipeline_ParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stages := []pipelineStage{
+		{name: "noop", workers: 1, process: func(ctx context.Context, frame *domain.FrameInfo) error {
+			return nil
+		}},
+	}
+
+	if err := runPipeline(ctx, feedFrames(5), stages, 1); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestProcessSelectedFrames_RemovesCompressedSources(t *testing.T) {
+	service := createTestWebPService()
+	dir := t.TempDir()
+
+	frames := make([]*domain.FrameInfo, 3)
+	for i := range frames {
+		path := filepath.Join(dir, fmt.Sprintf("frame_%d.png", i+1))
+		if err := os.WriteFile(path, []byte("png"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		frames[i] = &domain.FrameInfo{Index: i + 1, Path: path, Duration: 50 * time.Millisecond}
+	}
+
+	builder := newAssemblyManifestBuilder()
+	config := domain.DefaultCompressionConfig(50)
+	if err := service.processSelectedFrames(context.Background(), "test.webp", dir, frames, config, builder, false, nil); err != nil {
+		t.Fatalf("processSelectedFrames failed: %v", err)
+	}
+
+	manifest := builder.build("out.webp", 0)
+	if len(manifest.Frames) != 3 || manifest.Frames[2].File != filepath.Join(dir, "frame_compressed_3.webp") {
+		t.Errorf("Unexpected manifest: %+v", manifest.Frames)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("frame_%d.png", i))); !os.IsNotExist(err) {
+			t.Errorf("Expected source frame %d to be removed, stat err: %v", i, err)
+		}
+	}
+}
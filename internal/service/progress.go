@@ -0,0 +1,114 @@
+package service
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+// heartbeatInterval 单个工具长时间运行时报告心跳的间隔
+var heartbeatInterval = 2 * time.Second
+
+// progressReporter 统计某一阶段已完成的帧并调用进度回调，可被多个工作协程并发使用
+//
+// 回调为空时newProgressReporter返回nil，nil上的方法都不做任何事
+type progressReporter struct {
+	mu        sync.Mutex
+	callback  domain.StageProgressCallback
+	stage     string
+	total     int
+	completed int
+}
+
+// newProgressReporter 创建阶段进度报告器，total未知时传0
+func newProgressReporter(callback domain.StageProgressCallback, stage string, total int) *progressReporter {
+	if callback == nil {
+		return nil
+	}
+	return &progressReporter{callback: callback, stage: stage, total: total}
+}
+
+// setTotal 在边解析边处理时补充帧总数
+func (r *progressReporter) setTotal(total int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total = total
+}
+
+// frameDone 记录一帧完成
+func (r *progressReporter) frameDone(index int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed++
+	r.callback(domain.StageProgress{Stage: r.stage, FrameIndex: index, Completed: r.completed, Total: r.total})
+}
+
+// finish 把整个阶段标记为完成，用于一次处理所有帧的工具（如anim_dump、img2webp）
+func (r *progressReporter) finish() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed = r.total
+	r.callback(domain.StageProgress{Stage: r.stage, Completed: r.completed, Total: r.total})
+}
+
+// heartbeat 在单个工具长时间运行期间定期报告已运行时长和输出文件大小，避免进度看起来停滞
+//
+// outputPath为空时不报告输出大小；返回的函数停止心跳，工具结束后必须调用
+func (r *progressReporter) heartbeat(outputPath string) func() {
+	if r == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			var size int64
+			if outputPath != "" {
+				if info, err := os.Stat(outputPath); err == nil {
+					size = info.Size()
+				}
+			}
+
+			r.mu.Lock()
+			r.callback(domain.StageProgress{
+				Stage:       r.stage,
+				Completed:   r.completed,
+				Total:       r.total,
+				Heartbeat:   true,
+				Elapsed:     time.Since(start),
+				OutputBytes: size,
+			})
+			r.mu.Unlock()
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestCompressAnimation_ReportsStageProgress(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	var mu sync.Mutex
+	last := make(map[string]domain.StageProgress)
+	config := domain.DefaultCompressionConfig(50)
+	config.Progress = func(progress domain.StageProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		last[progress.Stage] = progress
+	}
+
+	if _, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config); err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	for _, stage := range []string{domain.StageExtract, domain.StageCompress, domain.StageAssemble} {
+		progress, ok := last[stage]
+		if !ok {
+			t.Errorf("No progress reported for stage %s", stage)
+			continue
+		}
+		if progress.Completed != 2 || progress.Total != 2 {
+			t.Errorf("Stage %s: expected 2/2, got %d/%d", stage, progress.Completed, progress.Total)
+		}
+	}
+}
+
+func TestProgressReporter_NilCallback(t *testing.T) {
+	reporter := newProgressReporter(nil, domain.StageCompress, 3)
+	if reporter != nil {
+		t.Fatal("Expected nil reporter without callback")
+	}
+
+	// nil报告器上的调用不应panic
+	reporter.setTotal(5)
+	reporter.frameDone(1)
+	reporter.finish()
+}
+
+func TestProgressReporter_Heartbeat(t *testing.T) {
+	interval := heartbeatInterval
+	heartbeatInterval = 5 * time.Millisecond
+	t.Cleanup(func() { heartbeatInterval = interval })
+
+	output := filepath.Join(t.TempDir(), "out.webp")
+	if err := os.WriteFile(output, make([]byte, 128), 0644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+
+	var mu sync.Mutex
+	var beats []domain.StageProgress
+	reporter := newProgressReporter(func(progress domain.StageProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		beats = append(beats, progress)
+	}, domain.StageAssemble, 3)
+
+	stop := reporter.heartbeat(output)
+	time.Sleep(30 * time.Millisecond)
+	stop()
+
+	mu.Lock()
+	count := len(beats)
+	mu.Unlock()
+	if count == 0 {
+		t.Fatal("Expected heartbeats while the tool runs")
+	}
+	for _, beat := range beats[:count] {
+		if !beat.Heartbeat || beat.Completed != 0 || beat.Total != 3 || beat.OutputBytes != 128 || beat.Elapsed <= 0 {
+			t.Errorf("Unexpected heartbeat: %+v", beat)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(beats) != count {
+		t.Errorf("Heartbeats continued after stop: %d -> %d", count, len(beats))
+	}
+}
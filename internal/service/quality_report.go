@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"webpcompressor/internal/domain"
+)
+
+// measureFrameQuality 用get_disto比较压缩结果与源帧，把PSNR和SSIM记录到帧信息
+func (s *WebPService) measureFrameQuality(ctx context.Context, frame *domain.FrameInfo, compressedPath string) error {
+	psnr, err := s.measureDistortion(ctx, "-psnr", compressedPath, frame.Path)
+	if err != nil {
+		return err
+	}
+	ssimDB, err := s.measureDistortion(ctx, "-ssim", compressedPath, frame.Path)
+	if err != nil {
+		return err
+	}
+
+	frame.PSNR = psnr
+	frame.SSIM = ssimFromDB(ssimDB)
+
+	s.logger.Debug("帧画质测量完成", "index", frame.Index, "psnr", frame.PSNR, "ssim", frame.SSIM)
+	return nil
+}
+
+// summarizeQuality 汇总各帧的PSNR和SSIM
+func summarizeQuality(frames []*domain.FrameInfo) *domain.QualityReport {
+	report := &domain.QualityReport{Frames: len(frames)}
+	if len(frames) == 0 {
+		return report
+	}
+
+	report.MinPSNR = frames[0].PSNR
+	report.MinSSIM = frames[0].SSIM
+	for _, frame := range frames {
+		report.AvgPSNR += frame.PSNR
+		report.AvgSSIM += frame.SSIM
+		if frame.PSNR < report.MinPSNR {
+			report.MinPSNR = frame.PSNR
+		}
+		if frame.SSIM < report.MinSSIM {
+			report.MinSSIM = frame.SSIM
+		}
+	}
+	report.AvgPSNR /= float64(len(frames))
+	report.AvgSSIM /= float64(len(frames))
+
+	return report
+}
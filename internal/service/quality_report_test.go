@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"webpcompressor/internal/domain"
+)
+
+func TestCompressFrame_QualityReport(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("get_disto -psnr frame_compressed_1.webp frame_1.webp", "100 38.50 38.50 38.50 38.50 99.00 [ 1.0 bpp ]")
+	mockToolExecutor.SetMockOutput("get_disto -ssim frame_compressed_1.webp frame_1.webp", "100 20.00 20.00 20.00 20.00 99.00 [ 1.0 bpp ]")
+
+	config := domain.DefaultCompressionConfig(60)
+	config.QualityReport = true
+
+	frame := &domain.FrameInfo{Index: 1, Path: "frame_1.webp"}
+	if err := service.compressFrame(context.Background(), frame, config); err != nil {
+		t.Fatalf("compressFrame failed: %v", err)
+	}
+
+	if frame.PSNR != 38.5 {
+		t.Errorf("Expected PSNR 38.5, got %v", frame.PSNR)
+	}
+	if math.Abs(frame.SSIM-0.99) > 1e-9 {
+		t.Errorf("Expected SSIM 0.99, got %v", frame.SSIM)
+	}
+}
+
+func TestSummarizeQuality(t *testing.T) {
+	frames := []*domain.FrameInfo{
+		{Index: 1, PSNR: 40, SSIM: 0.98},
+		{Index: 2, PSNR: 30, SSIM: 0.90},
+	}
+
+	report := summarizeQuality(frames)
+
+	if report.Frames != 2 || report.AvgPSNR != 35 || report.MinPSNR != 30 {
+		t.Errorf("Unexpected PSNR summary: %+v", report)
+	}
+	if math.Abs(report.AvgSSIM-0.94) > 1e-9 || report.MinSSIM != 0.90 {
+		t.Errorf("Unexpected SSIM summary: %+v", report)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// ReplayDiagnosticsBundle 按诊断包记录的输入和设置在本地重新执行失败的任务
+//
+// 诊断包只在webpmux管线组装失败时生成，重放固定使用webpmux管线，
+// 并关闭回退、缓存、历史记录和A/B实验，避免失败被其他策略掩盖。
+// 截止时间和努力程度在记录前已经换算为具体参数，重放时不再重新计算
+func (s *WebPService) ReplayDiagnosticsBundle(ctx context.Context, bundlePath, outputPath string) (*domain.ReplayReport, error) {
+	reader, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "OPEN_DIAGNOSTICS", "打开诊断包失败").
+			WithContext("bundle", bundlePath)
+	}
+	defer reader.Close()
+
+	data, err := readZipEntry(&reader.Reader, diagnosticsJobFile)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "BUNDLE_NOT_REPLAYABLE",
+			"诊断包缺少任务信息，可能由旧版本生成").WithContext("bundle", bundlePath)
+	}
+	var job diagnosticsJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "BUNDLE_NOT_REPLAYABLE", "解析任务信息失败").
+			WithContext("bundle", bundlePath)
+	}
+	if job.Input == "" {
+		return nil, errors.New(errors.ErrorTypeValidation, "BUNDLE_NOT_REPLAYABLE", "诊断包未包含输入文件").
+			WithContext("bundle", bundlePath)
+	}
+
+	report := &domain.ReplayReport{Bundle: bundlePath}
+	if data, err := readZipEntry(&reader.Reader, diagnosticsErrorFile); err == nil {
+		report.OriginalError = strings.TrimSpace(string(data))
+	}
+	if data, err := readZipEntry(&reader.Reader, diagnosticsVersionsFile); err == nil {
+		report.VersionDiffs = diffToolVersions(string(data), s.toolVersions(ctx))
+	}
+
+	workDir, err := s.fileManager.CreateTempDir("webp_replay")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(workDir)
+
+	inputPath := filepath.Join(workDir, filepath.Base(job.Input))
+	if err := extractZipEntry(&reader.Reader, job.Input, inputPath); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "EXTRACT_DIAGNOSTICS", "解出输入文件失败").
+			WithContext("entry", job.Input)
+	}
+
+	settings := job.Settings
+	settings.Pipeline = domain.PipelineWebpmux
+	settings.Best = false
+	settings.Fallback = false
+	settings.Learn = ""
+	settings.Deadline = 0
+	settings.Effort = 0
+	report.Settings = settings
+
+	// 使用记录的处理配置，去掉只在原部署上有意义的缓存、历史、统计、实验和诊断目录
+	cfg := *s.config
+	cfg.Processing = job.Processing
+	cfg.Processing.CacheDir = ""
+	cfg.Processing.HistoryFile = ""
+	cfg.Processing.StatsFile = ""
+	cfg.Processing.ExperimentStrategy = ""
+	cfg.Processing.ExperimentLog = ""
+	cfg.Processing.DiagnosticsDir = ""
+	replay := &WebPService{
+		config:       &cfg,
+		toolExecutor: s.toolExecutor,
+		fileManager:  s.fileManager,
+		logger:       s.logger.With("replay", bundlePath),
+		encoders:     s.encoders,
+		cpuProfile:   s.cpuProfile,
+		governor:     s.governor,
+	}
+
+	result, err := replay.CompressAnimation(ctx, inputPath, outputPath, &settings)
+	if err != nil {
+		report.Error = err.Error()
+		report.Reproduced = true
+		if appErr, ok := err.(*errors.AppError); ok && job.ErrorCode != "" {
+			report.Reproduced = appErr.Code == job.ErrorCode
+		}
+		return report, nil
+	}
+	report.Result = result
+	return report, nil
+}
+
+// diffToolVersions 比较诊断包记录的工具版本与本地版本
+func diffToolVersions(recorded, current string) []domain.ToolVersionDiff {
+	before := parseToolVersions(recorded)
+	after := parseToolVersions(current)
+
+	var diffs []domain.ToolVersionDiff
+	for _, tool := range diagnosticTools {
+		if before[tool] != after[tool] {
+			diffs = append(diffs, domain.ToolVersionDiff{Tool: tool, Recorded: before[tool], Current: after[tool]})
+		}
+	}
+	return diffs
+}
+
+// parseToolVersions 解析toolVersions输出的"工具: 版本"行
+func parseToolVersions(text string) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		tool, version, ok := strings.Cut(line, ": ")
+		if ok {
+			versions[tool] = strings.TrimSpace(version)
+		}
+	}
+	return versions
+}
+
+// readZipEntry 读取zip条目的全部内容
+func readZipEntry(r *zip.Reader, name string) ([]byte, error) {
+	src, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+// extractZipEntry 将zip条目写入目标文件
+func extractZipEntry(r *zip.Reader, name, dst string) error {
+	src, err := r.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return fmt.Errorf("写入 %s: %w", dst, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestReplayDiagnosticsBundle(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(os.TempDir(), "webp_replay_test"), 0755); err != nil {
+		t.Fatalf("create replay dir: %v", err)
+	}
+
+	service := createTestWebPService()
+	service.config.Processing.DiagnosticsDir = t.TempDir()
+	statsFile := filepath.Join(t.TempDir(), "stats.jsonl")
+	service.config.Processing.StatsFile = statsFile
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -version", "1.3.2")
+
+	inputPath := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)))
+	settings := &domain.CompressionConfig{Quality: 40, Method: 4, Pipeline: domain.PipelineImg2webp, Best: true, Fallback: true, Effort: 5}
+	manifest := &domain.AssemblyManifest{Output: "out.webp"}
+	assemblyErr := errors.New(errors.ErrorTypeExecution, "ASSEMBLE_ANIMATION", "重新组装动画失败")
+
+	bundlePath, err := service.writeDiagnosticsBundle(context.Background(), t.TempDir(), inputPath, settings, manifest, assemblyErr)
+	if err != nil {
+		t.Fatalf("writeDiagnosticsBundle failed: %v", err)
+	}
+
+	// 本地工具升级后重放，解析阶段失败说明原错误没有重现
+	mockToolExecutor.SetMockOutput("webpmux -version", "1.4.0")
+	replayInput := filepath.Join(os.TempDir(), "webp_replay_test", "input.webp")
+	mockToolExecutor.SetMockError("webpmux -info "+replayInput, errors.New(errors.ErrorTypeExecution, "PARSE_ANIMATION", "解析失败"))
+
+	report, err := service.ReplayDiagnosticsBundle(context.Background(), bundlePath, filepath.Join(t.TempDir(), "out.webp"))
+	if err != nil {
+		t.Fatalf("ReplayDiagnosticsBundle failed: %v", err)
+	}
+
+	if report.Reproduced || report.Error == "" {
+		t.Errorf("Expected a different failure, got reproduced=%v error=%q", report.Reproduced, report.Error)
+	}
+	if !strings.Contains(report.OriginalError, "ASSEMBLE_ANIMATION") {
+		t.Errorf("Unexpected original error: %q", report.OriginalError)
+	}
+	if s := report.Settings; s.Pipeline != domain.PipelineWebpmux || s.Best || s.Fallback || s.Effort != 0 || s.Quality != 40 || s.Method != 4 {
+		t.Errorf("Unexpected replay settings: %+v", s)
+	}
+	if len(report.VersionDiffs) != 1 || report.VersionDiffs[0].Tool != "webpmux" ||
+		report.VersionDiffs[0].Recorded != "1.3.2" || report.VersionDiffs[0].Current != "1.4.0" {
+		t.Errorf("Unexpected version diffs: %+v", report.VersionDiffs)
+	}
+	if data, err := os.ReadFile(replayInput); err != nil || len(data) == 0 {
+		t.Errorf("Expected input extracted to %s (%v)", replayInput, err)
+	}
+	// 重放不应写入原部署的统计文件
+	if _, err := os.Stat(statsFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no stats written during replay, got %v", err)
+	}
+}
+
+func TestReplayDiagnosticsBundle_MissingJob(t *testing.T) {
+	service := createTestWebPService()
+
+	bundlePath := filepath.Join(t.TempDir(), "old.zip")
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		t.Fatalf("create bundle: %v", err)
+	}
+	zw := zip.NewWriter(file)
+	if err := writeZipEntry(zw, "error.txt", []byte("failed\n")); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+	zw.Close()
+	file.Close()
+
+	_, err = service.ReplayDiagnosticsBundle(context.Background(), bundlePath, filepath.Join(t.TempDir(), "out.webp"))
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != "BUNDLE_NOT_REPLAYABLE" {
+		t.Errorf("Expected BUNDLE_NOT_REPLAYABLE, got %v", err)
+	}
+}
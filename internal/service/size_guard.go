@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"webpcompressor/pkg/errors"
+)
+
+// sizeGuardMinFrames 按平均帧大小预测输出总大小前至少需要暂存的帧数
+const sizeGuardMinFrames = 3
+
+// outputWatchInterval 单个工具写输出期间检查输出文件大小的间隔
+var outputWatchInterval = 500 * time.Millisecond
+
+// outputSizeLimit 返回输出大小上限，即输入大小乘以Processing.MaxOutputRatio，未配置时为0
+func (s *WebPService) outputSizeLimit(inputPath string) int64 {
+	ratio := s.config.Processing.MaxOutputRatio
+	if ratio <= 0 {
+		return 0
+	}
+	size, err := s.fileManager.GetFileSize(inputPath)
+	if err != nil || size <= 0 {
+		return 0
+	}
+	return int64(float64(size) * ratio)
+}
+
+// outputTooLargeError 预计输出超过上限时的错误
+func outputTooLargeError(projected, limit int64) error {
+	return errors.New(errors.ErrorTypeValidation, "OUTPUT_TOO_LARGE",
+		fmt.Sprintf("预计输出大小超过上限: %s > %s", formatFileSize(projected), formatFileSize(limit))).
+		WithDetails("检查无损、质量和近无损等设置，或调大WEBP_MAX_OUTPUT_RATIO")
+}
+
+// watchOutputSize 在单个工具写输出期间定期检查输出文件大小，超过上限时取消返回的ctx让工具提前结束
+//
+// 返回的函数停止检查，输出超过上限时返回OUTPUT_TOO_LARGE并删除不完整的输出；limit为0时不检查
+func watchOutputSize(ctx context.Context, path string, limit int64) (context.Context, func() error) {
+	if limit <= 0 {
+		return ctx, func() error { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var exceeded error
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(outputWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if info, err := os.Stat(path); err == nil && info.Size() > limit {
+				exceeded = outputTooLargeError(info.Size(), limit)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return ctx, func() error {
+		close(done)
+		<-stopped
+		cancel()
+		if exceeded != nil {
+			os.Remove(path)
+		}
+		return exceeded
+	}
+}
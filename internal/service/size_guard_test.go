@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestAssemblyManifestBuilder_CheckOutputSize(t *testing.T) {
+	builder := newAssemblyManifestBuilder()
+	builder.limitOutputSize(1000)
+	builder.setExpectedFrames(10)
+
+	// 前两帧只比较已暂存的大小，第三帧起按平均帧大小外推到10帧
+	for i := 1; i <= 2; i++ {
+		builder.add(&domain.FrameInfo{Index: i}, 150, "")
+		if err := builder.checkOutputSize(); err != nil {
+			t.Fatalf("Frame %d: unexpected error %v", i, err)
+		}
+	}
+	builder.add(&domain.FrameInfo{Index: 3}, 150, "")
+	if err := builder.checkOutputSize(); !errors.IsCode(err, "OUTPUT_TOO_LARGE") {
+		t.Errorf("Expected OUTPUT_TOO_LARGE for projected 1500 bytes, got %v", err)
+	}
+
+	unlimited := newAssemblyManifestBuilder()
+	unlimited.add(&domain.FrameInfo{Index: 1}, 1<<30, "")
+	if err := unlimited.checkOutputSize(); err != nil {
+		t.Errorf("Expected no limit by default, got %v", err)
+	}
+}
+
+func TestCompressAnimation_AbortsOnRunawayOutputSize(t *testing.T) {
+	service := createTestWebPService()
+	// 模拟的输入和每个压缩帧都是1024字节，两帧之和超过输入的1.5倍
+	service.config.Processing.MaxOutputRatio = 1.5
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	_, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", domain.DefaultCompressionConfig(50))
+	if !errors.IsCode(err, "OUTPUT_TOO_LARGE") {
+		t.Fatalf("Expected OUTPUT_TOO_LARGE, got %v", err)
+	}
+	for _, command := range mockToolExecutor.commands {
+		if strings.HasPrefix(command, "webpmux -frame") {
+			t.Errorf("Expected assembly to be skipped, got %s", command)
+		}
+	}
+}
+
+func TestWatchOutputSize(t *testing.T) {
+	interval := outputWatchInterval
+	outputWatchInterval = 5 * time.Millisecond
+	t.Cleanup(func() { outputWatchInterval = interval })
+
+	output := filepath.Join(t.TempDir(), "out.webp")
+	ctx, stop := watchOutputSize(context.Background(), output, 100)
+	if err := os.WriteFile(output, make([]byte, 200), 0644); err != nil {
+		t.Fatalf("write output: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the tool context to be cancelled")
+	}
+	if err := stop(); !errors.IsCode(err, "OUTPUT_TOO_LARGE") {
+		t.Errorf("Expected OUTPUT_TOO_LARGE, got %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial output to be removed, got %v", err)
+	}
+
+	parent := context.Background()
+	ctx, stop = watchOutputSize(parent, output, 0)
+	if ctx != parent || stop() != nil {
+		t.Error("Expected no watch without a limit")
+	}
+}
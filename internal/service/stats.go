@@ -0,0 +1,351 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// inputCharacteristics 从RIFF块读取的输入特征，不需要调用外部工具
+type inputCharacteristics struct {
+	Width      int   `json:"width,omitempty"`
+	Height     int   `json:"height,omitempty"`
+	Frames     int   `json:"frames,omitempty"`
+	DurationMs int64 `json:"duration_ms,omitempty"` // 所有帧时长之和
+	LoopCount  int   `json:"loop_count"`
+	InputSize  int64 `json:"input_size,omitempty"`
+}
+
+// statsRecord 一次压缩任务的统计，按行写入统计文件(JSON Lines)，可直接导入SQLite或ClickHouse分析
+type statsRecord struct {
+	Time    time.Time `json:"time"`
+	Version string    `json:"version"`
+	Input   string    `json:"input"`
+	inputCharacteristics
+	Settings       domain.CompressionConfig `json:"settings"`
+	ProcessingTime time.Duration            `json:"processing_time"`
+	Result         *domain.CompressResult   `json:"result,omitempty"` // 不含逐帧统计
+	Error          string                   `json:"error,omitempty"`
+}
+
+// recordStats 把任务的输入特征、设置和结果追加到统计文件，未配置时不记录，失败只记录警告
+func (s *WebPService) recordStats(inputPath string, config *domain.CompressionConfig, result *domain.CompressResult, duration time.Duration, taskErr error) {
+	path := s.config.Processing.StatsFile
+	if path == "" {
+		return
+	}
+
+	record := statsRecord{
+		Time:           time.Now(),
+		Version:        s.config.App.Version,
+		Input:          inputPath,
+		Settings:       *config,
+		ProcessingTime: duration,
+	}
+	if characteristics, err := readInputCharacteristics(inputPath); err != nil {
+		s.logger.Debug("读取输入特征失败", "file", inputPath, "error", err)
+	} else {
+		record.inputCharacteristics = *characteristics
+	}
+	if taskErr != nil {
+		record.Error = taskErr.Error()
+	} else {
+		trimmed := *result
+		trimmed.FrameStats = nil
+		record.Result = &trimmed
+	}
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if err := appendJSONLine(path, record); err != nil {
+		s.logger.Warn("写入压缩统计失败", "file", path, "error", err)
+	}
+}
+
+// readInputCharacteristics 读取VP8X画布尺寸、ANIM循环次数以及各ANMF帧的时长
+func readInputCharacteristics(path string) (*inputCharacteristics, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	characteristics := &inputCharacteristics{InputSize: info.Size()}
+
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return characteristics, nil
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return characteristics, nil
+	}
+	end := int64(binary.LittleEndian.Uint32(header[4:8])) + 8
+
+	var payload [16]byte
+	err = walkChunks(file, 12, end, func(id string, offset, size int64) error {
+		switch {
+		case id == "VP8X" && size >= 10:
+			if _, err := file.ReadAt(payload[:10], offset); err != nil {
+				return err
+			}
+			characteristics.Width = uint24(payload[4:7]) + 1
+			characteristics.Height = uint24(payload[7:10]) + 1
+		case id == "ANIM" && size >= 6:
+			if _, err := file.ReadAt(payload[:6], offset); err != nil {
+				return err
+			}
+			characteristics.LoopCount = int(binary.LittleEndian.Uint16(payload[4:6]))
+		case id == "ANMF" && size >= anmfHeaderSize:
+			if _, err := file.ReadAt(payload[:anmfHeaderSize], offset); err != nil {
+				return err
+			}
+			characteristics.Frames++
+			characteristics.DurationMs += int64(uint24(payload[12:15]))
+		}
+		return nil
+	})
+	return characteristics, err
+}
+
+// uint24 解析小端序的24位整数
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// appendJSONLine 把一条记录序列化后追加到JSON Lines文件
+func appendJSONLine(path string, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 统计导出格式
+const (
+	StatsFormatCSV     = "csv"
+	StatsFormatJSONL   = "jsonl"
+	StatsFormatParquet = "parquet"
+)
+
+// statsColumns CSV导出的列，依次对应statsRow返回的值
+var statsColumns = []string{
+	"time", "version", "input", "input_size", "width", "height", "frames", "duration_ms", "loop_count",
+	"quality", "method", "pass", "lossless", "near_lossless", "mixed", "pipeline", "encoder",
+	"processing_time_ms", "original_size", "compressed_size", "compression_ratio",
+	"frames_processed", "frames_dropped", "frames_merged", "result_pipeline", "fallback", "error",
+}
+
+// statsParquetColumns Parquet导出的列，与statsColumns同名同序，依次对应statsParquetRow返回的值；
+// 结果列在任务失败时为空
+var statsParquetColumns = []parquetColumn{
+	{name: "time", kind: parquetTimestamp},
+	{name: "version", kind: parquetString},
+	{name: "input", kind: parquetString},
+	{name: "input_size", kind: parquetInt64},
+	{name: "width", kind: parquetInt64},
+	{name: "height", kind: parquetInt64},
+	{name: "frames", kind: parquetInt64},
+	{name: "duration_ms", kind: parquetInt64},
+	{name: "loop_count", kind: parquetInt64},
+	{name: "quality", kind: parquetInt64},
+	{name: "method", kind: parquetInt64},
+	{name: "pass", kind: parquetInt64},
+	{name: "lossless", kind: parquetBoolean},
+	{name: "near_lossless", kind: parquetInt64},
+	{name: "mixed", kind: parquetBoolean},
+	{name: "pipeline", kind: parquetString},
+	{name: "encoder", kind: parquetString},
+	{name: "processing_time_ms", kind: parquetInt64},
+	{name: "original_size", kind: parquetInt64, optional: true},
+	{name: "compressed_size", kind: parquetInt64, optional: true},
+	{name: "compression_ratio", kind: parquetDouble, optional: true},
+	{name: "frames_processed", kind: parquetInt64, optional: true},
+	{name: "frames_dropped", kind: parquetInt64, optional: true},
+	{name: "frames_merged", kind: parquetInt64, optional: true},
+	{name: "result_pipeline", kind: parquetString, optional: true},
+	{name: "fallback", kind: parquetString, optional: true},
+	{name: "error", kind: parquetString, optional: true},
+}
+
+// IsValidStatsFormat 判断是否为支持的统计导出格式
+func IsValidStatsFormat(format string) bool {
+	return format == StatsFormatCSV || format == StatsFormatJSONL || format == StatsFormatParquet
+}
+
+// ExportStats 把统计文件中时间位于[from, to)的记录按指定格式写出，零值时间表示不限制，返回导出的记录数
+func (s *WebPService) ExportStats(statsPath string, from, to time.Time, format string, w io.Writer) (int, error) {
+	if !IsValidStatsFormat(format) {
+		return 0, errors.New(errors.ErrorTypeValidation, "UNSUPPORTED_EXPORT_FORMAT",
+			fmt.Sprintf("不支持的导出格式: %s，支持: csv、jsonl、parquet", format))
+	}
+
+	file, err := os.Open(statsPath)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrorTypeIO, "OPEN_STATS_FILE", "打开统计文件失败").
+			WithContext("file", statsPath)
+	}
+	defer file.Close()
+
+	var csvWriter *csv.Writer
+	if format == StatsFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(statsColumns); err != nil {
+			return 0, errors.Wrap(err, errors.ErrorTypeIO, "WRITE_STATS_EXPORT", "写出统计失败")
+		}
+	}
+
+	exported := 0
+	var parquetRows [][]any // Parquet按列存储，读完全部记录后一次写出
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return exported, errors.Wrap(readErr, errors.ErrorTypeIO, "READ_STATS_FILE", "读取统计文件失败")
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			var record statsRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return exported, errors.Wrapf(err, errors.ErrorTypeValidation, "INVALID_STATS_FILE", "统计文件第%d行格式错误", line)
+			}
+			if (from.IsZero() || !record.Time.Before(from)) && (to.IsZero() || record.Time.Before(to)) {
+				switch format {
+				case StatsFormatCSV:
+					err = csvWriter.Write(statsRow(&record))
+				case StatsFormatParquet:
+					parquetRows = append(parquetRows, statsParquetRow(&record))
+				default:
+					_, err = w.Write(append(data, '\n'))
+				}
+				if err != nil {
+					return exported, errors.Wrap(err, errors.ErrorTypeIO, "WRITE_STATS_EXPORT", "写出统计失败")
+				}
+				exported++
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return exported, errors.Wrap(err, errors.ErrorTypeIO, "WRITE_STATS_EXPORT", "写出统计失败")
+		}
+	}
+	if format == StatsFormatParquet {
+		if err := writeParquet(w, statsParquetColumns, parquetRows); err != nil {
+			return 0, errors.Wrap(err, errors.ErrorTypeIO, "WRITE_STATS_EXPORT", "写出统计失败")
+		}
+	}
+	return exported, nil
+}
+
+// statsRow 把一条统计记录展开为CSV的一行，失败的任务结果列为空
+func statsRow(record *statsRecord) []string {
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	settings := record.Settings
+	row := []string{
+		record.Time.UTC().Format(time.RFC3339),
+		record.Version,
+		record.Input,
+		itoa(record.InputSize),
+		itoa(int64(record.Width)),
+		itoa(int64(record.Height)),
+		itoa(int64(record.Frames)),
+		itoa(record.DurationMs),
+		itoa(int64(record.LoopCount)),
+		itoa(int64(settings.Quality)),
+		itoa(int64(settings.Method)),
+		itoa(int64(settings.Pass)),
+		strconv.FormatBool(settings.Lossless),
+		itoa(int64(settings.NearLossless)),
+		strconv.FormatBool(settings.Mixed),
+		settings.Pipeline,
+		settings.Encoder,
+		itoa(record.ProcessingTime.Milliseconds()),
+	}
+	if result := record.Result; result != nil {
+		row = append(row,
+			itoa(result.OriginalSize),
+			itoa(result.CompressedSize),
+			strconv.FormatFloat(result.CompressionRatio, 'f', 2, 64),
+			itoa(int64(result.FramesProcessed)),
+			itoa(int64(result.FramesDropped)),
+			itoa(int64(result.FramesMerged)),
+			result.Pipeline,
+			result.Fallback,
+		)
+	} else {
+		row = append(row, "", "", "", "", "", "", "", "")
+	}
+	return append(row, record.Error)
+}
+
+// statsParquetRow 把一条统计记录展开为Parquet的一行，失败的任务结果列为nil
+func statsParquetRow(record *statsRecord) []any {
+	settings := record.Settings
+	row := []any{
+		record.Time.UnixMilli(),
+		record.Version,
+		record.Input,
+		record.InputSize,
+		int64(record.Width),
+		int64(record.Height),
+		int64(record.Frames),
+		record.DurationMs,
+		int64(record.LoopCount),
+		int64(settings.Quality),
+		int64(settings.Method),
+		int64(settings.Pass),
+		settings.Lossless,
+		int64(settings.NearLossless),
+		settings.Mixed,
+		settings.Pipeline,
+		settings.Encoder,
+		record.ProcessingTime.Milliseconds(),
+	}
+	if result := record.Result; result != nil {
+		row = append(row,
+			result.OriginalSize,
+			result.CompressedSize,
+			result.CompressionRatio,
+			int64(result.FramesProcessed),
+			int64(result.FramesDropped),
+			int64(result.FramesMerged),
+			result.Pipeline,
+			result.Fallback,
+		)
+	} else {
+		row = append(row, nil, nil, nil, nil, nil, nil, nil, nil)
+	}
+	if record.Error == "" {
+		return append(row, nil)
+	}
+	return append(row, record.Error)
+}
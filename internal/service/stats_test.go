@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// timedAnmfChunk 生成指定时长的ANMF块
+func timedAnmfChunk(durationMs int) []byte {
+	payload := make([]byte, anmfHeaderSize)
+	payload[12] = byte(durationMs)
+	payload[13] = byte(durationMs >> 8)
+	return riffChunk("ANMF", payload)
+}
+
+func TestCompressAnimation_RecordsStats(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[4], vp8x[5], vp8x[7] = 31, 1, 159 // 画布288x160，存储为尺寸减一
+	anim := []byte{0, 0, 0, 0, 3, 0}
+	input := writeTestWebP(t, riffChunk("VP8X", vp8x), riffChunk("ANIM", anim), timedAnmfChunk(50), timedAnmfChunk(300))
+
+	service := createTestWebPService()
+	statsFile := filepath.Join(t.TempDir(), "stats.jsonl")
+	service.config.Processing.StatsFile = statsFile
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info "+input, verifyTestOutputInfo)
+
+	if _, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(50)); err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	mockToolExecutor.SetMockError("webpmux -info "+input, fmt.Errorf("webpmux crashed"))
+	if _, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(60)); err == nil {
+		t.Fatal("Expected the failing parse to fail the job")
+	}
+
+	file, err := os.Open(statsFile)
+	if err != nil {
+		t.Fatalf("open stats: %v", err)
+	}
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("parse record: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	ok := records[0]
+	if ok["width"] != 288.0 || ok["height"] != 160.0 || ok["frames"] != 2.0 || ok["duration_ms"] != 350.0 || ok["loop_count"] != 3.0 {
+		t.Errorf("Unexpected input characteristics: %v", ok)
+	}
+	if settings := ok["settings"].(map[string]interface{}); settings["quality"] != 50.0 {
+		t.Errorf("Unexpected settings: %v", settings)
+	}
+	result, _ := ok["result"].(map[string]interface{})
+	if result == nil || result["frames_processed"] != 2.0 {
+		t.Errorf("Expected the result to be recorded, got %v", ok["result"])
+	}
+	if _, hasFrameStats := result["frame_stats"]; hasFrameStats {
+		t.Error("Frame stats should not be recorded")
+	}
+
+	if failed := records[1]; failed["error"] == nil || failed["result"] != nil {
+		t.Errorf("Expected the failure to be recorded, got %v", failed)
+	}
+}
+
+func TestExportStats(t *testing.T) {
+	service := createTestWebPService()
+	statsFile := filepath.Join(t.TempDir(), "stats.jsonl")
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, record := range []statsRecord{
+		{Time: day, Input: "a.webp", Settings: domain.CompressionConfig{Quality: 40}, Result: &domain.CompressResult{CompressedSize: 100}},
+		{Time: day.Add(24 * time.Hour), Input: "b.webp", Error: "failed"},
+		{Time: day.Add(48 * time.Hour), Input: "c.webp"},
+	} {
+		if err := appendJSONLine(statsFile, record); err != nil {
+			t.Fatalf("write record %d: %v", i, err)
+		}
+	}
+
+	var out bytes.Buffer
+	count, err := service.ExportStats(statsFile, day, day.Add(48*time.Hour), StatsFormatCSV, &out)
+	if err != nil {
+		t.Fatalf("ExportStats failed: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if count != 2 || len(rows) != 3 || !reflect.DeepEqual(rows[0], statsColumns) {
+		t.Fatalf("Expected header and 2 rows, got %d: %v", count, rows)
+	}
+	column := func(row []string, name string) string {
+		for i, c := range statsColumns {
+			if c == name {
+				return row[i]
+			}
+		}
+		return ""
+	}
+	if column(rows[1], "input") != "a.webp" || column(rows[1], "quality") != "40" || column(rows[1], "compressed_size") != "100" {
+		t.Errorf("Unexpected first row: %v", rows[1])
+	}
+	if column(rows[2], "error") != "failed" || column(rows[2], "compressed_size") != "" {
+		t.Errorf("Unexpected failed row: %v", rows[2])
+	}
+
+	out.Reset()
+	if count, err := service.ExportStats(statsFile, time.Time{}, time.Time{}, StatsFormatJSONL, &out); err != nil || count != 3 {
+		t.Errorf("Expected all 3 records as JSON Lines, got %d (%v)", count, err)
+	}
+
+	out.Reset()
+	count, err = service.ExportStats(statsFile, day, day.Add(48*time.Hour), StatsFormatParquet, &out)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 records as Parquet, got %d (%v)", count, err)
+	}
+	footer, values := readParquet(t, out.Bytes())
+	var names []string
+	for _, element := range footer[2].([]any)[1:] {
+		names = append(names, element.(map[int16]any)[4].(string))
+	}
+	if !reflect.DeepEqual(names, statsColumns) || footer[3].(int64) != 2 {
+		t.Fatalf("Expected stats columns and 2 rows, got %v/%v", names, footer[3])
+	}
+	parquetValues := func(name string) []any {
+		for i, c := range statsColumns {
+			if c == name {
+				return values[i]
+			}
+		}
+		return nil
+	}
+	if got := parquetValues("input"); !reflect.DeepEqual(got, []any{"a.webp", "b.webp"}) {
+		t.Errorf("Unexpected input column: %v", got)
+	}
+	if got := parquetValues("compressed_size"); !reflect.DeepEqual(got, []any{int64(100), nil}) {
+		t.Errorf("Unexpected compressed_size column: %v", got)
+	}
+	if got := parquetValues("error"); !reflect.DeepEqual(got, []any{nil, "failed"}) {
+		t.Errorf("Unexpected error column: %v", got)
+	}
+
+	if _, err := service.ExportStats(statsFile, time.Time{}, time.Time{}, "xlsx", &out); !errors.IsCode(err, "UNSUPPORTED_EXPORT_FORMAT") {
+		t.Errorf("Expected UNSUPPORTED_EXPORT_FORMAT, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// validCwebpPresets cwebp支持的预设
+var validCwebpPresets = map[string]bool{
+	"default": true,
+	"photo":   true,
+	"picture": true,
+	"drawing": true,
+	"icon":    true,
+	"text":    true,
+}
+
+// maxFrameDuration WebP帧时长上限(毫秒，24位)
+const maxFrameDuration = 1<<24 - 1
+
+// cwebpArgs cwebp编码参数
+type cwebpArgs struct {
+	Quality        int    // -q 0-100
+	Method         int    // -m 0-6
+	Preset         string // -preset，为空则不指定
+	FilterStrength int    // -f 0-100
+	Sharpness      int    // -sharpness 0-7
+	SNS            int    // -sns 0-100
+	Segments       int    // -segments 1-4
+	Pass           int    // -pass 1-10
+	AlphaQuality   int    // -alpha_q 0-100
+	TargetSize     int    // -size，0表示不限制
+	Lossless       bool   // -lossless
+	NearLossless   int    // -near_lossless 1-100，0表示不启用（隐含无损）
+	MultiThread    bool   // -mt
+	Input          string
+	Output         string
+}
+
+// newCwebpArgs 由压缩配置生成cwebp参数
+func newCwebpArgs(config *domain.CompressionConfig, inputPath, outputPath string) *cwebpArgs {
+	pass := config.Pass
+	if pass == 0 {
+		pass = 10
+	}
+	return &cwebpArgs{
+		Quality:        config.Quality,
+		Method:         config.Method,
+		Preset:         config.Preset,
+		FilterStrength: config.FilterStrength,
+		Sharpness:      0,
+		SNS:            100,
+		Segments:       4,
+		Pass:           pass,
+		AlphaQuality:   config.AlphaQuality,
+		TargetSize:     0,
+		Lossless:       config.Lossless,
+		NearLossless:   config.NearLossless,
+		MultiThread:    true,
+		Input:          inputPath,
+		Output:         outputPath,
+	}
+}
+
+// Validate 检查编码参数范围，不检查输入输出路径
+func (a *cwebpArgs) Validate() error {
+	ranges := []struct {
+		name          string
+		value, lo, hi int
+	}{
+		{"q", a.Quality, 0, 100},
+		{"m", a.Method, 0, 6},
+		{"f", a.FilterStrength, 0, 100},
+		{"sharpness", a.Sharpness, 0, 7},
+		{"sns", a.SNS, 0, 100},
+		{"segments", a.Segments, 1, 4},
+		{"pass", a.Pass, 1, 10},
+		{"alpha_q", a.AlphaQuality, 0, 100},
+		{"near_lossless", a.NearLossless, 0, 100},
+	}
+	for _, r := range ranges {
+		if r.value < r.lo || r.value > r.hi {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("cwebp参数-%s超出范围[%d, %d]: %d", r.name, r.lo, r.hi, r.value))
+		}
+	}
+
+	if a.TargetSize < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("cwebp参数-size不能为负数: %d", a.TargetSize))
+	}
+	if a.Preset != "" && !validCwebpPresets[a.Preset] {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("不支持的cwebp预设: %s", a.Preset))
+	}
+
+	return nil
+}
+
+// Render 校验并生成命令行参数
+func (a *cwebpArgs) Render() ([]string, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if a.Input == "" || a.Output == "" {
+		return nil, errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS", "cwebp缺少输入或输出路径")
+	}
+
+	var args []string
+	if a.Lossless || a.NearLossless > 0 {
+		args = append(args, "-lossless")
+	}
+	if a.NearLossless > 0 {
+		args = append(args, "-near_lossless", strconv.Itoa(a.NearLossless))
+	}
+	args = append(args,
+		"-q", strconv.Itoa(a.Quality),
+		"-m", strconv.Itoa(a.Method),
+	)
+	if a.Preset != "" {
+		args = append(args, "-preset", a.Preset)
+	}
+	if a.MultiThread {
+		args = append(args, "-mt")
+	}
+	args = append(args,
+		"-f", strconv.Itoa(a.FilterStrength),
+		"-sharpness", strconv.Itoa(a.Sharpness),
+		"-sns", strconv.Itoa(a.SNS),
+		"-segments", strconv.Itoa(a.Segments),
+		"-pass", strconv.Itoa(a.Pass),
+		"-alpha_q", strconv.Itoa(a.AlphaQuality),
+		"-size", strconv.Itoa(a.TargetSize),
+		"-metadata", "none",
+		a.Input,
+		"-o", a.Output,
+	)
+
+	return args, nil
+}
+
+// ffmpegWebPArgs ffmpeg编码单帧WebP的参数
+type ffmpegWebPArgs struct {
+	Codec            string // -c:v，如libwebp或厂商编码器
+	Quality          int    // -quality 0-100
+	CompressionLevel int    // -compression_level 0-6，对应cwebp的-m
+	Preset           string // -preset，为空则不指定
+	Lossless         bool   // -lossless 1
+	Input            string
+	Output           string
+}
+
+// newFFmpegWebPArgs 由压缩配置生成ffmpeg参数
+func newFFmpegWebPArgs(codec string, config *domain.CompressionConfig, inputPath, outputPath string) *ffmpegWebPArgs {
+	return &ffmpegWebPArgs{
+		Codec:            codec,
+		Quality:          config.Quality,
+		CompressionLevel: config.Method,
+		Preset:           config.Preset,
+		Lossless:         config.Lossless,
+		Input:            inputPath,
+		Output:           outputPath,
+	}
+}
+
+// Validate 检查编码参数范围，不检查输入输出路径
+func (a *ffmpegWebPArgs) Validate() error {
+	if a.Codec == "" {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS", "ffmpeg缺少编码器名称")
+	}
+	if a.Quality < 0 || a.Quality > 100 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("ffmpeg参数-quality超出范围[0, 100]: %d", a.Quality))
+	}
+	if a.CompressionLevel < 0 || a.CompressionLevel > 6 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("ffmpeg参数-compression_level超出范围[0, 6]: %d", a.CompressionLevel))
+	}
+	if a.Preset != "" && !validCwebpPresets[a.Preset] {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("不支持的ffmpeg WebP预设: %s", a.Preset))
+	}
+	return nil
+}
+
+// Render 校验并生成命令行参数
+func (a *ffmpegWebPArgs) Render() ([]string, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if a.Input == "" || a.Output == "" {
+		return nil, errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS", "ffmpeg缺少输入或输出路径")
+	}
+
+	lossless := "0"
+	if a.Lossless {
+		lossless = "1"
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", a.Input,
+		"-frames:v", "1",
+		"-c:v", a.Codec,
+		"-lossless", lossless,
+		"-quality", strconv.Itoa(a.Quality),
+		"-compression_level", strconv.Itoa(a.CompressionLevel),
+	}
+	if a.Preset != "" {
+		args = append(args, "-preset", a.Preset)
+	}
+	return append(args, "-f", "webp", a.Output), nil
+}
+
+// webpmuxFrame webpmux组装中的单帧参数
+type webpmuxFrame struct {
+	File     string
+	Duration int // 毫秒
+	X        int
+	Y        int
+	Dispose  domain.DisposeMethod
+	Blend    domain.BlendMethod
+}
+
+// webpmuxAssembleArgs webpmux组装动画参数
+type webpmuxAssembleArgs struct {
+	Frames []webpmuxFrame
+	Loop   int // 0表示无限循环
+	Output string
+}
+
+// Validate 检查参数范围
+func (a *webpmuxAssembleArgs) Validate() error {
+	if len(a.Frames) == 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS", "webpmux组装没有帧")
+	}
+	if a.Loop < 0 || a.Loop > 65535 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+			fmt.Sprintf("webpmux循环次数超出范围[0, 65535]: %d", a.Loop))
+	}
+	if a.Output == "" {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS", "webpmux缺少输出路径")
+	}
+
+	for i, frame := range a.Frames {
+		if frame.File == "" {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("webpmux第%d帧缺少文件路径", i+1))
+		}
+		if frame.Duration < 0 || frame.Duration > maxFrameDuration {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("webpmux第%d帧时长超出范围[0, %d]: %d", i+1, maxFrameDuration, frame.Duration))
+		}
+		// ANMF中偏移按2像素存储，奇数偏移会被截断
+		if frame.X < 0 || frame.Y < 0 || frame.X%2 != 0 || frame.Y%2 != 0 {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("webpmux第%d帧偏移必须为非负偶数: %d,%d", i+1, frame.X, frame.Y))
+		}
+		if frame.Dispose != domain.DisposeNone && frame.Dispose != domain.DisposeBackground {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("webpmux第%d帧处理方式无效: %d", i+1, frame.Dispose))
+		}
+		if frame.Blend != domain.BlendNo && frame.Blend != domain.BlendYes {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_TOOL_ARGS",
+				fmt.Sprintf("webpmux第%d帧混合方式无效: %d", i+1, frame.Blend))
+		}
+	}
+
+	return nil
+}
+
+// Render 校验并生成命令行参数
+func (a *webpmuxAssembleArgs) Render() ([]string, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, len(a.Frames)*3+4)
+	for _, frame := range a.Frames {
+		blendStr := "-b"
+		if frame.Blend == domain.BlendYes {
+			blendStr = "+b"
+		}
+
+		// 正确的webpmux格式：file_i +di+xi+yi+mi+bi
+		// 文件路径和参数应该分别作为独立的参数
+		frameParams := fmt.Sprintf("+%d+%d+%d+%d%s",
+			frame.Duration, frame.X, frame.Y, int(frame.Dispose), blendStr)
+
+		args = append(args, "-frame", frame.File, frameParams)
+	}
+
+	return append(args, "-loop", strconv.Itoa(a.Loop), "-o", a.Output), nil
+}
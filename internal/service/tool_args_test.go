@@ -0,0 +1,96 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestCwebpArgs_Render(t *testing.T) {
+	config := domain.DefaultCompressionConfig(40)
+
+	args, err := newCwebpArgs(config, "in.webp", "out.webp").Render()
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := "-q 40 -m 6 -preset photo -mt -f 100 -sharpness 0 -sns 100 -segments 4 -pass 10 -alpha_q 20 -size 0 -metadata none in.webp -o out.webp"
+	if got := strings.Join(args, " "); got != expected {
+		t.Errorf("Unexpected args:\n got: %s\nwant: %s", got, expected)
+	}
+
+	config.Lossless = true
+	args, _ = newCwebpArgs(config, "in.webp", "out.webp").Render()
+	if args[0] != "-lossless" {
+		t.Errorf("Expected -lossless first, got %v", args)
+	}
+}
+
+func TestCwebpArgs_ValidateRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *cwebpArgs)
+	}{
+		{"quality", func(a *cwebpArgs) { a.Quality = 101 }},
+		{"method", func(a *cwebpArgs) { a.Method = 7 }},
+		{"segments", func(a *cwebpArgs) { a.Segments = 0 }},
+		{"sharpness", func(a *cwebpArgs) { a.Sharpness = 8 }},
+		{"preset", func(a *cwebpArgs) { a.Preset = "cartoon" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := newCwebpArgs(domain.DefaultCompressionConfig(50), "in.webp", "out.webp")
+			tt.modify(args)
+			if _, err := args.Render(); !errors.IsCode(err, "INVALID_TOOL_ARGS") {
+				t.Errorf("Expected INVALID_TOOL_ARGS, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebpmuxAssembleArgs_Validate(t *testing.T) {
+	args := &webpmuxAssembleArgs{
+		Frames: []webpmuxFrame{{File: "a.webp", Duration: 100, X: 3, Y: 0}},
+		Output: "out.webp",
+	}
+	if _, err := args.Render(); !errors.IsCode(err, "INVALID_TOOL_ARGS") {
+		t.Errorf("Expected odd offset to be rejected, got %v", err)
+	}
+
+	args.Frames[0].X = 2
+	args.Loop = 70000
+	if _, err := args.Render(); !errors.IsCode(err, "INVALID_TOOL_ARGS") {
+		t.Errorf("Expected loop out of range to be rejected, got %v", err)
+	}
+
+	args.Loop = 1
+	rendered, err := args.Render()
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got := strings.Join(rendered, " "); got != "-frame a.webp +100+2+0+0-b -loop 1 -o out.webp" {
+		t.Errorf("Unexpected args: %s", got)
+	}
+}
+
+func TestFFmpegWebPArgs_Render(t *testing.T) {
+	config := domain.DefaultCompressionConfig(40)
+
+	args, err := newFFmpegWebPArgs("libwebp", config, "in.webp", "out.webp").Render()
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := "-hide_banner -loglevel error -y -i in.webp -frames:v 1 -c:v libwebp -lossless 0 -quality 40 -compression_level 6 -preset photo -f webp out.webp"
+	if got := strings.Join(args, " "); got != expected {
+		t.Errorf("Unexpected args:\n got: %s\nwant: %s", got, expected)
+	}
+
+	config.Method = 7
+	if _, err := newFFmpegWebPArgs("libwebp", config, "in.webp", "out.webp").Render(); !errors.IsCode(err, "INVALID_TOOL_ARGS") {
+		t.Errorf("Expected INVALID_TOOL_ARGS, got %v", err)
+	}
+}
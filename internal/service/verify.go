@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// 输出校验模式
+const (
+	verifyOff    = "off"
+	verifyWarn   = "warn"
+	verifyStrict = "strict"
+)
+
+// checkOutput 按配置的校验模式校验输出，warn模式下只记录警告
+func (s *WebPService) checkOutput(ctx context.Context, outputPath string, expected *domain.AnimationInfo) error {
+	mode := s.config.Processing.VerifyOutput
+	if mode == verifyOff {
+		return nil
+	}
+
+	err := s.verifyOutput(ctx, outputPath, expected)
+	if err == nil {
+		s.logger.Debug("输出校验通过", "output", outputPath)
+		return nil
+	}
+
+	if mode == verifyStrict {
+		return err
+	}
+	s.logger.Warn("输出校验未通过", "output", outputPath, "error", err)
+	return nil
+}
+
+// verifyOutput 用webpinfo检查输出码流，再比较帧数、画布尺寸和逐帧时长；
+// webpinfo不在ValidateTools要求的工具之列，缺少时跳过码流检查，其余比较照常进行
+func (s *WebPService) verifyOutput(ctx context.Context, outputPath string, expected *domain.AnimationInfo) error {
+	if !s.toolExecutor.IsToolAvailable("webpinfo") {
+		s.logger.Debug("webpinfo不可用，跳过码流校验", "output", outputPath)
+	} else if _, err := s.toolExecutor.ExecuteCommandWithOutput(ctx, "webpinfo", "-quiet", outputPath); err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExecution, "INVALID_OUTPUT", "webpinfo校验输出失败")
+	}
+
+	actual, err := s.ParseAnimation(ctx, outputPath)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeExecution, "INVALID_OUTPUT", "解析输出动画失败")
+	}
+
+	if actual.Width != expected.Width || actual.Height != expected.Height {
+		return errors.New(errors.ErrorTypeExecution, "OUTPUT_MISMATCH",
+			fmt.Sprintf("输出画布尺寸不一致: 期望 %dx%d, 实际 %dx%d",
+				expected.Width, expected.Height, actual.Width, actual.Height))
+	}
+
+	if len(actual.Frames) != len(expected.Frames) {
+		return errors.New(errors.ErrorTypeExecution, "OUTPUT_MISMATCH",
+			fmt.Sprintf("输出帧数不一致: 期望 %d, 实际 %d", len(expected.Frames), len(actual.Frames)))
+	}
+
+	for i, frame := range expected.Frames {
+		want := frame.Duration.Round(time.Millisecond)
+		got := actual.Frames[i].Duration.Round(time.Millisecond)
+		if want != got {
+			return errors.New(errors.ErrorTypeExecution, "OUTPUT_MISMATCH",
+				fmt.Sprintf("第%d帧时长不一致: 期望 %v, 实际 %v", i+1, want, got))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+const verifyTestOutputInfo = `Canvas size: 288 x 288
+Loop Count : 0
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:    288    288   yes         0        0       50    none    no        172      lossy
+  2:    288    288   yes         0        0       70    none    no        518      lossy`
+
+func TestVerifyOutput_Matches(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info out.webp", verifyTestOutputInfo)
+
+	expected := &domain.AnimationInfo{
+		Width:  288,
+		Height: 288,
+		Frames: []*domain.FrameInfo{
+			{Index: 1, Duration: 50 * time.Millisecond},
+			{Index: 2, Duration: 70 * time.Millisecond},
+		},
+	}
+
+	if err := service.verifyOutput(context.Background(), "out.webp", expected); err != nil {
+		t.Errorf("Expected verification to pass, got %v", err)
+	}
+}
+
+func TestVerifyOutput_SkipsBitstreamWithoutWebpinfo(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetToolUnavailable("webpinfo")
+	mockToolExecutor.SetMockError("webpinfo -quiet out.webp", errors.ErrToolNotFound)
+	mockToolExecutor.SetMockOutput("webpmux -info out.webp", verifyTestOutputInfo)
+
+	expected := &domain.AnimationInfo{
+		Width:  288,
+		Height: 288,
+		Frames: []*domain.FrameInfo{
+			{Index: 1, Duration: 50 * time.Millisecond},
+			{Index: 2, Duration: 70 * time.Millisecond},
+		},
+	}
+	if err := service.verifyOutput(context.Background(), "out.webp", expected); err != nil {
+		t.Errorf("Expected verification to pass without webpinfo, got %v", err)
+	}
+	for _, command := range mockToolExecutor.commands {
+		if strings.HasPrefix(command, "webpinfo") {
+			t.Errorf("Expected webpinfo to be skipped, ran %q", command)
+		}
+	}
+
+	// 其余比较照常进行
+	expected.Frames = expected.Frames[:1]
+	if err := service.verifyOutput(context.Background(), "out.webp", expected); !errors.IsCode(err, "OUTPUT_MISMATCH") {
+		t.Errorf("Expected OUTPUT_MISMATCH, got %v", err)
+	}
+}
+
+func TestCheckOutput_StrictFailsOnDurationMismatch(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info out.webp", verifyTestOutputInfo)
+
+	expected := &domain.AnimationInfo{
+		Width:  288,
+		Height: 288,
+		Frames: []*domain.FrameInfo{
+			{Index: 1, Duration: 50 * time.Millisecond},
+			{Index: 2, Duration: 100 * time.Millisecond},
+		},
+	}
+
+	service.config.Processing.VerifyOutput = "warn"
+	if err := service.checkOutput(context.Background(), "out.webp", expected); err != nil {
+		t.Errorf("Expected warn mode to tolerate mismatch, got %v", err)
+	}
+
+	service.config.Processing.VerifyOutput = "strict"
+	err := service.checkOutput(context.Background(), "out.webp", expected)
+	if !errors.IsCode(err, "OUTPUT_MISMATCH") {
+		t.Errorf("Expected OUTPUT_MISMATCH, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// supportedChunks 处理管线能够重现的顶层RIFF块，元数据块虽不保留但不影响画面
+var supportedChunks = map[string]bool{
+	"VP8X": true, "ANIM": true, "ANMF": true, "ALPH": true, "VP8 ": true, "VP8L": true,
+	"ICCP": true, "EXIF": true, "XMP ": true,
+}
+
+// supportedFrameChunks ANMF帧内可以出现的子块
+var supportedFrameChunks = map[string]bool{"ALPH": true, "VP8 ": true, "VP8L": true}
+
+// leadingChunks WebP文件的第一个块只能是这些类型之一
+var leadingChunks = map[string]bool{"VP8 ": true, "VP8L": true, "VP8X": true}
+
+// anmfHeaderSize ANMF块中帧位置、尺寸、时长和标志占用的字节数，其后为帧数据子块
+const anmfHeaderSize = 16
+
+// scanUnsupportedFeatures 遍历WebP文件的RIFF块，返回处理管线无法重现的块类型（如FRGM分片），按出现顺序去重
+//
+// 不是RIFF/WEBP文件时返回nil，交由工具报告错误
+func scanUnsupportedFeatures(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return nil, nil
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return nil, nil
+	}
+	end := int64(binary.LittleEndian.Uint32(header[4:8])) + 8
+
+	var unsupported []string
+	seen := make(map[string]bool)
+	report := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			unsupported = append(unsupported, id)
+		}
+	}
+
+	err = walkChunks(file, 12, end, func(id string, offset, size int64) error {
+		if !supportedChunks[id] {
+			report(id)
+			return nil
+		}
+		if id != "ANMF" || size < anmfHeaderSize {
+			return nil
+		}
+		return walkChunks(file, offset+anmfHeaderSize, offset+size, func(id string, _, _ int64) error {
+			if !supportedFrameChunks[id] {
+				report("ANMF/" + id)
+			}
+			return nil
+		})
+	})
+	return unsupported, err
+}
+
+// sniffWebP 按文件头而不是扩展名判断文件是否为WebP：RIFF/WEBP容器且第一个块为VP8、VP8L或VP8X
+func sniffWebP(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	var header [16]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP" && leadingChunks[string(header[12:16])], nil
+}
+
+// walkChunks 依次读取[start, end)范围内的块头，visit收到块类型、数据偏移和数据长度
+func walkChunks(r io.ReaderAt, start, end int64, visit func(id string, offset, size int64) error) error {
+	var header [8]byte
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return fmt.Errorf("读取偏移%d处的块头失败: %w", offset, err)
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		if offset+8+size > end {
+			return fmt.Errorf("块%q长度%d超出容器范围", id, size)
+		}
+		if err := visit(id, offset+8, size); err != nil {
+			return err
+		}
+		// 块数据按偶数字节对齐
+		offset += 8 + size + size%2
+	}
+	return nil
+}
+
+// checkInputFeatures 检查输入是否为WebP文件以及是否含有处理管线无法重现的特性
+//
+// 文件头不是WebP时返回INVALID_WEBP_FORMAT，改名的可执行文件或其他数据不会进入处理管线；
+// 含有无法重现的特性时按Processing.UnsupportedPolicy拒绝(返回UNSUPPORTED_WEBP_FEATURE)或原样复制到输出；
+// 无法读取文件时只记录日志
+func (s *WebPService) checkInputFeatures(inputPath, outputPath string) (*domain.CompressResult, error) {
+	isWebP, err := sniffWebP(inputPath)
+	if err != nil {
+		s.logger.Debug("检查输入特性失败，交由工具处理", "file", inputPath, "error", err)
+		return nil, nil
+	}
+	if !isWebP {
+		return nil, errors.New(errors.ErrorTypeValidation, "INVALID_WEBP_FORMAT", "输入不是WebP文件").
+			WithContext("file", inputPath)
+	}
+
+	features, err := scanUnsupportedFeatures(inputPath)
+	if err != nil {
+		s.logger.Debug("检查输入特性失败，交由工具处理", "file", inputPath, "error", err)
+	}
+	if len(features) == 0 {
+		return nil, nil
+	}
+
+	if s.config.Processing.UnsupportedPolicy != "passthrough" {
+		return nil, errors.New(errors.ErrorTypeValidation, "UNSUPPORTED_WEBP_FEATURE",
+			fmt.Sprintf("输入含有处理管线无法重现的特性: %v", features)).
+			WithContext("file", inputPath).
+			WithDetails("可将WEBP_UNSUPPORTED_POLICY设为passthrough原样输出")
+	}
+
+	s.logger.Warn("输入含有处理管线无法重现的特性，原样复制", "file", inputPath, "features", features)
+	if err := s.fileManager.CopyFile(inputPath, outputPath); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "COPY_OUTPUT", "原样复制输入失败")
+	}
+	return &domain.CompressResult{
+		Fallback:            domain.FallbackPassthrough,
+		UnsupportedFeatures: features,
+	}, nil
+}
+
+// checkInputLimits 按RIFF块读取的帧数和画布尺寸检查输入是否超出配置的上限，在启动任何工具之前拒绝过大的输入
+//
+// 解码总字节数按每帧一个RGBA画布估算；无法读取文件时不检查，交由工具处理
+func (s *WebPService) checkInputLimits(inputPath string) error {
+	characteristics, err := readInputCharacteristics(inputPath)
+	if err != nil {
+		s.logger.Debug("读取输入特征失败，跳过输入上限检查", "file", inputPath, "error", err)
+		return nil
+	}
+
+	processing := s.config.Processing
+	frames := int64(characteristics.Frames)
+	pixels := int64(characteristics.Width) * int64(characteristics.Height)
+
+	if processing.MaxFrames > 0 && characteristics.Frames > processing.MaxFrames {
+		return errors.New(errors.ErrorTypeValidation, "INPUT_TOO_MANY_FRAMES",
+			fmt.Sprintf("输入帧数超过上限: %d > %d", characteristics.Frames, processing.MaxFrames)).
+			WithContext("file", inputPath).
+			WithDetails("可通过WEBP_MAX_FRAMES调整上限")
+	}
+	if processing.MaxCanvasPixels > 0 && pixels > processing.MaxCanvasPixels {
+		return errors.New(errors.ErrorTypeValidation, "INPUT_CANVAS_TOO_LARGE",
+			fmt.Sprintf("输入画布超过上限: %dx%d (%d像素) > %d像素",
+				characteristics.Width, characteristics.Height, pixels, processing.MaxCanvasPixels)).
+			WithContext("file", inputPath).
+			WithDetails("可通过WEBP_MAX_CANVAS_PIXELS调整上限")
+	}
+	if decoded := frames * pixels * 4; processing.MaxDecodedBytes > 0 && decoded > processing.MaxDecodedBytes {
+		return errors.New(errors.ErrorTypeValidation, "INPUT_DECODED_TOO_LARGE",
+			fmt.Sprintf("输入解码后的总大小超过上限: %d帧 x %dx%d = %s > %s",
+				characteristics.Frames, characteristics.Width, characteristics.Height,
+				formatFileSize(decoded), formatFileSize(processing.MaxDecodedBytes))).
+			WithContext("file", inputPath).
+			WithDetails("可通过WEBP_MAX_DECODED_MB调整上限")
+	}
+	return nil
+}
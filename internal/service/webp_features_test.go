@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+// riffChunk 生成一个RIFF块，奇数长度补齐一个字节
+func riffChunk(id string, payload []byte) []byte {
+	chunk := make([]byte, 8, 8+len(payload)+1)
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// writeTestWebP 按给定的块写出WebP容器
+func writeTestWebP(t *testing.T, chunks ...[]byte) string {
+	t.Helper()
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	data := make([]byte, 8, 8+len(body))
+	copy(data, "RIFF")
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(body)))
+	data = append(data, body...)
+
+	path := filepath.Join(t.TempDir(), "input.webp")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write webp: %v", err)
+	}
+	return path
+}
+
+// anmfChunk 生成帧头后跟随给定子块的ANMF块
+func anmfChunk(subChunks ...[]byte) []byte {
+	payload := make([]byte, anmfHeaderSize)
+	for _, sub := range subChunks {
+		payload = append(payload, sub...)
+	}
+	return riffChunk("ANMF", payload)
+}
+
+func TestScanUnsupportedFeatures(t *testing.T) {
+	plain := writeTestWebP(t,
+		riffChunk("VP8X", make([]byte, 10)),
+		riffChunk("ANIM", make([]byte, 6)),
+		anmfChunk(riffChunk("ALPH", []byte{1, 2, 3}), riffChunk("VP8 ", make([]byte, 5))),
+		riffChunk("EXIF", []byte{1}),
+	)
+	if features, err := scanUnsupportedFeatures(plain); err != nil || features != nil {
+		t.Errorf("Expected no unsupported features, got %v (%v)", features, err)
+	}
+
+	fragmented := writeTestWebP(t,
+		riffChunk("VP8X", make([]byte, 10)),
+		riffChunk("FRGM", make([]byte, 4)),
+		anmfChunk(riffChunk("VP8L", make([]byte, 4)), riffChunk("UNKN", nil)),
+		riffChunk("FRGM", make([]byte, 4)),
+	)
+	features, err := scanUnsupportedFeatures(fragmented)
+	if err != nil || !reflect.DeepEqual(features, []string{"FRGM", "ANMF/UNKN"}) {
+		t.Errorf("Expected FRGM and ANMF/UNKN, got %v (%v)", features, err)
+	}
+}
+
+func TestCompressAnimation_UnsupportedFeaturePolicy(t *testing.T) {
+	input := writeTestWebP(t, riffChunk("VP8X", make([]byte, 10)), riffChunk("FRGM", make([]byte, 4)))
+
+	service := createTestWebPService()
+	_, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(50))
+	if !errors.IsCode(err, "UNSUPPORTED_WEBP_FEATURE") {
+		t.Fatalf("Expected UNSUPPORTED_WEBP_FEATURE, got %v", err)
+	}
+
+	service.config.Processing.UnsupportedPolicy = "passthrough"
+	result, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(50))
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.Fallback != domain.FallbackPassthrough || !reflect.DeepEqual(result.UnsupportedFeatures, []string{"FRGM"}) {
+		t.Errorf("Expected passthrough of FRGM input, got %+v", result)
+	}
+	if commands := service.toolExecutor.(*MockToolExecutor).commands; len(commands) != 0 {
+		t.Errorf("Expected no tool runs, got %v", commands)
+	}
+}
+
+func TestCompressAnimation_RejectsNonWebP(t *testing.T) {
+	service := createTestWebPService()
+
+	renamed := filepath.Join(t.TempDir(), "setup.webp")
+	if err := os.WriteFile(renamed, append([]byte("MZ\x90\x00"), make([]byte, 60)...), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	wav := writeTestWebP(t)
+	if err := os.WriteFile(wav, []byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00"), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	for _, input := range []string{renamed, wav} {
+		_, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(50))
+		if !errors.IsCode(err, "INVALID_WEBP_FORMAT") {
+			t.Errorf("Expected INVALID_WEBP_FORMAT for %s, got %v", input, err)
+		}
+	}
+	if commands := service.toolExecutor.(*MockToolExecutor).commands; len(commands) != 0 {
+		t.Errorf("Expected no tool runs, got %v", commands)
+	}
+}
+
+func TestCompressAnimation_InputLimits(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[4], vp8x[5], vp8x[7] = 31, 1, 159 // 画布288x160
+	input := writeTestWebP(t, riffChunk("VP8X", vp8x), anmfChunk(), anmfChunk(), anmfChunk())
+
+	tests := []struct {
+		name  string
+		limit func(service *WebPService)
+		code  string
+	}{
+		{"frames", func(service *WebPService) { service.config.Processing.MaxFrames = 2 }, "INPUT_TOO_MANY_FRAMES"},
+		{"canvas", func(service *WebPService) { service.config.Processing.MaxCanvasPixels = 288*160 - 1 }, "INPUT_CANVAS_TOO_LARGE"},
+		{"decoded", func(service *WebPService) { service.config.Processing.MaxDecodedBytes = 288 * 160 * 4 * 2 }, "INPUT_DECODED_TOO_LARGE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := createTestWebPService()
+			tt.limit(service)
+
+			_, err := service.CompressAnimation(context.Background(), input, "out.webp", domain.DefaultCompressionConfig(50))
+			if !errors.IsCode(err, tt.code) {
+				t.Fatalf("Expected %s, got %v", tt.code, err)
+			}
+			if commands := service.toolExecutor.(*MockToolExecutor).commands; len(commands) != 0 {
+				t.Errorf("Expected no tool runs, got %v", commands)
+			}
+		})
+	}
+}
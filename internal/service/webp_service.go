@@ -4,10 +4,10 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"webpcompressor/internal/config"
@@ -22,6 +22,12 @@ type WebPService struct {
 	toolExecutor domain.ToolExecutor
 	fileManager  domain.FileManager
 	logger       logger.Logger
+	encoders     map[string]domain.FrameEncoder
+	cpuProfile   *cpuProfile
+	governor     *resourceGovernor
+	historyMu    sync.Mutex
+	experimentMu sync.Mutex
+	statsMu      sync.Mutex
 }
 
 // NewWebPService 创建WebP服务
@@ -31,12 +37,18 @@ func NewWebPService(
 	fileManager domain.FileManager,
 	logger logger.Logger,
 ) *WebPService {
-	return &WebPService{
+	s := &WebPService{
 		config:       cfg,
 		toolExecutor: toolExecutor,
 		fileManager:  fileManager,
 		logger:       logger,
+		encoders:     make(map[string]domain.FrameEncoder),
+		cpuProfile:   &cpuProfile{},
+		governor:     newResourceGovernor(cfg.Advanced.PerformanceConfig),
 	}
+	s.RegisterEncoder(newCwebpEncoder(toolExecutor))
+	s.RegisterEncoder(newFFmpegEncoder(toolExecutor, logger, cfg.Tools.FFmpegCodec))
+	return s
 }
 
 // CompressAnimation 压缩WebP动画
@@ -56,44 +68,104 @@ func (s *WebPService) CompressAnimation(ctx context.Context, inputPath, outputPa
 		return nil, err
 	}
 
-	// 获取原始文件大小
-	originalSize, err := s.fileManager.GetFileSize(inputPath)
-	if err != nil {
-		err = errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取文件大小失败")
+	// 帧数、画布或解码总大小超出上限的输入在启动任何工具之前拒绝
+	if err := s.checkInputLimits(inputPath); err != nil {
 		opLogger.Error(err)
 		return nil, err
 	}
 
-	// 解析动画信息
-	animInfo, err := s.ParseAnimation(ctx, inputPath)
+	// 输入含有处理管线无法重现的特性时按策略拒绝或原样输出
+	passthrough, err := s.checkInputFeatures(inputPath, outputPath)
 	if err != nil {
 		opLogger.Error(err)
 		return nil, err
 	}
+	if passthrough != nil {
+		if size, err := s.fileManager.GetFileSize(inputPath); err == nil {
+			passthrough.OriginalSize = size
+			passthrough.CompressedSize = size
+		}
+		passthrough.ProcessingTime = time.Since(startTime)
+		passthrough.CalculateCompressionRatio()
+		opLogger.Success()
+		return passthrough, nil
+	}
+
+	// 指定努力程度时由预设统一决定压缩方法、遍数和并发数
+	if config.Effort > 0 {
+		config = s.applyEffort(config)
+	}
+
+	// 配置了历史记录文件时按首帧指纹查找相似输入的最佳设置，apply模式下直接使用
+	var history *inputHistory
+	if s.config.Processing.HistoryFile != "" {
+		history = s.lookupHistory(ctx, inputPath, config)
+		if history != nil && history.suggested != nil && config.Learn == domain.LearnApply {
+			config = applyLearnedSettings(config, history.suggested)
+			if err := s.validateInput(inputPath, outputPath, config); err != nil {
+				opLogger.Error(err)
+				return nil, err
+			}
+		}
+	}
 
-	// 创建临时目录
-	tempDir, err := s.fileManager.CreateTempDir("webp_compress")
-	if err != nil {
-		err = errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
-		opLogger.Error(err)
-		return nil, err
+	// 配置了A/B实验时按比例把任务分入实验组
+	experiment, config := s.assignExperiment(inputPath, outputPath, config)
+
+	// 配置了缓存目录时，相同输入和设置直接复用之前的输出，不计入历史记录和实验指标
+	var cacheKey string
+	if s.config.Processing.CacheDir != "" {
+		key, err := s.resultCacheKey(inputPath, config)
+		if err != nil {
+			s.logger.Warn("计算缓存键失败，不使用缓存", "file", inputPath, "error", err)
+		} else if cached := s.lookupCachedResult(key, outputPath); cached != nil {
+			cached.ProcessingTime = time.Since(startTime)
+			if history != nil {
+				cached.Suggested = history.suggested
+			}
+			cached.ExperimentGroup = experiment
+			opLogger.Success()
+			s.logger.Info("使用缓存的压缩结果", "key", key, "compressed_size", formatFileSize(cached.CompressedSize))
+			return cached, nil
+		} else {
+			cacheKey = key
+		}
 	}
-	defer s.fileManager.CleanupTempDir(tempDir)
 
-	// 提取帧
-	if err := s.ExtractFrames(ctx, inputPath, tempDir, animInfo.Frames); err != nil {
+	// 检查所选编码后端可用
+	if err := s.ensureEncoderAvailable(ctx, config); err != nil {
 		opLogger.Error(err)
 		return nil, err
 	}
 
-	// 压缩帧
-	if err := s.CompressFrames(ctx, animInfo.Frames, config); err != nil {
+	// 获取原始文件大小
+	originalSize, err := s.fileManager.GetFileSize(inputPath)
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取文件大小失败")
 		opLogger.Error(err)
 		return nil, err
 	}
 
-	// 重新组装动画
-	if err := s.AssembleAnimation(ctx, animInfo.Frames, outputPath); err != nil {
+	// 此后启动的工具子进程按任务声明的优先级运行
+	if config.Priority != "" {
+		ctx = domain.WithToolPriority(ctx, config.Priority)
+	}
+
+	// 按所选处理管线压缩，--best时两种管线都尝试并保留较小的结果
+	var result *domain.CompressResult
+	if config.Best {
+		result, err = s.compressBest(ctx, inputPath, outputPath, config)
+	} else {
+		result, err = s.compressWithPipeline(ctx, config.Pipeline, inputPath, outputPath, config)
+	}
+	if err != nil && config.Fallback {
+		result, err = s.compressWithFallbacks(ctx, inputPath, outputPath, config, err)
+	}
+	if err != nil {
+		if experiment != "" {
+			s.recordExperiment(experiment, inputPath, nil, time.Since(startTime), err)
+		}
+		s.recordStats(inputPath, config, nil, time.Since(startTime), err)
 		opLogger.Error(err)
 		return nil, err
 	}
@@ -105,27 +177,33 @@ func (s *WebPService) CompressAnimation(ctx context.Context, inputPath, outputPa
 		compressedSize = 0
 	}
 
-	// 计算使用的并行工作者数量
-	parallelWorkers := 1 // 默认顺序处理
-	if config.EnableParallel && len(animInfo.Frames) > 1 {
-		maxWorkers := config.MaxConcurrency
-		if maxWorkers <= 0 {
-			maxWorkers = s.config.App.MaxConcurrency
-		}
-		if maxWorkers > len(animInfo.Frames) {
-			maxWorkers = len(animInfo.Frames)
+	result.OriginalSize = originalSize
+	result.CompressedSize = compressedSize
+	result.ProcessingTime = time.Since(startTime)
+	result.CalculateCompressionRatio()
+
+	// 估算失败不影响压缩结果
+	if config.GIFEstimate {
+		if size, err := s.estimateGIFSize(ctx, inputPath); err != nil {
+			s.logger.Warn("估算等价GIF大小失败", "error", err)
+		} else {
+			result.EstimatedGIFSize = size
 		}
-		parallelWorkers = maxWorkers
 	}
 
-	result := &domain.CompressResult{
-		OriginalSize:    originalSize,
-		CompressedSize:  compressedSize,
-		ProcessingTime:  time.Since(startTime),
-		FramesProcessed: len(animInfo.Frames),
-		ParallelWorkers: parallelWorkers,
+	// 回退策略的结果可能来自暂时性故障，不写入缓存
+	if cacheKey != "" && result.Fallback == "" {
+		s.storeCachedResult(cacheKey, outputPath, result)
 	}
-	result.CalculateCompressionRatio()
+	if history != nil {
+		result.Suggested = history.suggested
+		s.recordHistory(history, config, result)
+	}
+	if experiment != "" {
+		result.ExperimentGroup = experiment
+		s.recordExperiment(experiment, inputPath, result, result.ProcessingTime, nil)
+	}
+	s.recordStats(inputPath, config, result, result.ProcessingTime, nil)
 
 	opLogger.Success()
 
@@ -136,11 +214,248 @@ func (s *WebPService) CompressAnimation(ctx context.Context, inputPath, outputPa
 		"frames", result.FramesProcessed,
 		"duration", result.ProcessingTime,
 		"parallel_workers", result.ParallelWorkers,
+		"pipeline", result.Pipeline,
 	)
 
 	return result, nil
 }
 
+// compressWithPipeline 按指定处理管线压缩，返回不含文件大小和耗时的结果
+func (s *WebPService) compressWithPipeline(ctx context.Context, pipeline, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	if pipeline == domain.PipelineImg2webp {
+		return s.compressWithImg2webp(ctx, inputPath, outputPath, config)
+	}
+	return s.compressWithWebpmux(ctx, inputPath, outputPath, config)
+}
+
+// collectFrameStats 汇总各帧的压缩统计
+func collectFrameStats(frames []*domain.FrameInfo) []domain.FrameStat {
+	stats := make([]domain.FrameStat, len(frames))
+	for i, frame := range frames {
+		stats[i] = domain.FrameStat{
+			Index:          frame.Index,
+			Duration:       frame.Duration,
+			OriginalSize:   frame.OriginalSize,
+			CompressedSize: frame.CompressedSize,
+			CompressTime:   frame.CompressTime,
+			PSNR:           frame.PSNR,
+			SSIM:           frame.SSIM,
+			Kept:           frame.Kept,
+		}
+	}
+	return stats
+}
+
+// countKeptFrames 统计保留原始数据的帧数
+func countKeptFrames(frames []*domain.FrameInfo) int {
+	kept := 0
+	for _, frame := range frames {
+		if frame.Kept {
+			kept++
+		}
+	}
+	return kept
+}
+
+// frameSelection 表示按配置裁剪、去重、降帧后的帧
+type frameSelection struct {
+	frames     []*domain.FrameInfo
+	fullCanvas bool // 帧已提取为完整画布
+	trimmed    int
+	merged     int
+	dropped    int
+}
+
+// selectFrames 按配置依次裁剪帧范围、合并重复帧和降帧
+func (s *WebPService) selectFrames(ctx context.Context, inputPath, tempDir string, animInfo *domain.AnimationInfo, config *domain.CompressionConfig) (*frameSelection, error) {
+	selection := &frameSelection{frames: animInfo.Frames}
+
+	// 按帧范围裁剪
+	if config.HasFrameRange() {
+		frames, trimmed, err := selectFrameRange(selection.frames, config)
+		if err != nil {
+			return nil, err
+		}
+		selection.frames, selection.trimmed = frames, trimmed
+		s.logger.Info("帧范围裁剪完成", "kept", len(frames), "trimmed", trimmed)
+	}
+
+	// 合并重复帧（使用完整画布帧）
+	if config.Deduplicate {
+		frames, merged, err := s.deduplicateFrames(ctx, inputPath, tempDir, animInfo, selection.frames)
+		if err != nil {
+			return nil, err
+		}
+		selection.frames, selection.merged = frames, merged
+		selection.fullCanvas = true
+	}
+
+	// 降帧
+	if config.MaxFPS > 0 || config.DropEveryN > 1 {
+		selection.frames, selection.dropped = reduceFrameRate(selection.frames, config.MaxFPS, config.DropEveryN)
+		s.logger.Info("降帧完成", "kept", len(selection.frames), "dropped", selection.dropped)
+	}
+
+	return selection, nil
+}
+
+// compressWithWebpmux 逐帧提取、压缩后用webpmux重新组装
+func (s *WebPService) compressWithWebpmux(ctx context.Context, inputPath, outputPath string, config *domain.CompressionConfig) (*domain.CompressResult, error) {
+	startTime := time.Now()
+
+	// 创建临时目录
+	tempDir, err := s.fileManager.CreateTempDir("webp_compress")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeIO, "CREATE_TEMP_DIR", "创建临时目录失败")
+	}
+	defer s.fileManager.CleanupTempDir(tempDir)
+
+	// 不需要先拿到完整帧列表时，边解析边按阶段提取和压缩帧
+	needsFullFrameList := config.Deduplicate || config.MaxFPS > 0 || config.DropEveryN > 1 || config.HasFrameRange() || config.Deadline > 0
+	builder := newAssemblyManifestBuilder()
+	builder.limitOutputSize(s.outputSizeLimit(inputPath))
+
+	// 解析动画信息
+	var animInfo *domain.AnimationInfo
+	if needsFullFrameList {
+		animInfo, err = s.ParseAnimation(ctx, inputPath)
+	} else {
+		animInfo, err = s.parseAndProcessFrames(ctx, inputPath, tempDir, config, builder)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := s.selectFrames(ctx, inputPath, tempDir, animInfo, config)
+	if err != nil {
+		return nil, err
+	}
+	frames := selection.frames
+
+	if needsFullFrameList {
+		// 去重时已提取完整画布帧；需要完整画布帧时由anim_dump一次提取，其余情况逐帧提取并与压缩重叠
+		extractProgress := newProgressReporter(config.Progress, domain.StageExtract, len(frames))
+		extractPerFrame := false
+		switch {
+		case selection.fullCanvas:
+			extractProgress.finish()
+		case needsFullCanvasFrames(animInfo, selection.dropped+selection.trimmed > 0):
+			s.logger.Info("动画帧依赖前一帧画面，改用完整画布帧", "frames", len(frames))
+			stopHeartbeat := extractProgress.heartbeat("")
+			err := s.extractFullCanvasFrames(ctx, inputPath, tempDir, animInfo, frames)
+			stopHeartbeat()
+			if err != nil {
+				return nil, err
+			}
+			extractProgress.finish()
+		default:
+			extractPerFrame = true
+		}
+
+		// 指定截止时间时先试编码几帧，选择来得及完成的最高压缩方法
+		if config.Deadline > 0 {
+			method, err := s.selectMethodForDeadline(ctx, inputPath, tempDir, frames, config, config.Deadline-time.Since(startTime))
+			if err != nil {
+				return nil, err
+			}
+			tuned := *config
+			tuned.Method = method
+			config = &tuned
+		}
+
+		if err := s.processSelectedFrames(ctx, inputPath, tempDir, frames, config, builder, extractPerFrame, extractProgress); err != nil {
+			return nil, err
+		}
+	}
+
+	// 丢弃的失败帧不参与组装和统计，全部失败时返回汇总的错误
+	failures := builder.failures()
+	if len(failures) > 0 {
+		if len(failures) == len(frames) {
+			return nil, errors.Wrap(failures, errors.ErrorTypeExecution, "ALL_FRAMES_FAILED", "所有帧都处理失败")
+		}
+		s.logger.Warn("部分帧处理失败，已从输出中丢弃", "failed", len(failures), "total", len(frames))
+		frames = keptFrames(frames, failures)
+	}
+
+	// 按组装清单重新组装动画并保留原动画的循环次数，清单同时写入临时目录便于排查
+	manifest := builder.build(outputPath, animInfo.LoopCount)
+
+	// 帧区域超出画布时按配置移回画布内或报错
+	repositioned, err := fitManifestToCanvas(manifest, animInfo.Width, animInfo.Height, s.config.Processing.FrameBoundsPolicy == "fix")
+	if err != nil {
+		return nil, err
+	}
+	if repositioned > 0 {
+		s.logger.Warn("帧区域超出画布，已移回画布内", "frames", repositioned)
+	}
+
+	// 字节相同的压缩帧引用同一文件，相邻且可安全合并的重复帧并入前一帧
+	duplicates, merged := dedupeManifest(manifest)
+	if duplicates > 0 {
+		s.logger.Info("发现压缩结果相同的帧", "duplicates", duplicates, "merged", merged)
+	}
+	assembleProgress := newProgressReporter(config.Progress, domain.StageAssemble, len(manifest.Frames))
+	assembleCtx, stopWatch := watchOutputSize(ctx, outputPath, s.outputSizeLimit(inputPath))
+	stopHeartbeat := assembleProgress.heartbeat(outputPath)
+	err = s.assembleFromManifest(assembleCtx, manifest, tempDir)
+	stopHeartbeat()
+	if sizeErr := stopWatch(); sizeErr != nil {
+		return nil, sizeErr
+	}
+	if err != nil {
+		// 临时目录即将被清理，按配置先保存诊断包
+		if s.config.Processing.DiagnosticsDir != "" {
+			if bundlePath, bundleErr := s.writeDiagnosticsBundle(ctx, tempDir, inputPath, config, manifest, err); bundleErr != nil {
+				s.logger.Warn("生成诊断包失败", "error", bundleErr)
+			} else {
+				s.logger.Info("已生成诊断包", "path", bundlePath)
+				if appErr, ok := err.(*errors.AppError); ok {
+					err = appErr.WithContext("diagnostics", bundlePath)
+				}
+			}
+		}
+		return nil, err
+	}
+	assembleProgress.finish()
+
+	// 校验输出与源动画的画布、帧数和时长一致，时长以清单为准（含并入的丢弃帧时长）
+	expected := &domain.AnimationInfo{Width: animInfo.Width, Height: animInfo.Height, Frames: manifestFrames(manifest)}
+	if err := s.checkOutput(ctx, outputPath, expected); err != nil {
+		return nil, err
+	}
+
+	// 计算使用的并行工作者数量
+	parallelWorkers := 1 // 默认顺序处理
+	if config.EnableParallel && len(frames) > 1 {
+		maxWorkers := s.compressWorkers(config)
+		if maxWorkers > len(frames) {
+			maxWorkers = len(frames)
+		}
+		parallelWorkers = maxWorkers
+	}
+
+	result := &domain.CompressResult{
+		FramesProcessed:    len(frames),
+		FramesDropped:      selection.dropped,
+		FramesMerged:       selection.merged + merged,
+		FramesTrimmed:      selection.trimmed,
+		FramesFailed:       len(failures),
+		FramesKept:         countKeptFrames(frames),
+		ParallelWorkers:    parallelWorkers,
+		Pipeline:           domain.PipelineWebpmux,
+		FrameStats:         collectFrameStats(frames),
+		Method:             config.Method,
+		DuplicateFrames:    duplicates,
+		FramesRepositioned: repositioned,
+	}
+	if config.QualityReport {
+		result.Quality = summarizeQuality(frames)
+	}
+
+	return result, nil
+}
+
 // ParseAnimation 解析WebP动画信息
 func (s *WebPService) ParseAnimation(ctx context.Context, inputPath string) (*domain.AnimationInfo, error) {
 	s.logger.Debug("开始解析动画信息", "file", inputPath)
@@ -160,32 +475,40 @@ func (s *WebPService) ExtractFrames(ctx context.Context, inputPath string, outpu
 	progressLogger := logger.NewProgressLogger(s.logger, len(frames), "提取帧")
 
 	for i, frame := range frames {
-		frameOutput := filepath.Join(outputDir, fmt.Sprintf("frame_%d.webp", frame.Index))
+		if err := s.extractFrame(ctx, inputPath, outputDir, frame); err != nil {
+			return err
+		}
+		progressLogger.Update(i + 1)
+	}
 
-		err := s.toolExecutor.ExecuteCommand(ctx, "webpmux",
-			"-get", "frame", strconv.Itoa(frame.Index),
-			"-o", frameOutput, inputPath)
+	progressLogger.Finish()
+	return nil
+}
 
-		if err != nil {
-			return errors.Wrapf(err, errors.ErrorTypeExecution, "EXTRACT_FRAME",
-				"提取第%d帧失败", frame.Index)
-		}
+// extractFrame 提取单个帧
+func (s *WebPService) extractFrame(ctx context.Context, inputPath string, outputDir string, frame *domain.FrameInfo) error {
+	frameOutput := filepath.Join(outputDir, fmt.Sprintf("frame_%d.webp", frame.Index))
 
-		// 检查文件是否成功创建
-		if !s.fileManager.FileExists(frameOutput) {
-			return errors.New(errors.ErrorTypeExecution, "FRAME_NOT_CREATED",
-				fmt.Sprintf("第%d帧文件未成功创建: %s", frame.Index, frameOutput))
-		}
+	err := s.toolExecutor.ExecuteCommand(ctx, "webpmux",
+		"-get", "frame", strconv.Itoa(frame.Index),
+		"-o", frameOutput, inputPath)
 
-		frame.Path = frameOutput
-		s.logger.Debug("提取帧成功",
-			"index", frame.Index,
-			"output", frameOutput,
-		)
-		progressLogger.Update(i + 1)
+	if err != nil {
+		return errors.Wrapf(err, errors.ErrorTypeExecution, "EXTRACT_FRAME",
+			"提取第%d帧失败", frame.Index)
 	}
 
-	progressLogger.Finish()
+	// 检查文件是否成功创建
+	if !s.fileManager.FileExists(frameOutput) {
+		return errors.New(errors.ErrorTypeExecution, "FRAME_NOT_CREATED",
+			fmt.Sprintf("第%d帧文件未成功创建: %s", frame.Index, frameOutput))
+	}
+
+	frame.Path = frameOutput
+	s.logger.Debug("提取帧成功",
+		"index", frame.Index,
+		"output", frameOutput,
+	)
 	return nil
 }
 
@@ -206,10 +529,7 @@ func (s *WebPService) CompressFramesParallel(ctx context.Context, frames []*doma
 	)
 
 	// 限制并发数
-	maxWorkers := config.MaxConcurrency
-	if maxWorkers <= 0 {
-		maxWorkers = s.config.App.MaxConcurrency
-	}
+	maxWorkers := s.compressWorkers(config)
 	if maxWorkers > len(frames) {
 		maxWorkers = len(frames)
 	}
@@ -218,28 +538,37 @@ func (s *WebPService) CompressFramesParallel(ctx context.Context, frames []*doma
 	workerPool := domain.NewWorkerPool(maxWorkers)
 
 	// 创建帧处理器
-	frameProcessor := func(ctx context.Context, frame *domain.FrameInfo) error {
-		return s.compressFrame(ctx, frame, config)
-	}
+	progress := newProgressReporter(config.Progress, domain.StageCompress, len(frames))
+	frameProcessor := s.governed(func(ctx context.Context, frame *domain.FrameInfo) error {
+		if err := s.compressFrameWithRetries(ctx, frame, config); err != nil {
+			return err
+		}
+		progress.frameDone(frame.Index)
+		return nil
+	})
 
 	// 启动工作池
 	workerPool.Start(ctx, frameProcessor)
 
-	// 提交所有帧任务
+	// 提交所有帧任务，取消后停止提交
+	var submitErr error
 	for _, frame := range frames {
-		workerPool.Submit(frame)
+		if submitErr = workerPool.Submit(ctx, frame); submitErr != nil {
+			break
+		}
 	}
 
 	// 关闭任务队列
 	workerPool.Close()
 
-	// 等待所有任务完成
-	errors := workerPool.Wait()
-
-	// 检查是否有错误
-	if len(errors) > 0 {
-		s.logger.Error("并行压缩出现错误", "error_count", len(errors))
-		return errors[0] // 返回第一个错误
+	// 等待所有任务完成，失败帧的错误全部返回，成功的帧保留压缩结果
+	errs := workerPool.Wait()
+	if submitErr != nil {
+		return errors.Wrap(submitErr, errors.ErrorTypeExecution, "COMPRESS_CANCELLED", "并行压缩已取消")
+	}
+	if len(errs) > 0 {
+		s.logger.Error("并行压缩出现错误", "error_count", len(errs))
+		return errors.Wrap(errs, errors.ErrorTypeExecution, "COMPRESS_FRAMES", "并行压缩帧失败")
 	}
 
 	s.logger.Info("并行压缩完成",
@@ -255,12 +584,14 @@ func (s *WebPService) compressFramesSequential(ctx context.Context, frames []*do
 	s.logger.Info("开始顺序压缩帧", "total_frames", len(frames), "quality", config.Quality)
 
 	progressLogger := logger.NewProgressLogger(s.logger, len(frames), "压缩帧")
+	progress := newProgressReporter(config.Progress, domain.StageCompress, len(frames))
 
 	for i, frame := range frames {
-		if err := s.compressFrame(ctx, frame, config); err != nil {
+		if err := s.compressFrameWithRetries(ctx, frame, config); err != nil {
 			return err
 		}
 		progressLogger.Update(i + 1)
+		progress.frameDone(frame.Index)
 	}
 
 	progressLogger.Finish()
@@ -276,13 +607,26 @@ func (s *WebPService) compressFrame(ctx context.Context, frame *domain.FrameInfo
 	}
 
 	compressedPath := strings.Replace(frame.Path, "frame_", "frame_compressed_", 1)
+	compressedPath = strings.TrimSuffix(compressedPath, filepath.Ext(compressedPath)) + ".webp"
 
-	args := s.buildCompressionArgs(config, frame.Path, compressedPath)
-
-	err := s.toolExecutor.ExecuteCommand(ctx, "cwebp", args...)
+	originalSize, err := s.fileManager.GetFileSize(frame.Path)
 	if err != nil {
-		return errors.Wrapf(err, errors.ErrorTypeExecution, "COMPRESS_FRAME",
-			"压缩第%d帧失败", frame.Index)
+		return errors.Wrapf(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取第%d帧大小失败", frame.Index)
+	}
+	startTime := time.Now()
+
+	if keepOriginalFrame(frame, originalSize, config) {
+		// 小帧重新压缩收益有限，原样复制以便后续按压缩帧统一处理
+		if err := s.fileManager.CopyFile(frame.Path, compressedPath); err != nil {
+			return errors.Wrapf(err, errors.ErrorTypeIO, "COPY_FRAME", "保留第%d帧原始数据失败", frame.Index)
+		}
+		frame.Kept = true
+	} else if config.Mixed {
+		if err := s.compressFrameMixed(ctx, frame, compressedPath, config); err != nil {
+			return err
+		}
+	} else if err := s.compressFrameLossy(ctx, frame, compressedPath, config); err != nil {
+		return err
 	}
 
 	// 检查压缩后的文件是否成功创建
@@ -291,6 +635,18 @@ func (s *WebPService) compressFrame(ctx context.Context, frame *domain.FrameInfo
 			fmt.Sprintf("第%d帧压缩文件未成功创建: %s", frame.Index, compressedPath))
 	}
 
+	frame.CompressTime = time.Since(startTime)
+	frame.OriginalSize = originalSize
+	if frame.CompressedSize, err = s.fileManager.GetFileSize(compressedPath); err != nil {
+		return errors.Wrapf(err, errors.ErrorTypeIO, "GET_FILE_SIZE", "获取第%d帧压缩后大小失败", frame.Index)
+	}
+
+	if config.QualityReport {
+		if err := s.measureFrameQuality(ctx, frame, compressedPath); err != nil {
+			return err
+		}
+	}
+
 	frame.Path = compressedPath
 
 	s.logger.Debug("压缩帧成功",
@@ -301,142 +657,141 @@ func (s *WebPService) compressFrame(ctx context.Context, frame *domain.FrameInfo
 	return nil
 }
 
-// AssembleAnimation 重新组装动画
-func (s *WebPService) AssembleAnimation(ctx context.Context, frames []*domain.FrameInfo, outputPath string) error {
-	s.logger.Info("开始重新组装动画", "output", outputPath)
+// keepOriginalFrame 配置了重新压缩阈值且帧不超过阈值时保留原始帧，只有逐帧提取的WebP帧可以原样组装
+func keepOriginalFrame(frame *domain.FrameInfo, size int64, config *domain.CompressionConfig) bool {
+	return config.RecompressAbove > 0 && size <= config.RecompressAbove &&
+		strings.EqualFold(filepath.Ext(frame.Path), ".webp")
+}
 
-	// 确保输出目录存在
-	outputDir := filepath.Dir(outputPath)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return errors.Wrap(err, errors.ErrorTypeIO, "CREATE_OUTPUT_DIR",
-				fmt.Sprintf("创建输出目录失败: %s", outputDir))
-		}
-		s.logger.Debug("创建输出目录", "dir", outputDir)
+// compressFrameLossy 按固定质量或自动质量编码单帧
+func (s *WebPService) compressFrameLossy(ctx context.Context, frame *domain.FrameInfo, outputPath string, config *domain.CompressionConfig) error {
+	if config.AutoQuality {
+		return s.compressFrameAutoQuality(ctx, frame, outputPath, config)
 	}
+	return s.encodeFrame(ctx, frame, outputPath, config)
+}
 
-	// 验证所有帧文件是否存在
-	for _, frame := range frames {
-		if !s.fileManager.FileExists(frame.Path) {
-			return errors.New(errors.ErrorTypeIO, "FRAME_FILE_NOT_FOUND",
-				fmt.Sprintf("帧文件不存在: %s (索引: %d)", frame.Path, frame.Index))
-		}
-
-		// 检查文件大小
-		if size, err := s.fileManager.GetFileSize(frame.Path); err != nil {
-			s.logger.Warn("无法获取帧文件大小", "file", frame.Path, "error", err)
-		} else if size == 0 {
-			return errors.New(errors.ErrorTypeIO, "EMPTY_FRAME_FILE",
-				fmt.Sprintf("帧文件为空: %s (索引: %d)", frame.Path, frame.Index))
-		} else {
-			s.logger.Debug("帧文件验证通过",
-				"index", frame.Index,
-				"path", frame.Path,
-				"size", size,
-			)
-		}
-	}
+// AssembleAnimation 重新组装动画
+func (s *WebPService) AssembleAnimation(ctx context.Context, frames []*domain.FrameInfo, outputPath string) error {
+	return s.assembleAnimation(ctx, frames, outputPath, 0)
+}
 
-	args := []string{}
+// assembleAnimation 使用指定循环次数组装动画
+func (s *WebPService) assembleAnimation(ctx context.Context, frames []*domain.FrameInfo, outputPath string, loopCount int) error {
+	builder := newAssemblyManifestBuilder()
 	for _, frame := range frames {
-		blendStr := "-b"
-		if frame.Blend == domain.BlendYes {
-			blendStr = "+b"
-		}
-
-		// 正确的webpmux格式：file_i +di+xi+yi+mi+bi
-		// 文件路径和参数应该分别作为独立的参数
-		frameParams := fmt.Sprintf("+%d+%d+%d+%d%s",
-			int(frame.Duration/time.Millisecond),
-			frame.X, frame.Y,
-			int(frame.Dispose), blendStr)
-
-		args = append(args, "-frame", frame.Path, frameParams)
-
-		// 添加调试信息
-		s.logger.Debug("添加帧参数",
-			"index", frame.Index,
-			"path", frame.Path,
-			"frame_params", frameParams,
-			"duration_ms", int(frame.Duration/time.Millisecond),
-			"x", frame.X,
-			"y", frame.Y,
-			"dispose", int(frame.Dispose),
-			"blend", blendStr,
-		)
-	}
-	args = append(args, "-loop", "0", "-o", outputPath)
-
-	// 记录完整的命令
-	s.logger.Info("执行webpmux命令",
-		"args", strings.Join(args, " "),
-		"total_frames", len(frames),
-	)
-
-	err := s.toolExecutor.ExecuteCommand(ctx, "webpmux", args...)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrorTypeExecution, "ASSEMBLE_ANIMATION", "重新组装动画失败")
+		if err := s.stageFrame(builder, frame); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return s.assembleFromManifest(ctx, builder.build(outputPath, loopCount), "")
 }
 
 // parseWebpmuxOutput 解析webpmux输出
 func (s *WebPService) parseWebpmuxOutput(output string) (*domain.AnimationInfo, error) {
 	scanner := bufio.NewScanner(strings.NewReader(output))
+	parser := s.newWebpmuxInfoParser(nil)
 
-	animInfo := &domain.AnimationInfo{
-		Frames: make([]*domain.FrameInfo, 0),
+	for scanner.Scan() {
+		if parser.feed(scanner.Text()) {
+			break
+		}
 	}
 
-	startReading := false
+	return parser.finish()
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+// webpmuxInfoParser 逐行解析webpmux -info输出的状态机
+type webpmuxInfoParser struct {
+	service      *WebPService
+	animInfo     *domain.AnimationInfo
+	startReading bool
+	done         bool
+	onFrame      func(frame *domain.FrameInfo)
+}
 
-		// 解析画布大小
-		if strings.HasPrefix(line, "Canvas size:") {
-			if _, err := fmt.Sscanf(line, "Canvas size: %d x %d", &animInfo.Width, &animInfo.Height); err != nil {
-				s.logger.Warn("解析画布大小失败", "line", line)
-			}
-			continue
+// newWebpmuxInfoParser 创建解析器，onFrame在每解析出一帧时立即调用
+func (s *WebPService) newWebpmuxInfoParser(onFrame func(frame *domain.FrameInfo)) *webpmuxInfoParser {
+	return &webpmuxInfoParser{
+		service: s,
+		animInfo: &domain.AnimationInfo{
+			Frames: make([]*domain.FrameInfo, 0),
+		},
+		onFrame: onFrame,
+	}
+}
+
+// feed 处理一行输出，返回是否已读完帧表
+func (p *webpmuxInfoParser) feed(rawLine string) bool {
+	if p.done {
+		return true
+	}
+
+	s := p.service
+	animInfo := p.animInfo
+	line := strings.TrimSpace(rawLine)
+
+	// 解析画布大小
+	if strings.HasPrefix(line, "Canvas size:") {
+		if _, err := fmt.Sscanf(line, "Canvas size: %d x %d", &animInfo.Width, &animInfo.Height); err != nil {
+			s.logger.Warn("解析画布大小失败", "line", line)
 		}
+		return false
+	}
 
-		// 解析帧数
-		if strings.HasPrefix(line, "Number of frames:") {
-			if _, err := fmt.Sscanf(line, "Number of frames: %d", &animInfo.FrameCount); err != nil {
-				s.logger.Warn("解析帧数失败", "line", line)
-			}
-			continue
+	// 解析循环次数
+	if strings.HasPrefix(line, "Loop Count") {
+		if _, err := fmt.Sscanf(strings.TrimSpace(line[strings.Index(line, ":")+1:]), "%d", &animInfo.LoopCount); err != nil {
+			s.logger.Warn("解析循环次数失败", "line", line)
 		}
+		return false
+	}
 
-		// 检测表头
-		if strings.HasPrefix(line, "No.") && strings.Contains(line, "duration") {
-			startReading = true
-			continue
+	// 解析帧数
+	if strings.HasPrefix(line, "Number of frames:") {
+		if _, err := fmt.Sscanf(line, "Number of frames: %d", &animInfo.FrameCount); err != nil {
+			s.logger.Warn("解析帧数失败", "line", line)
 		}
+		return false
+	}
 
-		// 解析帧信息
-		if startReading {
-			if line == "" {
-				break
-			}
+	// 检测表头
+	if strings.HasPrefix(line, "No.") && strings.Contains(line, "duration") {
+		p.startReading = true
+		return false
+	}
 
-			frame, err := s.parseFrameLine(line)
-			if err != nil {
-				s.logger.Warn("解析帧信息失败", "line", line, "error", err)
-				continue
-			}
+	// 解析帧信息
+	if p.startReading {
+		if line == "" {
+			p.done = true
+			return true
+		}
+
+		frame, err := s.parseFrameLine(line)
+		if err != nil {
+			s.logger.Warn("解析帧信息失败", "line", line, "error", err)
+			return false
+		}
 
-			animInfo.Frames = append(animInfo.Frames, frame)
+		animInfo.Frames = append(animInfo.Frames, frame)
+		if p.onFrame != nil {
+			p.onFrame(frame)
 		}
 	}
 
+	return false
+}
+
+// finish 结束解析并校验结果
+func (p *webpmuxInfoParser) finish() (*domain.AnimationInfo, error) {
+	animInfo := p.animInfo
 	if len(animInfo.Frames) == 0 {
 		return nil, errors.New(errors.ErrorTypeValidation, "NO_FRAMES", "未能解析到任何帧")
 	}
 
-	s.logger.Debug("解析动画信息成功",
+	p.service.logger.Debug("解析动画信息成功",
 		"width", animInfo.Width,
 		"height", animInfo.Height,
 		"frames", len(animInfo.Frames),
@@ -455,6 +810,8 @@ func (s *WebPService) parseFrameLine(line string) (*domain.FrameInfo, error) {
 	// 解析各字段
 	indexStr := strings.TrimSuffix(fields[0], ":")
 	index, _ := strconv.Atoi(indexStr)
+	width, _ := strconv.Atoi(fields[1])      // width
+	height, _ := strconv.Atoi(fields[2])     // height
 	x, _ := strconv.Atoi(fields[4])          // x_offset
 	y, _ := strconv.Atoi(fields[5])          // y_offset
 	durationMs, _ := strconv.Atoi(fields[6]) // duration
@@ -473,6 +830,8 @@ func (s *WebPService) parseFrameLine(line string) (*domain.FrameInfo, error) {
 
 	return &domain.FrameInfo{
 		Index:    index,
+		Width:    width,
+		Height:   height,
 		X:        x,
 		Y:        y,
 		Duration: time.Duration(durationMs) * time.Millisecond,
@@ -482,36 +841,15 @@ func (s *WebPService) parseFrameLine(line string) (*domain.FrameInfo, error) {
 }
 
 // buildCompressionArgs 构建压缩参数
-func (s *WebPService) buildCompressionArgs(config *domain.CompressionConfig, inputPath, outputPath string) []string {
-	args := []string{
-		"-q", strconv.Itoa(config.Quality),
-		"-m", strconv.Itoa(config.Method),
-		"-preset", config.Preset,
-		"-mt", // 多线程
-		"-f", strconv.Itoa(config.FilterStrength),
-		"-sharpness", "0",
-		"-sns", "100",
-		"-segments", "4",
-		"-pass", "10",
-		"-alpha_q", strconv.Itoa(config.AlphaQuality),
-		"-size", "0",
-		"-metadata", "none",
-		inputPath,
-		"-o", outputPath,
-	}
-
-	if config.Lossless {
-		args = append([]string{"-lossless"}, args...)
-	}
-
-	return args
+func (s *WebPService) buildCompressionArgs(config *domain.CompressionConfig, inputPath, outputPath string) ([]string, error) {
+	return newCwebpArgs(config, inputPath, outputPath).Render()
 }
 
 // validateInput 验证输入参数
 func (s *WebPService) validateInput(inputPath, outputPath string, config *domain.CompressionConfig) error {
 	// 检查输入文件
 	if !s.fileManager.FileExists(inputPath) {
-		return errors.ErrFileNotFound.WithContext("file", inputPath)
+		return errors.New(errors.ErrorTypeIO, "FILE_NOT_FOUND", "文件不存在").WithContext("file", inputPath)
 	}
 
 	// 检查文件大小
@@ -526,7 +864,121 @@ func (s *WebPService) validateInput(inputPath, outputPath string, config *domain
 
 	// 验证质量参数
 	if config.Quality < 0 || config.Quality > 100 {
-		return errors.ErrInvalidQuality.WithContext("quality", config.Quality)
+		return errors.New(errors.ErrorTypeValidation, "INVALID_QUALITY", "质量参数必须在0-100之间").WithContext("quality", config.Quality)
+	}
+
+	// 验证降帧参数
+	if config.MaxFPS < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_MAX_FPS",
+			fmt.Sprintf("最大帧率不能为负数: %v", config.MaxFPS))
+	}
+	if config.DropEveryN == 1 || config.DropEveryN < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_DROP_EVERY_N",
+			fmt.Sprintf("丢帧间隔必须大于等于2: %d", config.DropEveryN))
+	}
+
+	// 验证编码参数范围
+	if err := newCwebpArgs(config, "", "").Validate(); err != nil {
+		return err
+	}
+
+	// 验证自动质量参数
+	if config.AutoQuality {
+		if config.Lossless {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_AUTO_QUALITY", "无损模式不支持自动质量")
+		}
+		if config.MinSSIM <= 0 && config.MinPSNR <= 0 {
+			return errors.New(errors.ErrorTypeValidation, "INVALID_AUTO_QUALITY", "自动质量需要指定SSIM或PSNR下限")
+		}
+	}
+	if config.MinSSIM < 0 || config.MinSSIM >= 1 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_AUTO_QUALITY",
+			fmt.Sprintf("SSIM下限必须在0-1之间: %v", config.MinSSIM))
+	}
+	if config.MinPSNR < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_AUTO_QUALITY",
+			fmt.Sprintf("PSNR下限不能为负数: %v", config.MinPSNR))
+	}
+
+	// 验证处理管线
+	if config.Pipeline != "" && config.Pipeline != domain.PipelineWebpmux && config.Pipeline != domain.PipelineImg2webp {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_PIPELINE",
+			fmt.Sprintf("不支持的处理管线: %s", config.Pipeline))
+	}
+	if config.Pipeline == domain.PipelineImg2webp && !config.Best && config.AutoQuality {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_PIPELINE", "img2webp管线不支持自动质量")
+	}
+
+	// 验证混合模式参数
+	if config.Mixed && config.Lossless {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_MIXED_MODE", "混合模式不能与无损模式同时使用")
+	}
+	if config.AutoQuality && config.NearLossless > 0 && !config.Mixed {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_AUTO_QUALITY", "近无损模式不支持自动质量")
+	}
+
+	// 验证编码后端
+	if _, err := s.frameEncoder(config); err != nil {
+		return err
+	}
+	if config.Encoder == domain.EncoderFFmpeg && config.NearLossless > 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_ENCODER", "ffmpeg编码后端不支持近无损模式")
+	}
+	if config.Encoder == domain.EncoderFFmpeg && config.Pipeline == domain.PipelineImg2webp && !config.Best {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_ENCODER", "img2webp管线不支持ffmpeg编码后端")
+	}
+
+	// 验证历史最佳设置的使用方式
+	switch config.Learn {
+	case "", domain.LearnSuggest, domain.LearnApply:
+	default:
+		return errors.New(errors.ErrorTypeValidation, "INVALID_LEARN_MODE",
+			fmt.Sprintf("不支持的学习模式: %s，支持: suggest、apply", config.Learn))
+	}
+	if config.Learn != "" && s.config.Processing.HistoryFile == "" {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_LEARN_MODE", "使用学习模式需要配置历史记录文件(WEBP_HISTORY_FILE)")
+	}
+
+	// 验证努力程度
+	if config.Effort < 0 || config.Effort > len(effortLevels) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_EFFORT",
+			fmt.Sprintf("努力程度必须在1-%d之间: %d", len(effortLevels), config.Effort))
+	}
+
+	// 验证截止时间
+	if config.Deadline < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_DEADLINE",
+			fmt.Sprintf("截止时间不能为负数: %v", config.Deadline))
+	}
+	if config.Deadline > 0 && (config.Best || config.Pipeline == domain.PipelineImg2webp) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_DEADLINE", "截止时间只支持webpmux管线")
+	}
+
+	// 验证重新压缩阈值
+	if config.RecompressAbove < 0 {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_RECOMPRESS_THRESHOLD",
+			fmt.Sprintf("重新压缩阈值不能为负数: %d", config.RecompressAbove))
+	}
+	if config.RecompressAbove > 0 && (config.Best || config.Pipeline == domain.PipelineImg2webp) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_RECOMPRESS_THRESHOLD", "重新压缩阈值只支持webpmux管线")
+	}
+
+	// 验证子进程优先级
+	if !domain.IsValidPriority(config.Priority) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_PRIORITY",
+			fmt.Sprintf("不支持的优先级: %s，支持: normal、low、idle", config.Priority))
+	}
+
+	// 验证帧范围参数
+	if config.FrameStart < 0 || config.FrameEnd < 0 ||
+		(config.FrameEnd > 0 && config.FrameStart > config.FrameEnd) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_FRAME_RANGE",
+			fmt.Sprintf("帧范围无效: %d-%d", config.FrameStart, config.FrameEnd))
+	}
+	if config.TrimStart < 0 || config.TrimEnd < 0 ||
+		(config.TrimEnd > 0 && config.TrimStart >= config.TrimEnd) {
+		return errors.New(errors.ErrorTypeValidation, "INVALID_TRIM_RANGE",
+			fmt.Sprintf("裁剪时间范围无效: %v-%v", config.TrimStart, config.TrimEnd))
 	}
 
 	// 验证输出路径目录
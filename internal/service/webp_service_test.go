@@ -6,31 +6,38 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"webpcompressor/internal/config"
 	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
 	"webpcompressor/pkg/logger"
 )
 
 // MockToolExecutor 模拟工具执行器
 type MockToolExecutor struct {
-	commands []string
-	outputs  map[string]string
-	errors   map[string]error
+	mu          sync.Mutex
+	commands    []string
+	outputs     map[string]string
+	errors      map[string]error
+	unavailable map[string]bool
 }
 
 func NewMockToolExecutor() *MockToolExecutor {
 	return &MockToolExecutor{
-		commands: make([]string, 0),
-		outputs:  make(map[string]string),
-		errors:   make(map[string]error),
+		commands:    make([]string, 0),
+		outputs:     make(map[string]string),
+		errors:      make(map[string]error),
+		unavailable: make(map[string]bool),
 	}
 }
 
 func (m *MockToolExecutor) ExecuteCommand(ctx context.Context, toolName string, args ...string) error {
 	key := toolName + " " + strings.Join(args, " ")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.commands = append(m.commands, key)
 	if err, exists := m.errors[key]; exists {
 		return err
@@ -40,9 +47,11 @@ func (m *MockToolExecutor) ExecuteCommand(ctx context.Context, toolName string,
 
 func (m *MockToolExecutor) ExecuteCommandWithOutput(ctx context.Context, toolName string, args ...string) (string, error) {
 	key := toolName + " " + strings.Join(args, " ")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.commands = append(m.commands, key)
 	if err, exists := m.errors[key]; exists {
-		return "", err
+		return m.outputs[key], err
 	}
 	if output, exists := m.outputs[key]; exists {
 		return output, nil
@@ -50,12 +59,23 @@ func (m *MockToolExecutor) ExecuteCommandWithOutput(ctx context.Context, toolNam
 	return "", nil
 }
 
+func (m *MockToolExecutor) ExecuteCommandWithLineHandler(ctx context.Context, toolName string, handler func(line string), args ...string) error {
+	output, err := m.ExecuteCommandWithOutput(ctx, toolName, args...)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		handler(line)
+	}
+	return nil
+}
+
 func (m *MockToolExecutor) GetToolPath(toolName string) string {
 	return toolName + ".exe"
 }
 
 func (m *MockToolExecutor) IsToolAvailable(toolName string) bool {
-	return true
+	return !m.unavailable[toolName]
 }
 
 func (m *MockToolExecutor) SetMockOutput(command, output string) {
@@ -66,6 +86,10 @@ func (m *MockToolExecutor) SetMockError(command string, err error) {
 	m.errors[command] = err
 }
 
+func (m *MockToolExecutor) SetToolUnavailable(toolName string) {
+	m.unavailable[toolName] = true
+}
+
 // MockFileManager 模拟文件管理器
 type MockFileManager struct {
 	files     map[string]bool
@@ -237,6 +261,62 @@ func TestCompressFrames_Success(t *testing.T) {
 	}
 }
 
+func TestCompressFrames_RecordsFrameStats(t *testing.T) {
+	service := createTestWebPService()
+	mockFileManager := service.fileManager.(*MockFileManager)
+	mockFileManager.SetFileSize("frame_1.webp", 4000)
+	mockFileManager.SetFileSize("frame_compressed_1.webp", 1500)
+
+	frames := []*domain.FrameInfo{{Index: 1, Path: "frame_1.webp", Duration: 80 * time.Millisecond}}
+
+	config := domain.DefaultCompressionConfig(50)
+	config.EnableParallel = false
+	if err := service.CompressFrames(context.Background(), frames, config); err != nil {
+		t.Fatalf("CompressFrames failed: %v", err)
+	}
+
+	stats := collectFrameStats(frames)
+	if len(stats) != 1 {
+		t.Fatalf("Expected 1 frame stat, got %d", len(stats))
+	}
+	if stats[0].OriginalSize != 4000 || stats[0].CompressedSize != 1500 || stats[0].Duration != 80*time.Millisecond {
+		t.Errorf("Unexpected frame stat: %+v", stats[0])
+	}
+}
+
+func TestCompressAnimation_StreamingExtract(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+
+	mockOutput := `Canvas size: 288 x 288
+Loop Count : 0
+Number of frames: 2
+No.: width height alpha x_offset y_offset duration dispose blend image_size compression
+  1:    172      1   yes        58      284       50    none    no        172      lossy
+  2:    183     12   yes        52      276       50 background   yes        518      lossy`
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", mockOutput)
+
+	config := domain.DefaultCompressionConfig(50)
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	if result.FramesProcessed != 2 {
+		t.Errorf("Expected 2 frames processed, got %d", result.FramesProcessed)
+	}
+
+	extracted := 0
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "webpmux -get frame") {
+			extracted++
+		}
+	}
+	if extracted != 2 {
+		t.Errorf("Expected 2 frame extractions, got %d", extracted)
+	}
+}
+
 func TestValidateInput_InvalidQuality(t *testing.T) {
 	service := createTestWebPService()
 
@@ -264,6 +344,76 @@ func TestValidateInput_FileNotExists(t *testing.T) {
 	}
 }
 
+func TestValidateInput_ConcurrentErrorsKeepOwnContext(t *testing.T) {
+	service := createTestWebPService()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(quality int) {
+			defer wg.Done()
+			err := service.validateInput("test.webp", "output.webp", &domain.CompressionConfig{Quality: quality})
+			appErr, ok := err.(*errors.AppError)
+			if !ok || appErr.Code != "INVALID_QUALITY" {
+				t.Errorf("Expected INVALID_QUALITY, got %v", err)
+				return
+			}
+			if appErr.Context["quality"] != quality {
+				t.Errorf("Expected quality %d in context, got %v", quality, appErr.Context["quality"])
+			}
+		}(101 + i)
+	}
+	wg.Wait()
+}
+
+func TestValidateInput_InvalidPriority(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Priority = "urgent"
+
+	if err := service.validateInput("test.webp", "output.webp", config); err == nil {
+		t.Error("Expected error for invalid priority, got nil")
+	}
+
+	config.Priority = domain.PriorityIdle
+	if err := service.validateInput("test.webp", "output.webp", config); err != nil {
+		t.Errorf("Expected idle priority to be accepted, got %v", err)
+	}
+}
+
+func TestCompressAnimation_PassesPriorityToTools(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := &priorityRecordingExecutor{MockToolExecutor: service.toolExecutor.(*MockToolExecutor)}
+	service = NewWebPService(service.config, mockToolExecutor, service.fileManager, service.logger)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.Priority = domain.PriorityLow
+	if _, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config); err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+
+	if mockToolExecutor.priority != domain.PriorityLow {
+		t.Errorf("Expected tools to run with low priority, got %q", mockToolExecutor.priority)
+	}
+}
+
+// priorityRecordingExecutor 记录cwebp调用时上下文中的优先级
+type priorityRecordingExecutor struct {
+	*MockToolExecutor
+	priority string
+}
+
+func (e *priorityRecordingExecutor) ExecuteCommand(ctx context.Context, toolName string, args ...string) error {
+	if toolName == "cwebp" {
+		e.mu.Lock()
+		e.priority = domain.ToolPriorityFromContext(ctx)
+		e.mu.Unlock()
+	}
+	return e.MockToolExecutor.ExecuteCommand(ctx, toolName, args...)
+}
+
 func BenchmarkParseAnimation(b *testing.B) {
 	service := createTestWebPService()
 	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
@@ -289,3 +439,40 @@ No.: width height alpha x_offset y_offset duration dispose blend image_size comp
 		}
 	}
 }
+
+func TestCompressAnimation_RecompressAboveKeepsSmallFrames(t *testing.T) {
+	service := createTestWebPService()
+	mockToolExecutor := service.toolExecutor.(*MockToolExecutor)
+	mockToolExecutor.SetMockOutput("webpmux -info test.webp", verifyTestOutputInfo)
+	mockFileManager := service.fileManager.(*MockFileManager)
+	tempDir := filepath.Join(os.TempDir(), "webp_compress_test")
+	mockFileManager.SetFileSize(filepath.Join(tempDir, "frame_1.webp"), 500)
+	mockFileManager.SetFileSize(filepath.Join(tempDir, "frame_2.webp"), 5000)
+
+	config := domain.DefaultCompressionConfig(50)
+	config.RecompressAbove = 1000
+	result, err := service.CompressAnimation(context.Background(), "test.webp", "out.webp", config)
+	if err != nil {
+		t.Fatalf("CompressAnimation failed: %v", err)
+	}
+	if result.FramesKept != 1 || !result.FrameStats[0].Kept || result.FrameStats[1].Kept {
+		t.Errorf("Expected only frame 1 kept, got %d kept: %+v", result.FramesKept, result.FrameStats)
+	}
+
+	for _, cmd := range mockToolExecutor.commands {
+		if strings.HasPrefix(cmd, "cwebp ") && strings.Contains(cmd, "frame_1.webp") {
+			t.Errorf("Expected frame 1 not to be recompressed, got %s", cmd)
+		}
+	}
+}
+
+func TestValidateInput_RecompressAboveRequiresWebpmux(t *testing.T) {
+	service := createTestWebPService()
+
+	config := domain.DefaultCompressionConfig(50)
+	config.RecompressAbove = 1000
+	config.Pipeline = domain.PipelineImg2webp
+	if err := service.validateInput("test.webp", "out.webp", config); !errors.IsCode(err, "INVALID_RECOMPRESS_THRESHOLD") {
+		t.Errorf("Expected INVALID_RECOMPRESS_THRESHOLD, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/domain"
+	"webpcompressor/pkg/errors"
+)
+
+func TestWorkerPool_WaitReturnsAllFrameErrors(t *testing.T) {
+	pool := domain.NewWorkerPool(2)
+
+	var mu sync.Mutex
+	processed := make(map[int]bool)
+	pool.Start(context.Background(), func(ctx context.Context, frame *domain.FrameInfo) error {
+		if frame.Index%2 == 0 {
+			return fmt.Errorf("frame %d failed", frame.Index)
+		}
+		mu.Lock()
+		processed[frame.Index] = true
+		mu.Unlock()
+		return nil
+	})
+
+	// 失败帧数超过工作池缓冲时也不应阻塞
+	for i := 1; i <= 20; i++ {
+		pool.Submit(context.Background(), &domain.FrameInfo{Index: i})
+	}
+	pool.Close()
+
+	errs := pool.Wait()
+	if len(errs) != 10 {
+		t.Fatalf("Expected 10 frame errors, got %d", len(errs))
+	}
+	for i, err := range errs {
+		if err.Frame.Index != (i+1)*2 {
+			t.Errorf("Expected errors sorted by frame index, got %d at %d", err.Frame.Index, i)
+		}
+	}
+	if len(processed) != 10 {
+		t.Errorf("Expected 10 successful frames, got %d", len(processed))
+	}
+}
+
+func TestWorkerPool_SubmitReturnsAfterCancel(t *testing.T) {
+	pool := domain.NewWorkerPool(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	var once sync.Once
+	pool.Start(ctx, func(ctx context.Context, frame *domain.FrameInfo) error {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := pool.Submit(ctx, &domain.FrameInfo{Index: 1}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+
+	// 填满队列后取消，Submit不应继续阻塞
+	done := make(chan error, 1)
+	go func() {
+		for i := 2; ; i++ {
+			if err := pool.Submit(ctx, &domain.FrameInfo{Index: i}); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Submit blocked after cancel")
+	}
+
+	pool.Close()
+	errs := pool.Wait()
+	if len(errs) == 0 {
+		t.Fatal("Expected cancelled frames to be reported")
+	}
+	for _, err := range errs {
+		if err.Err != context.Canceled {
+			t.Errorf("Frame %d: expected context.Canceled, got %v", err.Frame.Index, err.Err)
+		}
+	}
+}
+
+func TestWorkerPool_PanicFailsFrame(t *testing.T) {
+	pool := domain.NewWorkerPool(1)
+
+	var processed int
+	pool.Start(context.Background(), func(ctx context.Context, frame *domain.FrameInfo) error {
+		if frame.Index == 2 {
+			panic("boom")
+		}
+		processed++
+		return nil
+	})
+
+	for i := 1; i <= 3; i++ {
+		pool.Submit(context.Background(), &domain.FrameInfo{Index: i})
+	}
+	pool.Close()
+
+	// panic的帧记为失败，同一工作者继续处理后续帧
+	errs := pool.Wait()
+	if len(errs) != 1 || errs[0].Frame.Index != 2 {
+		t.Fatalf("Expected only frame 2 to fail, got %v", errs)
+	}
+	if !errors.IsCode(errs[0].Err, "FRAME_PANIC") {
+		t.Errorf("Expected FRAME_PANIC, got %v", errs[0].Err)
+	}
+	if appErr, ok := errs[0].Err.(*errors.AppError); !ok || !strings.Contains(appErr.StackTrace, "worker_pool_test.go") {
+		t.Errorf("Expected stack trace to include the panic site")
+	}
+	if processed != 2 {
+		t.Errorf("Expected 2 processed frames, got %d", processed)
+	}
+}
@@ -0,0 +1,196 @@
+// Package webpcompress 提供WebP动画压缩的公共库接口
+//
+// 其他Go程序可以直接嵌入压缩流程，不必调用命令行程序。压缩仍由本机的libwebp工具完成，
+// 需要webpmux和cwebp可用，导出GIF/APNG还需要anim_dump。
+//
+//	c, err := webpcompress.New(webpcompress.Config{ToolsPath: "./tools"})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := c.Compress(ctx, "in.webp", "out.webp", webpcompress.DefaultOptions(75))
+//
+// 返回的错误为*errors.AppError（见webpcompressor/pkg/errors），可用errors.IsCode按错误码判断
+package webpcompress
+
+import (
+	"context"
+	"time"
+
+	"webpcompressor/internal/config"
+	"webpcompressor/internal/domain"
+	"webpcompressor/internal/infrastructure"
+	"webpcompressor/internal/service"
+	"webpcompressor/pkg/errors"
+	"webpcompressor/pkg/logger"
+)
+
+// 处理管线
+const (
+	PipelineWebpmux  = domain.PipelineWebpmux  // 逐帧提取、压缩后重新组装（默认）
+	PipelineImg2webp = domain.PipelineImg2webp // 解码完整画布帧后重新编码
+)
+
+// Format 动画导出格式
+type Format string
+
+// 导出格式
+const (
+	FormatGIF  Format = Format(domain.OutputFormatGIF)
+	FormatAPNG Format = Format(domain.OutputFormatAPNG)
+)
+
+// Config 压缩器配置
+type Config struct {
+	ToolsPath      string        // libwebp工具所在目录，为空时从PATH查找
+	CommandTimeout time.Duration // 单次工具调用的超时，0表示使用默认值；精度为秒，不足一秒的部分向上取整
+	Logger         logger.Logger // 日志，为空时使用默认日志
+}
+
+// Options 单次压缩的选项，应从DefaultOptions开始修改
+type Options struct {
+	Quality      int     // 质量 0-100
+	Method       int     // 压缩方法 0-6，越大越慢、越小
+	Lossless     bool    // 无损压缩
+	AlphaQuality int     // Alpha质量 0-100
+	Preset       string  // cwebp预设，如photo、picture、drawing
+	Concurrency  int     // 并行压缩的帧数，1表示串行
+	MaxFPS       float64 // 最大帧率，0表示不限制
+	Deduplicate  bool    // 合并画面相同的连续帧
+	Pipeline     string  // 处理管线，见Pipeline*常量，为空时使用webpmux
+	Fallback     bool    // 处理管线失败时依次尝试其他策略
+}
+
+// DefaultOptions 返回与命令行程序默认值相同的选项
+func DefaultOptions(quality int) Options {
+	defaults := domain.DefaultCompressionConfig(quality)
+	return Options{
+		Quality:      defaults.Quality,
+		Method:       defaults.Method,
+		Lossless:     defaults.Lossless,
+		AlphaQuality: defaults.AlphaQuality,
+		Preset:       defaults.Preset,
+		Concurrency:  defaults.MaxConcurrency,
+	}
+}
+
+// Result 压缩或导出的结果
+type Result struct {
+	OriginalSize     int64         // 输入大小(字节)
+	OutputSize       int64         // 输出大小(字节)
+	CompressionRatio float64       // 输出占输入的百分比
+	Frames           int           // 处理的帧数
+	Duration         time.Duration // 处理耗时
+	Pipeline         string        // 实际使用的处理管线
+	Fallback         string        // 主处理失败后成功的回退策略，为空表示未回退
+}
+
+// Info 动画信息
+type Info struct {
+	Width     int           // 画布宽度
+	Height    int           // 画布高度
+	Frames    int           // 帧数
+	LoopCount int           // 循环次数，0表示无限循环
+	Duration  time.Duration // 总播放时长
+}
+
+// Compressor WebP动画压缩器，可在多个goroutine中并发使用
+type Compressor struct {
+	service *service.WebPService
+}
+
+// New 创建压缩器，检查必需的工具是否可用
+func New(cfg Config) (*Compressor, error) {
+	appConfig := config.DefaultConfig()
+	if cfg.ToolsPath != "" {
+		appConfig.Tools.ToolsPath = cfg.ToolsPath
+	}
+	if cfg.CommandTimeout > 0 {
+		appConfig.Tools.CommandTimeout = commandTimeoutSeconds(cfg.CommandTimeout)
+	}
+	if err := appConfig.Validate(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeConfiguration, "CONFIG_INVALID", "配置无效")
+	}
+
+	appLogger := cfg.Logger
+	if appLogger == nil {
+		appLogger = logger.NewDefaultLogger()
+	}
+
+	toolFactory := infrastructure.NewToolExecutorFactory(appConfig, appLogger)
+	toolExecutor := toolFactory.CreateExecutor(false, "")
+	if err := toolFactory.ValidateTools(toolExecutor); err != nil {
+		return nil, err
+	}
+	fileManager := infrastructure.NewFileManagerFactory(appConfig, appLogger).CreateFileManager(true)
+
+	return &Compressor{
+		service: service.NewWebPService(appConfig, toolExecutor, fileManager, appLogger),
+	}, nil
+}
+
+// commandTimeoutSeconds 把工具超时换算为配置使用的秒数，向上取整，避免不足一秒的超时变为0
+func commandTimeoutSeconds(timeout time.Duration) int {
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// Compress 按选项压缩WebP动画
+func (c *Compressor) Compress(ctx context.Context, inputPath, outputPath string, opts Options) (*Result, error) {
+	compressionConfig := domain.DefaultCompressionConfig(opts.Quality)
+	compressionConfig.Method = opts.Method
+	compressionConfig.Lossless = opts.Lossless
+	compressionConfig.AlphaQuality = opts.AlphaQuality
+	compressionConfig.Preset = opts.Preset
+	compressionConfig.EnableParallel = opts.Concurrency > 1
+	compressionConfig.MaxConcurrency = opts.Concurrency
+	compressionConfig.MaxFPS = opts.MaxFPS
+	compressionConfig.Deduplicate = opts.Deduplicate
+	compressionConfig.Pipeline = opts.Pipeline
+	compressionConfig.Fallback = opts.Fallback
+
+	result, err := c.service.CompressAnimation(ctx, inputPath, outputPath, compressionConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result), nil
+}
+
+// Info 解析WebP动画的画布、帧数和时长
+func (c *Compressor) Info(ctx context.Context, inputPath string) (*Info, error) {
+	animInfo, err := c.service.ParseAnimation(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		Width:     animInfo.Width,
+		Height:    animInfo.Height,
+		Frames:    len(animInfo.Frames),
+		LoopCount: animInfo.LoopCount,
+	}
+	for _, frame := range animInfo.Frames {
+		info.Duration += frame.Duration
+	}
+	return info, nil
+}
+
+// Convert 将WebP动画导出为GIF或APNG
+func (c *Compressor) Convert(ctx context.Context, inputPath, outputPath string, format Format) (*Result, error) {
+	result, err := c.service.ConvertAnimation(ctx, inputPath, outputPath, domain.OutputFormat(format))
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result), nil
+}
+
+// newResult 把服务层结果转换为公共结果
+func newResult(result *domain.CompressResult) *Result {
+	return &Result{
+		OriginalSize:     result.OriginalSize,
+		OutputSize:       result.CompressedSize,
+		CompressionRatio: result.CompressionRatio,
+		Frames:           result.FramesProcessed,
+		Duration:         result.ProcessingTime,
+		Pipeline:         result.Pipeline,
+		Fallback:         result.Fallback,
+	}
+}
@@ -0,0 +1,161 @@
+package webpcompress
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webpcompressor/internal/faketools"
+	"webpcompressor/pkg/errors"
+)
+
+// toolsPath 安装了faketools替身工具的目录
+var toolsPath string
+
+func TestMain(m *testing.M) {
+	if code, ok := faketools.Main(); ok {
+		os.Exit(code)
+	}
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "webpcompress_tools")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建工具目录失败: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	if err := faketools.Install(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	toolsPath = dir
+	return m.Run()
+}
+
+func newTestCompressor(t *testing.T) *Compressor {
+	t.Helper()
+	c, err := New(Config{ToolsPath: toolsPath, CommandTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return c
+}
+
+func fixture(t *testing.T) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("..", "..", "testdata", "input", "lianzhixin_1.webp"))
+	if err != nil {
+		t.Fatalf("abs: %v", err)
+	}
+	return path
+}
+
+func TestDefaultOptions(t *testing.T) {
+	opts := DefaultOptions(40)
+	if opts.Quality != 40 {
+		t.Errorf("Expected quality 40, got %d", opts.Quality)
+	}
+	if opts.Method < 0 || opts.Method > 6 {
+		t.Errorf("Expected method in 0-6, got %d", opts.Method)
+	}
+	if opts.Concurrency < 1 {
+		t.Errorf("Expected positive concurrency, got %d", opts.Concurrency)
+	}
+	if opts.Pipeline != "" || opts.Fallback || opts.Deduplicate {
+		t.Errorf("Expected optional features off by default, got %+v", opts)
+	}
+}
+
+func TestNew_ToolsMissing(t *testing.T) {
+	_, err := New(Config{ToolsPath: t.TempDir()})
+	if !errors.IsCode(err, "TOOLS_MISSING") {
+		t.Errorf("Expected TOOLS_MISSING, got %v", err)
+	}
+}
+
+func TestCommandTimeoutSeconds(t *testing.T) {
+	tests := []struct {
+		timeout  time.Duration
+		expected int
+	}{
+		{time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+	}
+	for _, tt := range tests {
+		if got := commandTimeoutSeconds(tt.timeout); got != tt.expected {
+			t.Errorf("commandTimeoutSeconds(%v) = %d, want %d", tt.timeout, got, tt.expected)
+		}
+	}
+}
+
+func TestNew_SubSecondTimeout(t *testing.T) {
+	if _, err := New(Config{ToolsPath: toolsPath, CommandTimeout: 500 * time.Millisecond}); err != nil {
+		t.Errorf("Expected sub-second timeout to be accepted, got %v", err)
+	}
+}
+
+func TestCompressor_Info(t *testing.T) {
+	info, err := newTestCompressor(t).Info(context.Background(), fixture(t))
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if info.Width != 288 || info.Height != 288 || info.Frames != 120 || info.LoopCount != 0 {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if info.Duration != 120*50*time.Millisecond {
+		t.Errorf("Expected duration 6s, got %v", info.Duration)
+	}
+}
+
+func TestCompressor_Compress(t *testing.T) {
+	c := newTestCompressor(t)
+	output := filepath.Join(t.TempDir(), "out.webp")
+
+	result, err := c.Compress(context.Background(), fixture(t), output, DefaultOptions(40))
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if result.Frames != 120 || result.OriginalSize == 0 || result.OutputSize == 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Pipeline != PipelineWebpmux || result.Fallback != "" {
+		t.Errorf("Expected webpmux pipeline without fallback, got %q/%q", result.Pipeline, result.Fallback)
+	}
+
+	info, err := c.Info(context.Background(), output)
+	if err != nil {
+		t.Fatalf("Info on output failed: %v", err)
+	}
+	if info.Frames != 120 {
+		t.Errorf("Expected 120 frames in output, got %d", info.Frames)
+	}
+}
+
+func TestCompressor_Convert(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.gif")
+
+	result, err := newTestCompressor(t).Convert(context.Background(), fixture(t), output, FormatGIF)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if result.Frames != 120 || result.OutputSize == 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if len(data) < 6 || string(data[:6]) != "GIF89a" {
+		t.Errorf("Output is not a GIF")
+	}
+}